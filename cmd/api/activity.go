@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/model"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// userToday returns the current time in the user's time zone, falling back
+// to UTC when the stored zone is unknown.
+func userToday(user model.User) time.Time {
+	location, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	return time.Now().In(location)
+}
+
+// validTimeZone accepts IANA zone names such as America/Sao_Paulo. The
+// empty name and "Local", which time.LoadLocation also takes, are refused.
+func validTimeZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+func (s *Server) recordActivity(userID int, kind string) {
+	user, err := s.db.GetUserByID(userID)
+	if err != nil {
+		log.Errorf("loading user to record activity: %v", err)
+		return
+	}
+
+	if err := s.db.RecordActivity(userID, userToday(user), kind); err != nil {
+		log.Error(err)
+	}
+}
+
+// getMyActivity returns the activity heatmap of the last year, one entry per
+// day the student studied.
+func (s *Server) getMyActivity(w http.ResponseWriter, r *http.Request) {
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	since := userToday(user).AddDate(-1, 0, 1)
+	activity, err := s.db.GetActivity(user.ID, since)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, activity)
+}
+
+func (s *Server) getMyStreak(w http.ResponseWriter, r *http.Request) {
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	activity, err := s.db.GetActivity(user.ID, time.Time{})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	days := make([]time.Time, len(activity))
+	for i, day := range activity {
+		days[i] = day.Date
+	}
+
+	today := userToday(user)
+	streak := model.Streak{
+		Current: gamification.CurrentStreak(days, today),
+		Longest: gamification.LongestStreak(days),
+	}
+	if len(days) > 0 {
+		last := days[len(days)-1]
+		streak.ActiveToday = last.Format("2006-01-02") == today.Format("2006-01-02")
+	}
+	streak.AtRisk = streak.Current > 0 && !streak.ActiveToday
+
+	writeJSON(w, http.StatusOK, streak)
+}
+
+func (s *Server) setMyTimeZone(w http.ResponseWriter, r *http.Request) {
+	var request TimeZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !validTimeZone(request.TimeZone) {
+		http.Error(w, "Unknown time zone", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.SetUserTimeZone(claimsFromContext(r.Context()).UserID, request.TimeZone); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendStreakReminders emails students whose streak ends tonight unless they
+// study today. Each student is reminded at most once per local day.
+func (s *Server) sendStreakReminders(hour int) func(context.Context) error {
+	return func(_ context.Context) error {
+		reminders, err := s.db.GetStreakReminders(hour)
+		if err != nil {
+			return err
+		}
+
+		for _, reminder := range reminders {
+			activity, err := s.db.GetActivity(reminder.UserID, time.Time{})
+			if err != nil {
+				return err
+			}
+
+			days := make([]time.Time, len(activity))
+			for i, day := range activity {
+				days[i] = day.Date
+			}
+
+			streak := gamification.CurrentStreak(days, reminder.Day)
+			if err := s.sender.SendStreakReminderEmail(reminder.Email, streak); err != nil {
+				log.Errorf("sending streak reminder to user %d: %v", reminder.UserID, err)
+				continue
+			}
+
+			if err := s.db.MarkStreakReminderSent(reminder.UserID, reminder.Day); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/certificates"
+	"game-student-go/internal/model"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+// issueCertificateIfFinished issues the course certificate once the user has
+// completed every training of it. Failures are only logged since they must
+// not fail the training completion that triggered them.
+func (s *Server) issueCertificateIfFinished(userID, courseID int) {
+	progress, err := s.db.GetCourseProgress(userID, courseID)
+	if err != nil {
+		log.Errorf("checking progress for certificate: %v", err)
+		return
+	}
+
+	if !progress.Finished() {
+		return
+	}
+
+	user, err := s.db.GetUserByID(userID)
+	if err != nil {
+		log.Errorf("loading user for certificate: %v", err)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(courseID)
+	if err != nil {
+		log.Errorf("loading course for certificate: %v", err)
+		return
+	}
+
+	code, err := certificates.NewCode()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	_, err = s.db.IssueCertificate(model.Certificate{
+		Code:        code,
+		UserID:      user.ID,
+		CourseID:    course.ID,
+		StudentName: certificateName(user),
+		CourseName:  course.Name,
+	})
+	if err != nil {
+		log.Errorf("issuing certificate: %v", err)
+	}
+}
+
+// anonymousStudentName goes on the certificates of students who haven't set
+// a display name. Certificates are public, so emails never go on them.
+const anonymousStudentName = "Estudante da Escola do Jogo"
+
+func certificateName(user model.User) string {
+	if name := strings.TrimSpace(user.DisplayName); name != "" {
+		return name
+	}
+
+	return anonymousStudentName
+}
+
+func (s *Server) certificateURL(code string) string {
+	return fmt.Sprintf("%s/certificates/%s", s.publicURL, code)
+}
+
+func (s *Server) listMyCertificates(w http.ResponseWriter, r *http.Request) {
+	certs, err := s.db.GetCertificatesByUser(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, certs)
+}
+
+// verifyCertificate is public so employers can check a code printed on a
+// certificate. Revoked certificates are still found but reported as invalid.
+func (s *Server) verifyCertificate(w http.ResponseWriter, r *http.Request) {
+	certificate, err := s.db.GetCertificateByCode(mux.Vars(r)["code"])
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CertificateVerificationResponse{
+		Code:        certificate.Code,
+		StudentName: certificate.StudentName,
+		CourseName:  certificate.CourseName,
+		IssuedAt:    certificate.IssuedAt,
+		Valid:       certificate.RevokedAt == nil,
+		RevokedAt:   certificate.RevokedAt,
+	})
+}
+
+func (s *Server) downloadCertificate(w http.ResponseWriter, r *http.Request) {
+	certificate, err := s.db.GetCertificateByCode(mux.Vars(r)["code"])
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if certificate.RevokedAt != nil {
+		http.Error(w, "Certificate has been revoked", http.StatusGone)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="certificate-%s.pdf"`, certificate.Code))
+	if _, err := w.Write(certificates.RenderPDF(*certificate, s.certificateURL(certificate.Code))); err != nil {
+		log.Error("Failed to write certificate:", err)
+	}
+}
+
+func (s *Server) revokeCertificate(w http.ResponseWriter, r *http.Request) {
+	var request RevokeCertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	certificate, err := s.db.RevokeCertificate(mux.Vars(r)["code"], request.Reason)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, certificate)
+}
@@ -0,0 +1,658 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/calendar"
+	"game-student-go/internal/database"
+	"game-student-go/internal/model"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stripe/stripe-go/v74"
+	"github.com/stripe/stripe-go/v74/paymentintent"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxCohortNameLength = 255
+
+func validateCohort(request CohortRequest) (model.Cohort, error) {
+	cohort := model.Cohort{
+		Name:     strings.TrimSpace(request.Name),
+		StartsAt: request.StartsAt,
+		EndsAt:   request.EndsAt,
+		Capacity: request.Capacity,
+		Price:    request.Price,
+		Currency: strings.ToLower(request.Currency),
+	}
+
+	if cohort.Name == "" || len(cohort.Name) > maxCohortNameLength {
+		return cohort, fmt.Errorf("name must have between 1 and %d characters", maxCohortNameLength)
+	}
+	if cohort.StartsAt.IsZero() || !cohort.EndsAt.After(cohort.StartsAt) {
+		return cohort, errors.New("ends_at must come after starts_at")
+	}
+	if cohort.Capacity < 1 {
+		return cohort, errors.New("capacity must be at least 1")
+	}
+	if cohort.Price < 0 {
+		return cohort, errors.New("price must not be negative")
+	}
+	if cohort.Currency == "" {
+		cohort.Currency = "brl"
+	}
+	if len(cohort.Currency) != 3 {
+		return cohort, errors.New("currency must be a three letter ISO code")
+	}
+
+	return cohort, nil
+}
+
+func validateLiveSession(request LiveSessionRequest) (model.LiveSession, error) {
+	session := model.LiveSession{
+		Title:           strings.TrimSpace(request.Title),
+		StartsAt:        request.StartsAt,
+		DurationMinutes: request.DurationMinutes,
+		MeetingURL:      strings.TrimSpace(request.MeetingURL),
+	}
+
+	if session.Title == "" || len(session.Title) > maxCohortNameLength {
+		return session, fmt.Errorf("title must have between 1 and %d characters", maxCohortNameLength)
+	}
+	if session.StartsAt.IsZero() {
+		return session, errors.New("starts_at is required")
+	}
+	if session.DurationMinutes < 1 {
+		return session, errors.New("duration_minutes must be at least 1")
+	}
+
+	return session, validateWebURL("meeting_url", session.MeetingURL)
+}
+
+// visibleCohort loads the cohort in the id path variable and its course,
+// answering 404 when the caller may not see the course.
+func (s *Server) visibleCohort(w http.ResponseWriter, r *http.Request) (*model.Cohort, model.Course, bool) {
+	cohortID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, model.Course{}, false
+	}
+
+	cohort, err := s.db.GetCohort(cohortID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, model.Course{}, false
+	}
+
+	course, err := s.db.GetCourseByID(cohort.CourseID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, model.Course{}, false
+	}
+
+	if !canSeeCourse(claimsFromContext(r.Context()), course) {
+		http.Error(w, fmt.Sprintf("no cohort found with id %d", cohortID), http.StatusNotFound)
+		return nil, model.Course{}, false
+	}
+
+	return cohort, course, true
+}
+
+// managedCohort is visibleCohort for callers that may edit the course.
+func (s *Server) managedCohort(w http.ResponseWriter, r *http.Request) (*model.Cohort, bool) {
+	cohort, course, ok := s.visibleCohort(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !canManage(claimsFromContext(r.Context()), course) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return cohort, true
+}
+
+func (s *Server) getCourseCohorts(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if !canSeeCourse(claimsFromContext(r.Context()), course) {
+		http.Error(w, fmt.Sprintf("no course found with id %d", courseID), http.StatusNotFound)
+		return
+	}
+
+	cohorts, err := s.db.GetCourseCohorts(course.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cohorts)
+}
+
+func (s *Server) createCohort(w http.ResponseWriter, r *http.Request) {
+	course, ok := s.managedCourse(w, r)
+	if !ok {
+		return
+	}
+
+	var request CohortRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cohort, err := validateCohort(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cohort.CourseID = course.ID
+
+	created, err := s.db.CreateCohort(cohort)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) getCohort(w http.ResponseWriter, r *http.Request) {
+	cohort, _, ok := s.visibleCohort(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cohort)
+}
+
+func (s *Server) updateCohort(w http.ResponseWriter, r *http.Request) {
+	cohort, ok := s.managedCohort(w, r)
+	if !ok {
+		return
+	}
+
+	var request CohortRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changed, err := validateCohort(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	changed.ID = cohort.ID
+
+	updated, err := s.db.UpdateCohort(changed)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) deleteCohort(w http.ResponseWriter, r *http.Request) {
+	cohort, ok := s.managedCohort(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteCohort(cohort.ID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// enrollInCohort takes a seat for the caller. Free cohorts enroll right away.
+// Paid ones charge the given payment method and keep the seat pending until
+// the Stripe webhook confirms the payment, unless Stripe settles it at once.
+// The seat and its payment are recorded before the card is charged, so a
+// webhook arriving early always finds them.
+func (s *Server) enrollInCohort(w http.ResponseWriter, r *http.Request) {
+	cohort, _, ok := s.visibleCohort(w, r)
+	if !ok {
+		return
+	}
+
+	if !cohort.EndsAt.After(time.Now()) {
+		http.Error(w, fmt.Sprintf("cohort %d has ended", cohort.ID), http.StatusConflict)
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	if !s.requirePrerequisites(w, userID, cohort.CourseID) {
+		return
+	}
+
+	if cohort.Price == 0 {
+		enrollment, err := s.db.Enroll(cohort.ID, userID, model.EnrollmentActive)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, enrollment)
+		return
+	}
+
+	var request EnrollmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.PaymentMethodID == "" {
+		http.Error(w, "payment_method_id is required for paid cohorts", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByID(userID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	// Checked before taking the seat, as Stripe refuses a payment without a
+	// customer.
+	if user.StripeId == "" {
+		http.Error(w, "Your account has no payment profile yet, so it cannot pay for a cohort", http.StatusConflict)
+		return
+	}
+
+	enrollment, err := s.db.Enroll(cohort.ID, userID, model.EnrollmentPending)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	// Each seat taken gets its own key, so retried calls to Stripe never
+	// charge twice for it.
+	idempotencyKey := fmt.Sprintf("cohort-%d-user-%d-%d", cohort.ID, userID, enrollment.CreatedAt.UnixNano())
+
+	params := &stripe.PaymentIntentParams{
+		Amount:             stripe.Int64(cohort.Price),
+		Currency:           stripe.String(cohort.Currency),
+		Customer:           stripe.String(user.StripeId),
+		PaymentMethod:      stripe.String(request.PaymentMethodID),
+		PaymentMethodTypes: stripe.StringSlice([]string{string(stripe.PaymentMethodTypeCard)}),
+		Description:        stripe.String(fmt.Sprintf("Turma %s", cohort.Name)),
+	}
+	params.AddMetadata("cohort_id", strconv.Itoa(cohort.ID))
+	params.AddMetadata("user_id", strconv.Itoa(userID))
+	params.SetIdempotencyKey(idempotencyKey + "-create")
+
+	pi, err := paymentintent.New(params)
+	if err != nil {
+		s.releaseSeat(cohort.ID, userID)
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+		return
+	}
+
+	payment, err := s.db.AddEnrollmentPayment(cohort.ID, userID, pi)
+	if err != nil {
+		if _, err := paymentintent.Cancel(pi.ID, nil); err != nil {
+			log.Errorf("cancelling payment %s of user %d in cohort %d: %v", pi.ID, userID, cohort.ID, err)
+		}
+		s.releaseSeat(cohort.ID, userID)
+		writeDBError(w, err)
+		return
+	}
+	enrollment.PaymentID = &payment.ID
+
+	confirm := &stripe.PaymentIntentConfirmParams{}
+	confirm.SetIdempotencyKey(idempotencyKey + "-confirm")
+	pi, err = paymentintent.Confirm(pi.ID, confirm)
+	if err != nil {
+		// An error does not mean the charge failed, so the seat is only
+		// given back once the payment can no longer succeed. Otherwise the
+		// webhook or expireEnrollments settles it.
+		if _, cancelErr := paymentintent.Cancel(payment.StripePaymentIntentID, nil); cancelErr != nil {
+			log.Errorf("cancelling payment %s of user %d in cohort %d: %v", payment.StripePaymentIntentID, userID, cohort.ID, cancelErr)
+		} else {
+			s.releaseSeat(cohort.ID, userID)
+		}
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+		return
+	}
+
+	status := http.StatusAccepted
+	if pi.Status == stripe.PaymentIntentStatusSucceeded {
+		payment.Status = string(pi.Status)
+		if _, err := s.db.UpdatePaymentStatus(payment); err != nil {
+			writeDBError(w, err)
+			return
+		}
+		if err := s.db.SetPaymentEnrollmentStatus(payment.ID, model.EnrollmentActive); err != nil {
+			writeDBError(w, err)
+			return
+		}
+		enrollment.Status = model.EnrollmentActive
+		status = http.StatusCreated
+	}
+
+	writeJSON(w, status, enrollment)
+}
+
+func (s *Server) releaseSeat(cohortID, userID int) {
+	if err := s.db.CancelPendingEnrollment(cohortID, userID); err != nil {
+		log.Errorf("releasing seat of user %d in cohort %d: %v", userID, cohortID, err)
+	}
+}
+
+// expireEnrollments gives back the seats of payments that did not go
+// through within ttl. Their payment is cancelled first so it cannot succeed
+// afterwards; payments Stripe will not cancel are left for the webhook.
+func (s *Server) expireEnrollments(ttl time.Duration) func(context.Context) error {
+	return func(_ context.Context) error {
+		expired, err := s.db.GetExpiredEnrollments(time.Now().Add(-ttl))
+		if err != nil {
+			return err
+		}
+
+		for _, enrollment := range expired {
+			if enrollment.PaymentIntentID != "" {
+				if _, err := paymentintent.Cancel(enrollment.PaymentIntentID, nil); err != nil {
+					log.Warnf("cancelling payment %s of user %d in cohort %d: %v", enrollment.PaymentIntentID,
+						enrollment.UserID, enrollment.CohortID, err)
+					continue
+				}
+			}
+
+			if err := s.db.CancelPendingEnrollment(enrollment.CohortID, enrollment.UserID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// cohortPayment records a payment Stripe reports for a cohort seat that was
+// not recorded yet, finding the seat by the metadata the payment was
+// created with.
+func (s *Server) cohortPayment(pi *stripe.PaymentIntent) (*model.Payment, error) {
+	cohortID, err := strconv.Atoi(pi.Metadata["cohort_id"])
+	if err != nil {
+		return nil, fmt.Errorf("payment %s is not for a cohort: %w", pi.ID, database.ErrNotFound)
+	}
+
+	userID, err := strconv.Atoi(pi.Metadata["user_id"])
+	if err != nil {
+		return nil, fmt.Errorf("payment %s has no user: %w", pi.ID, database.ErrNotFound)
+	}
+
+	return s.db.AddEnrollmentPayment(cohortID, userID, pi)
+}
+
+func (s *Server) getCohortEnrollments(w http.ResponseWriter, r *http.Request) {
+	cohort, ok := s.managedCohort(w, r)
+	if !ok {
+		return
+	}
+
+	enrollments, err := s.db.GetCohortEnrollments(cohort.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, enrollments)
+}
+
+// getCohortSessions lists the live sessions of a cohort to its active
+// students and to whoever manages the course, as they carry meeting links.
+func (s *Server) getCohortSessions(w http.ResponseWriter, r *http.Request) {
+	cohort, course, ok := s.visibleCohort(w, r)
+	if !ok {
+		return
+	}
+
+	claims := claimsFromContext(r.Context())
+	if !canManage(claims, course) {
+		enrollment, err := s.db.GetEnrollment(cohort.ID, claims.UserID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			writeDBError(w, err)
+			return
+		}
+		if enrollment == nil || enrollment.Status != model.EnrollmentActive {
+			http.Error(w, "Only enrolled students can see the sessions", http.StatusForbidden)
+			return
+		}
+	}
+
+	sessions, err := s.db.GetCohortSessions(cohort.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) createLiveSession(w http.ResponseWriter, r *http.Request) {
+	cohort, ok := s.managedCohort(w, r)
+	if !ok {
+		return
+	}
+
+	var request LiveSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	session, err := validateLiveSession(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	session.CohortID = cohort.ID
+
+	created, err := s.db.CreateLiveSession(session)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// managedSession loads the live session in the id path variable and checks
+// the caller may edit its course.
+func (s *Server) managedSession(w http.ResponseWriter, r *http.Request) (*model.LiveSession, bool) {
+	sessionID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	session, err := s.db.GetLiveSession(sessionID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, false
+	}
+
+	cohort, err := s.db.GetCohort(session.CohortID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, false
+	}
+
+	course, err := s.db.GetCourseByID(cohort.CourseID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, false
+	}
+
+	if !canManage(claimsFromContext(r.Context()), course) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return session, true
+}
+
+func (s *Server) updateLiveSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.managedSession(w, r)
+	if !ok {
+		return
+	}
+
+	var request LiveSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changed, err := validateLiveSession(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	changed.ID = session.ID
+
+	updated, err := s.db.UpdateLiveSession(changed)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) deleteLiveSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := s.managedSession(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteLiveSession(session.ID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getMyCohorts(w http.ResponseWriter, r *http.Request) {
+	cohorts, err := s.db.GetUserCohorts(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cohorts)
+}
+
+// randomToken returns size random bytes hex encoded.
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// getMyCalendar returns the address of the caller's .ics feed, creating its
+// secret token on first use.
+func (s *Server) getMyCalendar(w http.ResponseWriter, r *http.Request) {
+	token, err := randomToken(20)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token, err = s.db.EnsureCalendarToken(claimsFromContext(r.Context()).UserID, token)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CalendarResponse{URL: fmt.Sprintf("%s/calendar/%s.ics", s.publicURL, token)})
+}
+
+// getCalendarFeed serves a student's live sessions as iCalendar. The token
+// in the path stands in for authentication.
+func (s *Server) getCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.db.GetUserIDByCalendarToken(mux.Vars(r)["token"])
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	sessions, err := s.db.GetUserSessions(userID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	domain := "localhost"
+	if public, err := url.Parse(s.publicURL); err == nil && public.Hostname() != "" {
+		domain = public.Hostname()
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if _, err := w.Write([]byte(calendar.Feed("Escola do Jogo", domain, sessions, time.Now()))); err != nil {
+		log.Error("Failed to write calendar:", err)
+	}
+}
+
+// sendSessionReminders emails the active students of every session starting
+// within lead, in their own time zone. Each session is reminded once.
+func (s *Server) sendSessionReminders(lead time.Duration) func(context.Context) error {
+	return func(_ context.Context) error {
+		now := time.Now()
+		reminders, err := s.db.GetSessionReminders(now, now.Add(lead))
+		if err != nil {
+			return err
+		}
+
+		var sessionIDs []int
+		for _, reminder := range reminders {
+			if len(sessionIDs) == 0 || sessionIDs[len(sessionIDs)-1] != reminder.SessionID {
+				sessionIDs = append(sessionIDs, reminder.SessionID)
+			}
+
+			location, err := time.LoadLocation(reminder.TimeZone)
+			if err != nil {
+				location = time.UTC
+			}
+
+			startsAt := reminder.StartsAt.In(location).Format("02/01/2006 às 15:04 MST")
+			if err := s.sender.SendLiveSessionReminderEmail(reminder.Email, reminder.Title, startsAt, reminder.MeetingURL); err != nil {
+				log.Errorf("reminding user %d of session %d: %v", reminder.UserID, reminder.SessionID, err)
+			}
+		}
+
+		for _, sessionID := range sessionIDs {
+			if err := s.db.MarkSessionReminded(sessionID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -4,16 +4,57 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ardanlabs/conf"
+	"time"
 )
 
 type Config struct {
 	Port            string `conf:"default:8080,env:PORT"`
 	DBCon           string `conf:"default:user=ps_user password=ps_password dbname=backend sslmode=disable host=localhost,env:DB_CONN"`
-	JWTKey          string `conf:"default:your_secret_key,env:JWT_KEY"`
 	NewRelicAppName string `conf:"default:game-student-go,env:NEW_RELIC_APP_NAME"`
 	NewRelicLicense string `conf:"env:NEW_RELIC_LICENSE"`
 	SendgridAPIKey  string `conf:"env:SENDGRID_API_KEY"`
 	StripeKey       string `conf:"env:STRIPE_SECRET_KEY"`
+	PublicURL       string `conf:"default:http://localhost:8080,env:PUBLIC_URL"`
+	StorageDir      string `conf:"default:./uploads,env:STORAGE_DIR"`
+
+	// JWTKeysDir holds the token signing keys, and JWTSigningKeyID names
+	// the one that signs when there are several. Without a directory a
+	// throwaway key is made at startup.
+	JWTKeysDir      string `conf:"env:JWT_KEYS_DIR"`
+	JWTSigningKeyID string `conf:"env:JWT_SIGNING_KEY_ID"`
+
+	LeaderboardRefreshInterval time.Duration `conf:"default:5m,env:LEADERBOARD_REFRESH_INTERVAL"`
+	StreakReminderInterval     time.Duration `conf:"default:1h,env:STREAK_REMINDER_INTERVAL"`
+	StreakReminderHour         int           `conf:"default:19,env:STREAK_REMINDER_HOUR"`
+	PublishInterval            time.Duration `conf:"default:1m,env:PUBLISH_INTERVAL"`
+	SessionReminderInterval    time.Duration `conf:"default:5m,env:SESSION_REMINDER_INTERVAL"`
+	SessionReminderLead        time.Duration `conf:"default:1h,env:SESSION_REMINDER_LEAD"`
+	EnrollmentExpiryInterval   time.Duration `conf:"default:5m,env:ENROLLMENT_EXPIRY_INTERVAL"`
+	PendingEnrollmentTTL       time.Duration `conf:"default:1h,env:PENDING_ENROLLMENT_TTL"`
+	JamResultsInterval         time.Duration `conf:"default:1m,env:JAM_RESULTS_INTERVAL"`
+	DataExportInterval         time.Duration `conf:"default:1m,env:DATA_EXPORT_INTERVAL"`
+	DataExportTTL              time.Duration `conf:"default:168h,env:DATA_EXPORT_TTL"`
+
+	// The SigninIP limits apply to all accounts signing in from one IP
+	// address, which may be shared by many people, hence their higher
+	// defaults.
+	SigninFreeAttempts       int           `conf:"default:3,env:SIGNIN_FREE_ATTEMPTS"`
+	SigninLockoutThreshold   int           `conf:"default:10,env:SIGNIN_LOCKOUT_THRESHOLD"`
+	SigninIPFreeAttempts     int           `conf:"default:20,env:SIGNIN_IP_FREE_ATTEMPTS"`
+	SigninIPLockoutThreshold int           `conf:"default:100,env:SIGNIN_IP_LOCKOUT_THRESHOLD"`
+	SigninLockoutDuration    time.Duration `conf:"default:15m,env:SIGNIN_LOCKOUT_DURATION"`
+	SigninCleanupInterval    time.Duration `conf:"default:1h,env:SIGNIN_CLEANUP_INTERVAL"`
+
+	// OAuthCompleteURL is the page of the web app that sign ins finished at
+	// a provider are sent back to, with a one-time code in the fragment.
+	OAuthCompleteURL   string `conf:"default:http://localhost:3000/auth/complete,env:OAUTH_COMPLETE_URL"`
+	GoogleClientID     string `conf:"env:GOOGLE_CLIENT_ID"`
+	GoogleClientSecret string `conf:"env:GOOGLE_CLIENT_SECRET,noprint"`
+	GoogleIssuer       string `conf:"default:https://accounts.google.com,env:GOOGLE_ISSUER"`
+	GitHubClientID     string `conf:"env:GITHUB_CLIENT_ID"`
+	GitHubClientSecret string `conf:"env:GITHUB_CLIENT_SECRET,noprint"`
+	GitHubURL          string `conf:"default:https://github.com,env:GITHUB_URL"`
+	GitHubAPIURL       string `conf:"default:https://api.github.com,env:GITHUB_API_URL"`
 }
 
 func ReadConfig() (*Config, error) {
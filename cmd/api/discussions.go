@@ -0,0 +1,559 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/listing"
+	"game-student-go/internal/model"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxQuestionTitleLength = 255
+	maxPostLength          = 10000
+)
+
+// discussionAccess tells whether the caller may see the discussions of the
+// training, which they may while they can see the training, and whether they
+// moderate them: admins and the instructors of its course do.
+func (s *Server) discussionAccess(claims *JWTClaims, trainingID int) (bool, bool, error) {
+	training, err := s.db.GetTrainingByID(trainingID)
+	if err != nil {
+		return false, false, err
+	}
+
+	course, err := s.db.GetCourseByID(training.CourseID)
+	if err != nil {
+		return false, false, err
+	}
+
+	return canSeeTraining(claims, training, course), canManage(claims, course), nil
+}
+
+func validatePost(body string) error {
+	if body == "" || len(body) > maxPostLength {
+		return fmt.Errorf("body must have between 1 and %d characters", maxPostLength)
+	}
+
+	return nil
+}
+
+// getTrainingQuestions lists the questions about a training one page at a
+// time, newest first unless ?sort= says otherwise.
+func (s *Server) getTrainingQuestions(w http.ResponseWriter, r *http.Request) {
+	training, course, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	page, err := listing.Parse(query, database.QuestionSorts...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("sort") == "" {
+		page.Desc = true
+	}
+
+	moderator := canManage(claimsFromContext(r.Context()), course)
+	questions, err := s.db.GetTrainingQuestions(training.ID, page, moderator)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listing.NewPage(questions, page.Limit, func(question model.Question) listing.Cursor {
+		if page.Sort == "upvotes" {
+			return listing.Cursor{Value: strconv.Itoa(question.Upvotes), ID: question.ID}
+		}
+		return listing.Cursor{Value: question.CreatedAt.Format(time.RFC3339Nano), ID: question.ID}
+	}))
+}
+
+// askQuestion lets the students of the course, and its staff, ask about one
+// of its trainings.
+func (s *Server) askQuestion(w http.ResponseWriter, r *http.Request) {
+	training, course, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	claims := claimsFromContext(r.Context())
+	if !canManage(claims, course) {
+		enrolled, err := s.db.HasStartedCourse(claims.UserID, course.ID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		if !enrolled {
+			http.Error(w, "Only students enrolled in the course can ask about it", http.StatusForbidden)
+			return
+		}
+	}
+
+	var request QuestionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	title, body, err := validateQuestion(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	question, err := s.db.CreateQuestion(model.Question{
+		TrainingID: training.ID,
+		UserID:     claims.UserID,
+		Title:      title,
+		Body:       body,
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, question)
+}
+
+func validateQuestion(request QuestionRequest) (string, string, error) {
+	title, body := strings.TrimSpace(request.Title), strings.TrimSpace(request.Body)
+	if title == "" || len(title) > maxQuestionTitleLength {
+		return "", "", fmt.Errorf("title must have between 1 and %d characters", maxQuestionTitleLength)
+	}
+
+	return title, body, validatePost(body)
+}
+
+// threadFor loads a question the caller may see, answering 404 for hidden
+// questions unless the caller moderates them.
+func (s *Server) threadFor(w http.ResponseWriter, r *http.Request) (*model.Question, bool, bool) {
+	questionID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false, false
+	}
+
+	question, err := s.db.GetQuestion(questionID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, false, false
+	}
+
+	visible, moderator, err := s.discussionAccess(claimsFromContext(r.Context()), question.TrainingID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, false, false
+	}
+
+	if !visible || question.Hidden && !moderator {
+		http.Error(w, fmt.Sprintf("no question found with id %d", questionID), http.StatusNotFound)
+		return nil, false, false
+	}
+
+	return question, moderator, true
+}
+
+func (s *Server) getThread(w http.ResponseWriter, r *http.Request) {
+	question, moderator, ok := s.threadFor(w, r)
+	if !ok {
+		return
+	}
+
+	answers, err := s.db.GetAnswers(question.ID, moderator)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, model.Thread{Question: *question, Answers: answers})
+}
+
+func (s *Server) updateQuestion(w http.ResponseWriter, r *http.Request) {
+	question, _, ok := s.threadFor(w, r)
+	if !ok {
+		return
+	}
+
+	if question.UserID != claimsFromContext(r.Context()).UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if question.Locked {
+		http.Error(w, "The question is locked", http.StatusConflict)
+		return
+	}
+
+	var request QuestionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	title, body, err := validateQuestion(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	question, err = s.db.UpdateQuestion(question.ID, title, body)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, question)
+}
+
+// deleteQuestion removes a question and its answers. Authors may delete
+// their own questions and moderators any of them.
+func (s *Server) deleteQuestion(w http.ResponseWriter, r *http.Request) {
+	question, moderator, ok := s.threadFor(w, r)
+	if !ok {
+		return
+	}
+
+	if question.UserID != claimsFromContext(r.Context()).UserID && !moderator {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := s.db.DeleteQuestion(question.ID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) answerQuestion(w http.ResponseWriter, r *http.Request) {
+	question, _, ok := s.threadFor(w, r)
+	if !ok {
+		return
+	}
+
+	if question.Locked {
+		http.Error(w, "The question is locked", http.StatusConflict)
+		return
+	}
+
+	var request AnswerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := strings.TrimSpace(request.Body)
+	if err := validatePost(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var parent *model.Answer
+	if request.ParentID != nil {
+		var err error
+		parent, err = s.db.GetAnswer(*request.ParentID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		if parent.QuestionID != question.ID {
+			http.Error(w, "parent_id must be an answer to the same question", http.StatusBadRequest)
+			return
+		}
+	}
+
+	answer, err := s.db.CreateAnswer(model.Answer{
+		QuestionID: question.ID,
+		ParentID:   request.ParentID,
+		UserID:     claimsFromContext(r.Context()).UserID,
+		Body:       body,
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	s.notifyReply(question, parent, answer)
+
+	writeJSON(w, http.StatusCreated, answer)
+}
+
+// notifyReply emails the question author, and the author of the answer
+// replied to, about a new answer. Nobody is told about their own posts.
+func (s *Server) notifyReply(question *model.Question, parent *model.Answer, answer *model.Answer) {
+	recipients := []int{question.UserID}
+	if parent != nil && parent.UserID != question.UserID {
+		recipients = append(recipients, parent.UserID)
+	}
+
+	threadURL := fmt.Sprintf("%s/questions/%d", s.publicURL, question.ID)
+	for _, userID := range recipients {
+		if userID == answer.UserID {
+			continue
+		}
+
+		user, err := s.db.GetUserByID(userID)
+		if err != nil {
+			log.Errorf("loading user to notify reply: %v", err)
+			continue
+		}
+
+		s.sendInBackground("sending question reply email", func() error {
+			return s.sender.SendQuestionReplyEmail(user.Email, question.Title, threadURL)
+		})
+	}
+}
+
+// answerFor loads an answer along with its question, answering 404 when
+// the caller may not see either.
+func (s *Server) answerFor(w http.ResponseWriter, r *http.Request) (*model.Answer, *model.Question, bool, bool) {
+	answerID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false, false
+	}
+
+	answer, err := s.db.GetAnswer(answerID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, false, false
+	}
+
+	question, err := s.db.GetQuestion(answer.QuestionID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, false, false
+	}
+
+	visible, moderator, err := s.discussionAccess(claimsFromContext(r.Context()), question.TrainingID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, false, false
+	}
+
+	if !visible || (answer.Hidden || question.Hidden) && !moderator {
+		http.Error(w, fmt.Sprintf("no answer found with id %d", answerID), http.StatusNotFound)
+		return nil, nil, false, false
+	}
+
+	return answer, question, moderator, true
+}
+
+func (s *Server) updateAnswer(w http.ResponseWriter, r *http.Request) {
+	answer, question, _, ok := s.answerFor(w, r)
+	if !ok {
+		return
+	}
+
+	if answer.UserID != claimsFromContext(r.Context()).UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if question.Locked {
+		http.Error(w, "The question is locked", http.StatusConflict)
+		return
+	}
+
+	var request AnswerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := strings.TrimSpace(request.Body)
+	if err := validatePost(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	answer, err := s.db.UpdateAnswer(answer.ID, body)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, answer)
+}
+
+func (s *Server) deleteAnswer(w http.ResponseWriter, r *http.Request) {
+	answer, _, moderator, ok := s.answerFor(w, r)
+	if !ok {
+		return
+	}
+
+	if answer.UserID != claimsFromContext(r.Context()).UserID && !moderator {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := s.db.DeleteAnswer(answer.ID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) voteQuestion(up bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		question, _, ok := s.threadFor(w, r)
+		if !ok {
+			return
+		}
+
+		question, err := s.db.SetQuestionVote(question.ID, claimsFromContext(r.Context()).UserID, up)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, question)
+	}
+}
+
+func (s *Server) voteAnswer(up bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		answer, _, _, ok := s.answerFor(w, r)
+		if !ok {
+			return
+		}
+
+		answer, err := s.db.SetAnswerVote(answer.ID, claimsFromContext(r.Context()).UserID, up)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, answer)
+	}
+}
+
+// acceptAnswer lets an instructor of the course mark the answer that solves
+// the question. A null answer_id clears it.
+func (s *Server) acceptAnswer(w http.ResponseWriter, r *http.Request) {
+	question, moderator, ok := s.threadFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !moderator {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var request AcceptAnswerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.AnswerID != nil {
+		answer, err := s.db.GetAnswer(*request.AnswerID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		if answer.QuestionID != question.ID {
+			http.Error(w, "answer_id must be an answer to this question", http.StatusBadRequest)
+			return
+		}
+	}
+
+	question, err := s.db.AcceptAnswer(question.ID, request.AnswerID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, question)
+}
+
+func (s *Server) moderateQuestion(w http.ResponseWriter, r *http.Request) {
+	question, moderator, ok := s.threadFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !moderator {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var request ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Hidden == nil && request.Locked == nil {
+		http.Error(w, "hidden or locked is required", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if request.Hidden != nil {
+		if question, err = s.db.SetQuestionHidden(question.ID, *request.Hidden); err != nil {
+			writeDBError(w, err)
+			return
+		}
+	}
+
+	if request.Locked != nil {
+		if question, err = s.db.SetQuestionLocked(question.ID, *request.Locked); err != nil {
+			writeDBError(w, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, question)
+}
+
+func (s *Server) moderateAnswer(w http.ResponseWriter, r *http.Request) {
+	answer, _, moderator, ok := s.answerFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !moderator {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var request ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Hidden == nil {
+		http.Error(w, "hidden is required", http.StatusBadRequest)
+		return
+	}
+
+	if request.Locked != nil {
+		http.Error(w, "answers cannot be locked, lock the question instead", http.StatusBadRequest)
+		return
+	}
+
+	answer, err := s.db.SetAnswerHidden(answer.ID, *request.Hidden)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, answer)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/model"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// activityByReason maps XP reasons that come from the student studying to
+// the daily activity they count as.
+var activityByReason = map[string]string{
+	gamification.ReasonTrainingCompleted: model.ActivityTrainingCompleted,
+	gamification.ReasonQuizPassed:        model.ActivityQuizPassed,
+}
+
+// grantXP rewards the user for an achievement, records it as learning
+// activity and checks whether it unlocked any badge. Achievements already
+// rewarded before give no XP but still count as studying that day.
+// Failures are logged only, the achievement itself already happened.
+func (s *Server) grantXP(userID int, reason string, sourceID, courseID int) {
+	kind, studied := activityByReason[reason]
+	if studied {
+		s.recordActivity(userID, kind)
+	}
+
+	granted, err := s.db.GrantXP(model.XPGrant{
+		UserID:   userID,
+		Amount:   gamification.XPFor(reason),
+		Reason:   reason,
+		SourceID: sourceID,
+		CourseID: courseID,
+	})
+	if err != nil {
+		log.Errorf("granting %s xp to user %d: %v", reason, userID, err)
+		return
+	}
+
+	if granted || studied {
+		s.evaluateBadges(userID)
+	}
+}
+
+func (s *Server) evaluateBadges(userID int) {
+	courses, err := s.db.CountCertificatesByUser(userID)
+	if err != nil {
+		log.Errorf("loading badge stats: %v", err)
+		return
+	}
+
+	activity, err := s.db.GetActivity(userID, time.Time{})
+	if err != nil {
+		log.Errorf("loading badge stats: %v", err)
+		return
+	}
+
+	stats := gamification.Stats{CoursesCompleted: courses}
+	for _, day := range activity {
+		stats.ActiveDays = append(stats.ActiveDays, day.Date)
+	}
+
+	for _, badge := range gamification.EarnedBadges(stats) {
+		if err := s.db.AwardBadge(userID, badge); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func (s *Server) getMyXP(w http.ResponseWriter, r *http.Request) {
+	total, err := s.db.GetUserXP(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, gamification.LevelFor(total))
+}
+
+func (s *Server) getMyXPLedger(w http.ResponseWriter, r *http.Request) {
+	grants, err := s.db.GetXPLedger(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, grants)
+}
+
+func (s *Server) getMyBadges(w http.ResponseWriter, r *http.Request) {
+	awarded, err := s.db.GetUserBadges(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	badges := []BadgeResponse{}
+	for _, userBadge := range awarded {
+		badge, ok := gamification.BadgeByCode(userBadge.Badge)
+		if !ok {
+			// Badge definitions that were removed from the code are hidden
+			continue
+		}
+		badges = append(badges, BadgeResponse{Badge: badge, AwardedAt: userBadge.AwardedAt})
+	}
+
+	writeJSON(w, http.StatusOK, badges)
+}
+
+func (s *Server) recomputeXP(w http.ResponseWriter, _ *http.Request) {
+	if err := s.db.RecomputeXP(); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"game-student-go/internal/listing"
+	"game-student-go/internal/model"
+	"net/http"
+	"strings"
+)
+
+const maxSocialLinks = 10
+
+func decodeInstructor(r *http.Request) (model.Instructor, error) {
+	var request InstructorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return model.Instructor{}, err
+	}
+
+	instructor := model.Instructor{
+		DisplayName:   strings.TrimSpace(request.DisplayName),
+		Bio:           strings.TrimSpace(request.Bio),
+		AvatarURL:     request.AvatarURL,
+		SocialLinks:   map[string]string{},
+		PayoutAccount: strings.TrimSpace(request.PayoutAccount),
+	}
+
+	if instructor.DisplayName == "" {
+		return model.Instructor{}, errors.New("display_name is required")
+	}
+
+	if instructor.AvatarURL != "" {
+		if err := validateWebURL("avatar_url", instructor.AvatarURL); err != nil {
+			return model.Instructor{}, err
+		}
+	}
+
+	if len(request.SocialLinks) > maxSocialLinks {
+		return model.Instructor{}, errors.New("too many social links")
+	}
+
+	for network, link := range request.SocialLinks {
+		network = strings.ToLower(strings.TrimSpace(network))
+		if network == "" {
+			return model.Instructor{}, errors.New("social links need a network name")
+		}
+		if err := validateWebURL(network, link); err != nil {
+			return model.Instructor{}, err
+		}
+		instructor.SocialLinks[network] = link
+	}
+
+	return instructor, nil
+}
+
+// getInstructorPage shows an instructor's public profile along with the
+// first page of their published courses. Later pages come from /courses
+// with the same ?instructor= filter.
+func (s *Server) getInstructorPage(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	instructor, err := s.db.GetInstructor(id)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	page := listing.Params{Limit: listing.DefaultLimit, Sort: "name"}
+	courses, err := s.db.GetCourses(model.CourseFilter{InstructorID: id}, page)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, InstructorPageResponse{
+		Instructor: instructor.Public(),
+		Courses: listing.NewPage(courses, page.Limit, func(course model.Course) listing.Cursor {
+			return listing.Cursor{Value: course.Name, ID: course.ID}
+		}),
+	})
+}
+
+func (s *Server) getMyInstructorProfile(w http.ResponseWriter, r *http.Request) {
+	instructor, err := s.db.GetInstructor(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, instructor)
+}
+
+func (s *Server) updateMyInstructorProfile(w http.ResponseWriter, r *http.Request) {
+	instructor, err := decodeInstructor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	instructor.UserID = claimsFromContext(r.Context()).UserID
+
+	updated, err := s.db.UpdateInstructor(instructor)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// createInstructor opens an instructor profile for a user. The user's
+// current token keeps its old role until they sign in again.
+func (s *Server) createInstructor(w http.ResponseWriter, r *http.Request) {
+	var request CreateInstructorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	instructor, err := s.db.CreateInstructor(request.UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, instructor)
+}
+
+func (s *Server) setCourseInstructors(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var request CourseInstructorsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.SetCourseInstructors(courseID, request.InstructorIDs); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, course)
+}
@@ -0,0 +1,791 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/jams"
+	"game-student-go/internal/model"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxJamTitleLength    = 255
+	maxTeamNameLength    = 100
+	maxScreenshotBytes   = 5 << 20
+	maxJamCriteria       = 10
+	maxJamCriterionChars = 100
+)
+
+// screenshotTypes maps the accepted screenshot formats to their extension.
+var screenshotTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var (
+	errNotScreenshot      = errors.New("screenshots must be png, jpeg, gif or webp images")
+	errScreenshotTooLarge = fmt.Errorf("screenshots must be smaller than %d MB", maxScreenshotBytes>>20)
+)
+
+// presentJam fills in the jam's phase and hides its theme until the reveal,
+// except from admins.
+func presentJam(claims *JWTClaims, jam *model.GameJam, now time.Time) {
+	jam.Phase = jams.Phase(*jam, now)
+	if jam.Phase == jams.PhaseUpcoming && !hasRole(claims, model.RoleAdmin) {
+		jam.Theme = ""
+	}
+}
+
+// jamFor loads the jam in the named path variable.
+func (s *Server) jamFor(w http.ResponseWriter, r *http.Request, name string) (*model.GameJam, bool) {
+	jamID, err := pathID(r, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	jam, err := s.db.GetJam(jamID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, false
+	}
+
+	presentJam(claimsFromContext(r.Context()), jam, time.Now())
+	return jam, true
+}
+
+// teamFor loads the team in the id path variable and its jam.
+func (s *Server) teamFor(w http.ResponseWriter, r *http.Request) (*model.JamTeam, *model.GameJam, bool) {
+	teamID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	team, err := s.db.GetJamTeam(teamID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, false
+	}
+
+	jam, err := s.db.GetJam(team.JamID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, false
+	}
+	presentJam(claimsFromContext(r.Context()), jam, time.Now())
+
+	return team, jam, true
+}
+
+// entryFor loads the entry in the id path variable, its team and its jam,
+// answering 404 while entries are private to anyone outside the team.
+func (s *Server) entryFor(w http.ResponseWriter, r *http.Request) (*model.JamEntry, *model.JamTeam, *model.GameJam, bool) {
+	entryID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+
+	entry, err := s.db.GetJamEntry(entryID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, nil, false
+	}
+
+	team, err := s.db.GetJamTeam(entry.TeamID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, nil, false
+	}
+
+	jam, err := s.db.GetJam(entry.JamID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, nil, nil, false
+	}
+
+	claims := claimsFromContext(r.Context())
+	presentJam(claims, jam, time.Now())
+	if !jams.EntriesPublic(jam.Phase) && !canSeeTeamEntry(claims, team) {
+		http.Error(w, fmt.Sprintf("no jam entry found with id %d", entryID), http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+
+	s.presentEntry(entry)
+	return entry, team, jam, true
+}
+
+func canSeeTeamEntry(claims *JWTClaims, team *model.JamTeam) bool {
+	return claims != nil && (hasRole(claims, model.RoleAdmin) || team.HasMember(claims.UserID))
+}
+
+// presentEntry turns the stored screenshot keys into download addresses.
+func (s *Server) presentEntry(entry *model.JamEntry) {
+	entry.Screenshots = make([]string, len(entry.ScreenshotKeys))
+	for i := range entry.ScreenshotKeys {
+		entry.Screenshots[i] = fmt.Sprintf("%s/entries/%d/screenshots/%d", s.publicURL, entry.ID, i)
+	}
+}
+
+func validateJam(request JamRequest) (model.GameJam, error) {
+	jam := model.GameJam{
+		Title:              strings.TrimSpace(request.Title),
+		Description:        strings.TrimSpace(request.Description),
+		Theme:              strings.TrimSpace(request.Theme),
+		ThemeRevealAt:      request.ThemeRevealAt,
+		SubmissionDeadline: request.SubmissionDeadline,
+		VotingEndsAt:       request.VotingEndsAt,
+		MaxTeamSize:        request.MaxTeamSize,
+	}
+
+	if jam.Title == "" || len(jam.Title) > maxJamTitleLength {
+		return jam, fmt.Errorf("title must have between 1 and %d characters", maxJamTitleLength)
+	}
+	if jam.Theme == "" || len(jam.Theme) > maxJamTitleLength {
+		return jam, fmt.Errorf("theme must have between 1 and %d characters", maxJamTitleLength)
+	}
+	if jam.ThemeRevealAt.IsZero() || !jam.SubmissionDeadline.After(jam.ThemeRevealAt) || !jam.VotingEndsAt.After(jam.SubmissionDeadline) {
+		return jam, errors.New("theme_reveal_at, submission_deadline and voting_ends_at must come in that order")
+	}
+	if jam.MaxTeamSize < 1 {
+		return jam, errors.New("max_team_size must be at least 1")
+	}
+	if len(request.Criteria) == 0 || len(request.Criteria) > maxJamCriteria {
+		return jam, fmt.Errorf("a jam needs between 1 and %d criteria", maxJamCriteria)
+	}
+
+	seen := map[string]bool{}
+	for _, name := range request.Criteria {
+		name = strings.TrimSpace(name)
+		if name == "" || len(name) > maxJamCriterionChars || seen[name] {
+			return jam, fmt.Errorf("criteria must be unique and have between 1 and %d characters", maxJamCriterionChars)
+		}
+		seen[name] = true
+		jam.Criteria = append(jam.Criteria, model.JamCriterion{Name: name})
+	}
+
+	return jam, nil
+}
+
+func (s *Server) listJams(w http.ResponseWriter, r *http.Request) {
+	all, err := s.db.GetJams()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	claims, now := claimsFromContext(r.Context()), time.Now()
+	for i := range all {
+		presentJam(claims, &all[i], now)
+	}
+
+	writeJSON(w, http.StatusOK, all)
+}
+
+func (s *Server) getJam(w http.ResponseWriter, r *http.Request) {
+	jam, ok := s.jamFor(w, r, "id")
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, jam)
+}
+
+func (s *Server) createJam(w http.ResponseWriter, r *http.Request) {
+	var request JamRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jam, err := validateJam(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.db.CreateJam(jam)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	presentJam(claimsFromContext(r.Context()), created, time.Now())
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// updateJam changes a jam until its results are out.
+func (s *Server) updateJam(w http.ResponseWriter, r *http.Request) {
+	jam, ok := s.jamFor(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if jam.Phase == jams.PhaseFinished {
+		http.Error(w, "The jam results are already published", http.StatusConflict)
+		return
+	}
+
+	var request JamRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changed, err := validateJam(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	changed.ID = jam.ID
+
+	updated, err := s.db.UpdateJam(changed)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	presentJam(claimsFromContext(r.Context()), updated, time.Now())
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// listJamTeams lists the teams of a jam. Invite codes are only shown to the
+// members of each team.
+func (s *Server) listJamTeams(w http.ResponseWriter, r *http.Request) {
+	jam, ok := s.jamFor(w, r, "id")
+	if !ok {
+		return
+	}
+
+	teams, err := s.db.GetJamTeams(jam.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	claims := claimsFromContext(r.Context())
+	for i := range teams {
+		if claims == nil || !teams[i].HasMember(claims.UserID) {
+			teams[i].InviteCode = ""
+		}
+	}
+
+	writeJSON(w, http.StatusOK, teams)
+}
+
+func (s *Server) createJamTeam(w http.ResponseWriter, r *http.Request) {
+	jam, ok := s.jamFor(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !jams.TeamsOpen(jam.Phase) {
+		http.Error(w, "Teams can no longer be changed", http.StatusConflict)
+		return
+	}
+
+	var request JamTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxTeamNameLength {
+		http.Error(w, fmt.Sprintf("name must have between 1 and %d characters", maxTeamNameLength), http.StatusBadRequest)
+		return
+	}
+
+	code, err := randomToken(8)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	team, err := s.db.CreateJamTeam(model.JamTeam{JamID: jam.ID, Name: name, InviteCode: strings.ToUpper(code)},
+		claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, team)
+}
+
+func (s *Server) joinJamTeam(w http.ResponseWriter, r *http.Request) {
+	jam, ok := s.jamFor(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !jams.TeamsOpen(jam.Phase) {
+		http.Error(w, "Teams can no longer be changed", http.StatusConflict)
+		return
+	}
+
+	var request JoinJamTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(request.InviteCode))
+	team, err := s.db.JoinJamTeam(jam.ID, code, claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, team)
+}
+
+// inviteToJamTeam emails the team's invite code. Only members can invite.
+func (s *Server) inviteToJamTeam(w http.ResponseWriter, r *http.Request) {
+	team, jam, ok := s.teamFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !team.HasMember(claimsFromContext(r.Context()).UserID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if !jams.TeamsOpen(jam.Phase) {
+		http.Error(w, "Teams can no longer be changed", http.StatusConflict)
+		return
+	}
+
+	if len(team.Members) >= jam.MaxTeamSize {
+		http.Error(w, fmt.Sprintf("team %d is full", team.ID), http.StatusConflict)
+		return
+	}
+
+	var request JamInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	address, err := mail.ParseAddress(request.Email)
+	if err != nil {
+		http.Error(w, "email must be a valid address", http.StatusBadRequest)
+		return
+	}
+
+	jamURL := fmt.Sprintf("%s/jams/%d", s.publicURL, jam.ID)
+	if err := s.sender.SendJamInviteEmail(address.Address, jam.Title, team.Name, team.InviteCode, jamURL); err != nil {
+		log.Errorf("inviting to team %d: %v", team.ID, err)
+		http.Error(w, "Failed to send the invite", http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) leaveJamTeam(w http.ResponseWriter, r *http.Request) {
+	team, jam, ok := s.teamFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !jams.TeamsOpen(jam.Phase) {
+		http.Error(w, "Teams can no longer be changed", http.StatusConflict)
+		return
+	}
+
+	if err := s.db.LeaveJamTeam(team.ID, claimsFromContext(r.Context()).UserID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseJamEntryRequest accepts either a JSON body or a multipart form with
+// title, description and build_url fields, an optional "file" zip build and
+// up to MaxScreenshots "screenshots" images. The caller closes the file.
+func parseJamEntryRequest(w http.ResponseWriter, r *http.Request) (JamEntryRequest, multipart.File, []*multipart.FileHeader, error) {
+	var request JamEntryRequest
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err := json.NewDecoder(r.Body).Decode(&request)
+		return request, nil, nil, err
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentBytes+jams.MaxScreenshots*maxScreenshotBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return request, nil, nil, fmt.Errorf("parsing upload: %w", err)
+	}
+
+	request.Title = r.FormValue("title")
+	request.Description = r.FormValue("description")
+	request.BuildURL = r.FormValue("build_url")
+
+	screenshots := r.MultipartForm.File["screenshots"]
+	if len(screenshots) > jams.MaxScreenshots {
+		return request, nil, nil, fmt.Errorf("at most %d screenshots are accepted", jams.MaxScreenshots)
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return request, nil, screenshots, nil
+		}
+		return request, nil, nil, fmt.Errorf("reading upload: %w", err)
+	}
+
+	return request, file, screenshots, nil
+}
+
+// storeScreenshots checks and stores the screenshots uploaded with the entry
+// of a team, returning their keys. When one is refused the ones stored
+// before it are removed.
+func (s *Server) storeScreenshots(jamID, teamID int, headers []*multipart.FileHeader) ([]string, error) {
+	keys := make([]string, 0, len(headers))
+	for _, header := range headers {
+		key, err := s.storeScreenshot(jamID, teamID, header)
+		if err != nil {
+			s.deleteUploads(keys)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (s *Server) storeScreenshot(jamID, teamID int, header *multipart.FileHeader) (string, error) {
+	if header.Size > maxScreenshotBytes {
+		return "", errScreenshotTooLarge
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return "", fmt.Errorf("reading screenshot: %w", err)
+	}
+	defer file.Close()
+
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	ext, ok := screenshotTypes[http.DetectContentType(sniff[:n])]
+	if !ok {
+		return "", errNotScreenshot
+	}
+
+	token, err := randomToken(8)
+	if err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("jams/%d/teams/%d/screenshot-%s%s", jamID, teamID, token, ext)
+	return key, s.store.Save(key, io.MultiReader(bytes.NewReader(sniff[:n]), file))
+}
+
+// submitJamEntry creates or replaces the team's entry while the jam runs. An
+// entry needs a build URL or an uploaded zip build; screenshots sent along
+// replace the previous ones.
+func (s *Server) submitJamEntry(w http.ResponseWriter, r *http.Request) {
+	team, jam, ok := s.teamFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !team.HasMember(claimsFromContext(r.Context()).UserID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if jam.Phase != jams.PhaseRunning {
+		http.Error(w, "The jam is not accepting entries", http.StatusConflict)
+		return
+	}
+
+	request, file, screenshots, err := parseJamEntryRequest(w, r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if file != nil {
+		defer file.Close()
+	}
+
+	entry := model.JamEntry{
+		JamID:       jam.ID,
+		TeamID:      team.ID,
+		Title:       strings.TrimSpace(request.Title),
+		Description: strings.TrimSpace(request.Description),
+		BuildURL:    strings.TrimSpace(request.BuildURL),
+	}
+	if entry.Title == "" || len(entry.Title) > maxJamTitleLength {
+		http.Error(w, fmt.Sprintf("title must have between 1 and %d characters", maxJamTitleLength), http.StatusBadRequest)
+		return
+	}
+	if len(entry.Description) > maxPostLength {
+		http.Error(w, fmt.Sprintf("description must have at most %d characters", maxPostLength), http.StatusBadRequest)
+		return
+	}
+	if entry.BuildURL != "" {
+		if err := validateWebURL("build_url", entry.BuildURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	previous, err := s.db.GetTeamEntry(team.ID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		writeDBError(w, err)
+		return
+	}
+	if entry.BuildURL == "" && file == nil && (previous == nil || !previous.HasBuild) {
+		http.Error(w, "An entry needs a build_url or a zip build", http.StatusBadRequest)
+		return
+	}
+
+	// The uploads are checked and stored before the entry is written, so an
+	// entry never points at files that are not there.
+	if file != nil {
+		token, err := randomToken(8)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		entry.BuildKey = fmt.Sprintf("jams/%d/teams/%d/build-%s.zip", jam.ID, team.ID, token)
+		if err := s.saveZip(entry.BuildKey, file); err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	}
+
+	if len(screenshots) > 0 {
+		if entry.ScreenshotKeys, err = s.storeScreenshots(jam.ID, team.ID, screenshots); err != nil {
+			s.deleteUpload(entry.BuildKey)
+			writeUploadError(w, err)
+			return
+		}
+	}
+
+	saved, err := s.db.SaveJamEntry(entry)
+	if err != nil {
+		s.deleteUpload(entry.BuildKey)
+		s.deleteUploads(entry.ScreenshotKeys)
+		writeDBError(w, err)
+		return
+	}
+
+	if previous != nil {
+		if entry.BuildKey != "" {
+			s.deleteUpload(previous.BuildKey)
+		}
+		if entry.ScreenshotKeys != nil {
+			s.deleteUploads(previous.ScreenshotKeys)
+		}
+	}
+
+	s.presentEntry(saved)
+	writeJSON(w, http.StatusOK, saved)
+}
+
+func (s *Server) getTeamEntry(w http.ResponseWriter, r *http.Request) {
+	team, jam, ok := s.teamFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !jams.EntriesPublic(jam.Phase) && !canSeeTeamEntry(claimsFromContext(r.Context()), team) {
+		http.Error(w, fmt.Sprintf("team %d has no entry", team.ID), http.StatusNotFound)
+		return
+	}
+
+	entry, err := s.db.GetTeamEntry(team.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	s.presentEntry(entry)
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// listJamEntries lists the entries of a jam once submissions close, ranked
+// after the results are published.
+func (s *Server) listJamEntries(w http.ResponseWriter, r *http.Request) {
+	jam, ok := s.jamFor(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if !jams.EntriesPublic(jam.Phase) {
+		http.Error(w, "Entries are shown once submissions close", http.StatusForbidden)
+		return
+	}
+
+	entries, err := s.db.GetJamEntries(jam.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	for i := range entries {
+		s.presentEntry(&entries[i])
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) getJamResults(w http.ResponseWriter, r *http.Request) {
+	jam, ok := s.jamFor(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if jam.Phase != jams.PhaseFinished {
+		http.Error(w, "The results are not published yet", http.StatusNotFound)
+		return
+	}
+
+	entries, err := s.db.GetJamEntries(jam.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	for i := range entries {
+		s.presentEntry(&entries[i])
+	}
+
+	writeJSON(w, http.StatusOK, JamResultsResponse{Jam: *jam, Entries: entries})
+}
+
+func (s *Server) downloadJamBuild(w http.ResponseWriter, r *http.Request) {
+	entry, _, _, ok := s.entryFor(w, r)
+	if !ok {
+		return
+	}
+
+	if !entry.HasBuild {
+		http.Error(w, "Entry has no zip build", http.StatusNotFound)
+		return
+	}
+
+	file, err := s.store.Open(entry.BuildKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="jam-entry-%d.zip"`, entry.ID))
+	if _, err := io.Copy(w, file); err != nil {
+		log.Error("Failed to write jam build:", err)
+	}
+}
+
+func (s *Server) getJamScreenshot(w http.ResponseWriter, r *http.Request) {
+	entry, _, _, ok := s.entryFor(w, r)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil || index < 0 || index >= len(entry.ScreenshotKeys) {
+		http.Error(w, "Screenshot not found", http.StatusNotFound)
+		return
+	}
+
+	key := entry.ScreenshotKeys[index]
+	file, err := s.store.Open(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	if _, err := io.Copy(w, file); err != nil {
+		log.Error("Failed to write screenshot:", err)
+	}
+}
+
+// voteJamEntry records the caller's scores for an entry during the voting
+// window. Teams cannot vote on their own entry.
+func (s *Server) voteJamEntry(w http.ResponseWriter, r *http.Request) {
+	entry, team, jam, ok := s.entryFor(w, r)
+	if !ok {
+		return
+	}
+
+	if jam.Phase != jams.PhaseVoting {
+		http.Error(w, "Voting is closed", http.StatusConflict)
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	if team.HasMember(userID) {
+		http.Error(w, "Teams cannot vote on their own entry", http.StatusForbidden)
+		return
+	}
+
+	var request JamVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Every criterion needs a score, or entries would be ranked on
+	// different criteria by different voters.
+	for _, criterion := range jam.Criteria {
+		if _, ok := request.Scores[criterion.ID]; !ok {
+			http.Error(w, fmt.Sprintf("a score for %q is required", criterion.Name), http.StatusBadRequest)
+			return
+		}
+	}
+	if len(request.Scores) != len(jam.Criteria) {
+		http.Error(w, "scores may only be given for the criteria of the jam", http.StatusBadRequest)
+		return
+	}
+	for _, score := range request.Scores {
+		if score < jams.MinVoteScore || score > jams.MaxVoteScore {
+			http.Error(w, fmt.Sprintf("scores must be between %d and %d", jams.MinVoteScore, jams.MaxVoteScore), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.db.SaveJamVotes(entry.ID, userID, request.Scores); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishJamResults tallies the votes of the jams whose voting closed.
+func (s *Server) publishJamResults(_ context.Context) error {
+	published, err := s.db.PublishJamResults(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, jamID := range published {
+		log.Infof("Published the results of jam %d", jamID)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/model"
+	"game-student-go/internal/scheduler"
+	"time"
+)
+
+// RegisterJobs adds the server's background jobs to the scheduler.
+func (s *Server) RegisterJobs(jobs *scheduler.Scheduler, cfg *Config) {
+	jobs.Every("refresh leaderboards", cfg.LeaderboardRefreshInterval, s.refreshLeaderboards)
+	jobs.Every("streak reminders", cfg.StreakReminderInterval, s.sendStreakReminders(cfg.StreakReminderHour))
+	jobs.Every("publish scheduled content", cfg.PublishInterval, s.publishScheduled)
+	jobs.Every("live session reminders", cfg.SessionReminderInterval, s.sendSessionReminders(cfg.SessionReminderLead))
+	jobs.Every("expire pending enrollments", cfg.EnrollmentExpiryInterval, s.expireEnrollments(cfg.PendingEnrollmentTTL))
+	jobs.Every("publish jam results", cfg.JamResultsInterval, s.publishJamResults)
+	jobs.Every("build data exports", cfg.DataExportInterval, s.buildDataExports(cfg.DataExportTTL))
+	jobs.Every("clean sign in throttles", cfg.SigninCleanupInterval, s.cleanSigninThrottles)
+	jobs.Every("clean oauth states", cfg.SigninCleanupInterval, s.cleanOAuthStates)
+}
+
+// refreshLeaderboards rebuilds the all time rankings and the ones of the
+// running weekly season. Once a week is over its rankings are no longer
+// refreshed and stay around as that season's final results.
+func (s *Server) refreshLeaderboards(_ context.Context) error {
+	now := time.Now()
+	for _, period := range []string{model.PeriodAllTime, model.PeriodWeekly} {
+		if err := s.db.RefreshLeaderboards(period, gamification.SeasonStart(period, now)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
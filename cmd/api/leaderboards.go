@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/model"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLeaderboardLimit = 50
+	maxLeaderboardLimit     = 500
+)
+
+// getLeaderboard serves /leaderboards/{scope} where scope is global, course
+// or cohort. Course and cohort boards take their id in ?id=, and ?period=
+// picks between all_time (default) and the running weekly season.
+func (s *Server) getLeaderboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	board := model.Leaderboard{
+		Scope:  mux.Vars(r)["scope"],
+		Period: query.Get("period"),
+	}
+
+	if board.Period == "" {
+		board.Period = model.PeriodAllTime
+	}
+	if board.Period != model.PeriodAllTime && board.Period != model.PeriodWeekly {
+		http.Error(w, "period must be all_time or weekly", http.StatusBadRequest)
+		return
+	}
+
+	switch board.Scope {
+	case model.LeaderboardGlobal:
+	case model.LeaderboardCourse, model.LeaderboardCohort:
+		id, err := strconv.Atoi(query.Get("id"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("id is required for %s leaderboards", board.Scope), http.StatusBadRequest)
+			return
+		}
+		board.ScopeID = id
+	default:
+		http.Error(w, "Unknown leaderboard scope", http.StatusNotFound)
+		return
+	}
+
+	limit := defaultLeaderboardLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > maxLeaderboardLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	board.SeasonStart = gamification.SeasonStart(board.Period, time.Now())
+	entries, err := s.db.GetLeaderboard(board.Scope, board.ScopeID, board.Period, board.SeasonStart, limit)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	board.Entries = entries
+
+	me, err := s.db.GetLeaderboardEntry(board.Scope, board.ScopeID, board.Period, board.SeasonStart, claimsFromContext(r.Context()).UserID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		writeDBError(w, err)
+		return
+	}
+	board.Me = me
+
+	writeJSON(w, http.StatusOK, board)
+}
+
+func (s *Server) setLeaderboardOptOut(w http.ResponseWriter, r *http.Request) {
+	var request LeaderboardOptOutRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.SetLeaderboardOptOut(claimsFromContext(r.Context()).UserID, request.OptOut); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
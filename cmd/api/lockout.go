@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/lockout"
+	"game-student-go/internal/model"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dummyPasswordHash is compared against when the email is unknown, so those
+// sign ins take as long as a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// signinFailedMessage is the only answer to bad credentials, whether the
+// account exists or not.
+const signinFailedMessage = "Invalid email or password"
+
+// ConfigureLockout sets how failed sign ins are limited per account and
+// per IP address.
+func (s *Server) ConfigureLockout(cfg *Config) {
+	s.accountLockout = lockout.Policy{
+		FreeAttempts: cfg.SigninFreeAttempts,
+		MaxFailures:  cfg.SigninLockoutThreshold,
+		Duration:     cfg.SigninLockoutDuration,
+	}
+	s.ipLockout = lockout.Policy{
+		FreeAttempts: cfg.SigninIPFreeAttempts,
+		MaxFailures:  cfg.SigninIPLockoutThreshold,
+		Duration:     cfg.SigninLockoutDuration,
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (s *Server) lockoutPolicy(key string) lockout.Policy {
+	if strings.HasPrefix(key, "ip:") {
+		return s.ipLockout
+	}
+
+	return s.accountLockout
+}
+
+// beginSignin counts a sign in attempt against the account of email and
+// against ip before the credentials are checked, so that concurrent attempts
+// cannot all slip in under the limits. It answers 429 when the attempt must
+// wait, and tells whether it may go ahead. Attempts count as failures until
+// passwordAccepted or signinSucceeded settles them.
+func (s *Server) beginSignin(w http.ResponseWriter, email, ip string) bool {
+	now := time.Now()
+	var retryAt time.Time
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		before, err := s.db.RecordSigninAttempt(key, now, lockout.FailureWindow)
+		if err != nil {
+			log.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return false
+		}
+
+		at, locks := s.lockoutPolicy(key).Admit(*before, now)
+		if locks {
+			s.lockSignin(key, email, ip, at)
+		}
+		if at.After(retryAt) {
+			retryAt = at
+		}
+	}
+
+	if !retryAt.After(now) {
+		return true
+	}
+
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAt.Sub(now).Seconds()))))
+	http.Error(w, "Too many failed sign in attempts, try again later", http.StatusTooManyRequests)
+	return false
+}
+
+// lockSignin locks key out until the given time. The owner of an account
+// that gets locked is warned by email, if the account exists.
+func (s *Server) lockSignin(key, email, ip string, until time.Time) {
+	if err := s.db.LockSignin(key, until); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Warnf("Locked sign in for %s until %s", key, until.Format(time.RFC3339))
+
+	if key != accountKey(email) {
+		return
+	}
+
+	s.sendInBackground("warning of account lock", func() error {
+		user, err := s.db.GetUserByEmail(email)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		location, err := time.LoadLocation(user.TimeZone)
+		if err != nil {
+			location = time.UTC
+		}
+		return s.sender.SendAccountLockedEmail(user.Email, until.In(location).Format("02/01/2006 às 15:04 MST"), ip)
+	})
+}
+
+// passwordAccepted settles an attempt whose password was right. Accounts
+// with 2FA are not signed in until their code is right too, so until then
+// the failures of the account keep counting against wrong codes.
+func (s *Server) passwordAccepted(user model.User, ip string) {
+	if user.TwoFactorEnabled {
+		s.uncountSigninAttempt(ipKey(ip))
+		return
+	}
+
+	s.signinSucceeded(user.Email, ip)
+}
+
+// signinSucceeded forgets the failures of the account of email once it is
+// signed in. The address keeps its own, bar this attempt, so signing in to
+// one account does not make up for guessing at others.
+func (s *Server) signinSucceeded(email, ip string) {
+	if err := s.db.ClearSigninFailures(accountKey(email)); err != nil {
+		log.Error(err)
+	}
+	s.uncountSigninAttempt(ipKey(ip))
+}
+
+func (s *Server) uncountSigninAttempt(key string) {
+	if err := s.db.UncountSigninAttempt(key); err != nil {
+		log.Error(err)
+	}
+}
+
+// checkPassword looks up the account of email and checks its password,
+// spending the same time whether the account exists or not. The user is
+// returned even when the password is wrong, and is nil when unknown.
+func (s *Server) checkPassword(email, password string) (*model.User, bool, error) {
+	user, err := s.db.GetUserByEmail(email)
+	if errors.Is(err, database.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &user, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil, nil
+}
+
+// cleanSigninThrottles forgets the failures that no longer count.
+func (s *Server) cleanSigninThrottles(_ context.Context) error {
+	return s.db.DeleteStaleSigninThrottles(time.Now().Add(-lockout.FailureWindow))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"game-student-go/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The fake counts attempts without windows or locks, which is all the
+// handlers under test need to show what they count and forget.
+
+func (db *fakeDB) RecordSigninAttempt(key string, now time.Time, window time.Duration) (*model.SigninThrottle, error) {
+	if db.throttles == nil {
+		db.throttles = map[string]model.SigninThrottle{}
+	}
+	before := db.throttles[key]
+	before.Key = key
+	db.throttles[key] = model.SigninThrottle{Key: key, Failures: before.Failures + 1}
+	return &before, nil
+}
+
+func (db *fakeDB) UncountSigninAttempt(key string) error {
+	if throttle, ok := db.throttles[key]; ok && throttle.Failures > 0 {
+		throttle.Failures--
+		db.throttles[key] = throttle
+	}
+	return nil
+}
+
+func (db *fakeDB) ClearSigninFailures(key string) error {
+	delete(db.throttles, key)
+	return nil
+}
+
+func (db *fakeDB) CreateTwoFactorChallenge(userID int, tokenHash string, expiresAt time.Time) error {
+	if db.challenges == nil {
+		db.challenges = map[string]int{}
+	}
+	db.challenges[tokenHash] = userID
+	return nil
+}
+
+func signin(s *Server, email, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(SignInRequest{Email: email, Password: password})
+	w := httptest.NewRecorder()
+	s.Signin(w, httptest.NewRequest(http.MethodPost, "/signin", bytes.NewReader(body)))
+	return w
+}
+
+func TestSigninCountsFailures(t *testing.T) {
+	s, db := newOAuthServer(t, "ana@test.com")
+	s.accountLockout.FreeAttempts = 100
+	s.ipLockout.FreeAttempts = 100
+	account, ip := accountKey("ana@test.com"), ipKey("192.0.2.1")
+
+	assert.Equal(t, http.StatusUnauthorized, signin(s, "ana@test.com", "wrong password").Code)
+	assert.Equal(t, 1, db.throttles[account].Failures)
+	assert.Equal(t, 1, db.throttles[ip].Failures)
+
+	assert.Equal(t, http.StatusOK, signin(s, "ana@test.com", "password").Code)
+	assert.NotContains(t, db.throttles, account, "Signing in forgets the failures of the account")
+	assert.Equal(t, 1, db.throttles[ip].Failures, "The address keeps its failures")
+}
+
+func TestSigninKeepsFailuresUntilSecondFactor(t *testing.T) {
+	s, db := newOAuthServer(t, "ana@test.com")
+	s.accountLockout.FreeAttempts = 100
+	s.ipLockout.FreeAttempts = 100
+	ana := db.users[studentID]
+	ana.TwoFactorEnabled = true
+	db.users[studentID] = ana
+
+	// Wrong codes count against the account like wrong passwords
+	account, ip := accountKey("ana@test.com"), ipKey("192.0.2.1")
+	db.throttles = map[string]model.SigninThrottle{account: {Key: account, Failures: 4}}
+
+	w := signin(s, "ana@test.com", "password")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "challenge_token")
+	assert.Equal(t, 5, db.throttles[account].Failures, "The password alone does not forget wrong codes")
+	assert.Equal(t, 0, db.throttles[ip].Failures, "Right passwords do not count against the address")
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"game-student-go/internal/database"
+	"game-student-go/internal/jwtkeys"
 	"game-student-go/internal/notifications"
+	"game-student-go/internal/scheduler"
+	"game-student-go/internal/storage"
 	"github.com/newrelic/go-agent/v3/newrelic"
 	"github.com/sendgrid/sendgrid-go"
 	log "github.com/sirupsen/logrus"
 	"github.com/stripe/stripe-go"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	// Students pick their own time zone and the runtime image ships no zoneinfo
+	_ "time/tzdata"
 )
 
 func main() {
@@ -45,9 +54,59 @@ func main() {
 
 	stripe.Key = cfg.StripeKey
 
-	server := NewServer(port, db, cfg.JWTKey, metrics, emailSender)
+	store, err := storage.NewLocalStorage(cfg.StorageDir)
+	if err != nil {
+		log.Fatalf("creating storage: %v", err)
+	}
+
+	keys, err := loadSigningKeys(cfg)
+	if err != nil {
+		log.Fatalf("loading signing keys: %v", err)
+	}
+	log.Infof("signing tokens with key %s", keys.SigningKeyID())
+
+	server := NewServer(port, db, keys, metrics, emailSender, cfg.PublicURL, store)
+	server.ConfigureLockout(cfg)
+	server.ConfigureOAuth(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobs := scheduler.New()
+	server.RegisterJobs(jobs, cfg)
+	jobs.Start(ctx)
 
 	if err := server.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
 }
+
+// loadSigningKeys loads the keys tokens are signed with. A throwaway key
+// is only made up for servers running locally: anywhere else every restart
+// would sign everyone out, and replicas would not accept each other's tokens.
+func loadSigningKeys(cfg *Config) (*jwtkeys.KeySet, error) {
+	if cfg.JWTKeysDir == "" {
+		if !isLocalURL(cfg.PublicURL) {
+			return nil, fmt.Errorf("JWT_KEYS_DIR must be set when PUBLIC_URL is %s", cfg.PublicURL)
+		}
+		log.Warn("JWT_KEYS_DIR is not set, tokens are signed with a key that is lost on restart")
+		return jwtkeys.Ephemeral()
+	}
+
+	return jwtkeys.LoadDir(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+}
+
+// isLocalURL tells whether address points at this machine.
+func isLocalURL(address string) bool {
+	u, err := url.Parse(address)
+	if err != nil {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSigningKeysOnlyMakesKeysUpLocally(t *testing.T) {
+	for _, publicURL := range []string{"http://localhost:8080", "http://127.0.0.1:8080", "http://[::1]:8080"} {
+		keys, err := loadSigningKeys(&Config{PublicURL: publicURL})
+		assert.NoError(t, err, publicURL)
+		assert.NotNil(t, keys, publicURL)
+	}
+
+	_, err := loadSigningKeys(&Config{PublicURL: "https://api.escoladojogo.com.br"})
+	assert.Error(t, err)
+}
@@ -1,35 +1,144 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"github.com/dgrijalva/jwt-go"
 	"net/http"
 	"strings"
 )
 
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
 func (s *Server) authenticate(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tokenHeader := r.Header.Get("Authorization")
-		if tokenHeader == "" {
-			http.Error(w, "Missing token", http.StatusUnauthorized)
+		claims, err := s.activeClaims(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		splitToken := strings.Split(tokenHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// identify is authenticate for public endpoints: it reads the token when a
+// valid one is sent and lets anonymous callers through otherwise.
+func (s *Server) identify(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := s.activeClaims(r)
+		if err != nil {
+			next(w, r)
 			return
 		}
-		requestToken := splitToken[1]
 
-		token, err := jwt.ParseWithClaims(requestToken, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(s.jwtKey), nil
-		})
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// activeClaims is parseToken for accounts that still exist: tokens issued
+// before a user deleted their account stop working right away.
+func (s *Server) activeClaims(r *http.Request) (*JWTClaims, error) {
+	claims, err := s.parseToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserByID(claims.UserID)
+	if err != nil || user.Deleted {
+		return nil, errors.New("Invalid token")
+	}
+
+	if !claims.TwoFactor {
+		required, err := s.twoFactorRequired(claims.Role)
+		if err != nil {
+			return nil, err
+		}
+		claims.twoFactorMissing = required
+	}
+
+	return claims, nil
+}
+
+func (s *Server) parseToken(r *http.Request) (*JWTClaims, error) {
+	tokenHeader := r.Header.Get("Authorization")
+	if tokenHeader == "" {
+		return nil, errors.New("Missing token")
+	}
+
+	splitToken := strings.Split(tokenHeader, "Bearer ")
+	if len(splitToken) != 2 {
+		return nil, errors.New("Invalid token")
+	}
+	requestToken := splitToken[1]
+
+	claims := &JWTClaims{}
+	token, err := jwt.ParseWithClaims(requestToken, claims, s.keys.Keyfunc)
 
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+	if err != nil || !token.Valid {
+		return nil, errors.New("Invalid token")
+	}
+
+	// Keyfunc has checked kid and alg against our keys; tokens must also be
+	// ours rather than another service's signed with a shared key
+	if !claims.VerifyIssuer(s.publicURL, true) {
+		return nil, errors.New("Invalid token")
+	}
+
+	return claims, nil
+}
+
+// authorize rejects callers whose token does not carry one of the given
+// roles, or that signed in without 2FA when their role requires it. It must
+// run behind authenticate.
+func (s *Server) authorize(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := claimsFromContext(r.Context())
+		if claims == nil {
+			http.Error(w, "Missing token", http.StatusUnauthorized)
+			return
+		}
+
+		if hasRole(claims, roles...) {
+			next(w, r)
 			return
 		}
 
-		next(w, r)
+		for _, role := range roles {
+			if claims.Role == role {
+				http.Error(w, "Two-factor authentication is required for your role", http.StatusForbidden)
+				return
+			}
+		}
+
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}
+}
+
+// hasRole tells whether the caller holds one of the given roles. A role that
+// requires 2FA only counts when the caller signed in with it, so every check
+// that grants staff powers must go through here rather than read Role.
+func hasRole(claims *JWTClaims, roles ...string) bool {
+	if claims == nil || claims.twoFactorMissing {
+		return false
 	}
+
+	for _, role := range roles {
+		if claims.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// claimsFromContext returns the claims of the token validated by
+// authenticate, or nil when the request did not go through it.
+func claimsFromContext(ctx context.Context) *JWTClaims {
+	claims, _ := ctx.Value(claimsContextKey).(*JWTClaims)
+	return claims
 }
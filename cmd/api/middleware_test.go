@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/jwtkeys"
+	"game-student-go/internal/model"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPublicURL = "https://escoladojogo.test"
+
+// fakeDB serves the few lookups the handlers under test make. Anything else
+// panics on the nil embedded Client.
+type fakeDB struct {
+	database.Client
+	users          map[int]model.User
+	twoFactorRoles []string
+	courses        map[int]model.Course
+	trainings      map[int]model.Training
+	quizzes        map[int]*model.Quiz
+	submissions    map[int]*model.Submission
+	oauthStates    map[string]model.OAuthState
+	identities     map[string]model.UserIdentity
+	oauthSignins   map[string]model.OAuthSignin
+	throttles      map[string]model.SigninThrottle
+	challenges     map[string]int
+}
+
+func (db *fakeDB) GetUserByID(id int) (model.User, error) {
+	user, ok := db.users[id]
+	if !ok {
+		return model.User{}, fmt.Errorf("no user found with id %d: %w", id, database.ErrNotFound)
+	}
+	return user, nil
+}
+
+func (db *fakeDB) GetTwoFactorRoles() ([]string, error) {
+	return db.twoFactorRoles, nil
+}
+
+func (db *fakeDB) GetCourseByID(id int) (model.Course, error) {
+	course, ok := db.courses[id]
+	if !ok {
+		return model.Course{}, fmt.Errorf("no course found with id %d: %w", id, database.ErrNotFound)
+	}
+	return course, nil
+}
+
+func (db *fakeDB) GetTrainingByID(id int) (model.Training, error) {
+	training, ok := db.trainings[id]
+	if !ok {
+		return model.Training{}, fmt.Errorf("no training found with id %d: %w", id, database.ErrNotFound)
+	}
+	return training, nil
+}
+
+func (db *fakeDB) GetQuizByTraining(trainingID int) (*model.Quiz, error) {
+	quiz, ok := db.quizzes[trainingID]
+	if !ok {
+		return nil, fmt.Errorf("no quiz found for training %d: %w", trainingID, database.ErrNotFound)
+	}
+	copied := *quiz
+	return &copied, nil
+}
+
+func (db *fakeDB) GetSubmission(id int) (*model.Submission, error) {
+	submission, ok := db.submissions[id]
+	if !ok {
+		return nil, fmt.Errorf("no submission found with id %d: %w", id, database.ErrNotFound)
+	}
+	return submission, nil
+}
+
+const (
+	adminID      = 1
+	instructorID = 2
+	studentID    = 3
+)
+
+func newTestServer(t *testing.T, db database.Client) *Server {
+	keys, err := jwtkeys.Ephemeral()
+	if err != nil {
+		t.Fatalf("Failed to create keys: %v", err)
+	}
+	return NewServer(0, db, keys, nil, nil, testPublicURL, nil)
+}
+
+// newStaffDB has an admin, an instructor teaching course 1 and a student,
+// with 2FA required of both staff roles.
+func newStaffDB() *fakeDB {
+	return &fakeDB{
+		users: map[int]model.User{
+			adminID:      {ID: adminID, Role: model.RoleAdmin},
+			instructorID: {ID: instructorID, Role: model.RoleInstructor},
+			studentID:    {ID: studentID, Role: model.RoleStudent},
+		},
+		twoFactorRoles: []string{model.RoleAdmin, model.RoleInstructor},
+		courses: map[int]model.Course{
+			1: {ID: 1, Status: model.PublicationPublished, InstructorIDs: []int{instructorID}},
+			2: {ID: 2, Status: model.PublicationDraft, InstructorIDs: []int{instructorID}},
+		},
+		trainings: map[int]model.Training{
+			10: {ID: 10, CourseID: 1, Status: model.PublicationPublished},
+			20: {ID: 20, CourseID: 2, Status: model.PublicationDraft},
+		},
+		quizzes: map[int]*model.Quiz{
+			10: {TrainingID: 10, Questions: []model.QuizQuestion{{
+				ID: 1, Kind: model.QuestionSingleChoice,
+				Options: []model.QuizOption{{ID: 1, Text: "A", Correct: true}, {ID: 2, Text: "B"}},
+			}}},
+		},
+		submissions: map[int]*model.Submission{
+			5: {ID: 5, UserID: studentID, TrainingID: 10},
+		},
+	}
+}
+
+func (s *Server) testToken(t *testing.T, user model.User, twoFactor bool) string {
+	now := time.Now()
+	token, err := s.keys.Sign(&JWTClaims{
+		UserID:    user.ID,
+		Role:      user.Role,
+		TwoFactor: twoFactor,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.publicURL,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(tokenTTL).Unix(),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+// serve runs handler behind authenticate for the user, with vars as the
+// path variables.
+func serve(t *testing.T, s *Server, handler http.HandlerFunc, userID int, twoFactor bool, vars map[string]string) *httptest.ResponseRecorder {
+	user, err := s.db.GetUserByID(userID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+s.testToken(t, user, twoFactor))
+	r = mux.SetURLVars(r, vars)
+
+	w := httptest.NewRecorder()
+	s.authenticate(handler)(w, r)
+	return w
+}
+
+func TestAuthenticateRejectsDeletedUsers(t *testing.T) {
+	db := newStaffDB()
+	s := newTestServer(t, db)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	assert.Equal(t, http.StatusNoContent, serve(t, s, ok, studentID, false, nil).Code)
+
+	deleted := db.users[studentID]
+	deleted.Deleted = true
+	db.users[studentID] = deleted
+	assert.Equal(t, http.StatusUnauthorized, serve(t, s, ok, studentID, false, nil).Code)
+}
+
+func TestParseTokenChecksIssuerAndKey(t *testing.T) {
+	s := newTestServer(t, newStaffDB())
+	user := model.User{ID: studentID, Role: model.RoleStudent}
+	parse := func(token string) error {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		_, err := s.parseToken(r)
+		return err
+	}
+
+	assert.NoError(t, parse(s.testToken(t, user, false)))
+
+	other := newTestServer(t, newStaffDB())
+	assert.Error(t, parse(other.testToken(t, user, false)), "Tokens signed with unknown keys are rejected")
+
+	other.keys = s.keys
+	other.publicURL = "https://elsewhere.test"
+	assert.Error(t, parse(other.testToken(t, user, false)), "Tokens of other issuers are rejected")
+}
+
+func TestStaffWithoutTwoFactorIsRejected(t *testing.T) {
+	s := newTestServer(t, newStaffDB())
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	t.Run("authorize", func(t *testing.T) {
+		w := serve(t, s, s.authorize(ok, model.RoleAdmin), adminID, false, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Two-factor")
+
+		w = serve(t, s, s.authorize(ok, model.RoleAdmin), adminID, true, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("payment capture", func(t *testing.T) {
+		capture := s.authorize(s.captureFunds, model.RoleAdmin)
+		assert.Equal(t, http.StatusForbidden, serve(t, s, capture, adminID, false, nil).Code)
+		assert.Equal(t, http.StatusForbidden, serve(t, s, capture, studentID, false, nil).Code)
+	})
+
+	t.Run("course preview", func(t *testing.T) {
+		vars := map[string]string{"id": "20"}
+		assert.Equal(t, http.StatusNotFound, serve(t, s, s.getQuiz, instructorID, false, vars).Code)
+		assert.Equal(t, http.StatusNotFound, serve(t, s, s.getQuiz, adminID, false, vars).Code)
+	})
+
+	t.Run("quiz answers", func(t *testing.T) {
+		correct := func(w *httptest.ResponseRecorder) bool {
+			var quiz model.Quiz
+			if err := json.NewDecoder(w.Body).Decode(&quiz); err != nil {
+				t.Fatalf("Failed to decode quiz: %v", err)
+			}
+			return quiz.Questions[0].Options[0].Correct
+		}
+
+		vars := map[string]string{"id": "10"}
+		assert.False(t, correct(serve(t, s, s.getQuiz, instructorID, false, vars)))
+		assert.True(t, correct(serve(t, s, s.getQuiz, instructorID, true, vars)))
+	})
+
+	t.Run("submissions", func(t *testing.T) {
+		vars := map[string]string{"id": "5"}
+		assert.Equal(t, http.StatusForbidden, serve(t, s, s.getSubmission, instructorID, false, vars).Code)
+		assert.Equal(t, http.StatusOK, serve(t, s, s.getSubmission, instructorID, true, vars).Code)
+		assert.Equal(t, http.StatusOK, serve(t, s, s.getSubmission, studentID, false, vars).Code)
+	})
+}
+
+func TestUsersOnlySeeTheirOwnAccount(t *testing.T) {
+	s := newTestServer(t, newStaffDB())
+	vars := map[string]string{"id": fmt.Sprint(studentID)}
+
+	assert.Equal(t, http.StatusOK, serve(t, s, s.GetUserByID, studentID, false, vars).Code)
+	assert.Equal(t, http.StatusForbidden, serve(t, s, s.GetUserByID, instructorID, true, vars).Code)
+	assert.Equal(t, http.StatusForbidden, serve(t, s, s.GetUserByID, adminID, false, vars).Code, "Admins need 2FA")
+	assert.Equal(t, http.StatusOK, serve(t, s, s.GetUserByID, adminID, true, vars).Code)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/model"
+	"game-student-go/internal/notes"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func validateNote(request NoteRequest) (string, error) {
+	if request.PositionSeconds < 0 {
+		return "", fmt.Errorf("position_seconds must not be negative")
+	}
+
+	body := strings.TrimSpace(request.Body)
+	return body, validatePost(body)
+}
+
+// ownNote loads the note in the id path variable, answering 404 when it
+// belongs to someone else so notes stay private.
+func (s *Server) ownNote(w http.ResponseWriter, r *http.Request) (*model.Note, bool) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	note, err := s.db.GetNote(noteID)
+	if err != nil {
+		writeDBError(w, err)
+		return nil, false
+	}
+
+	if note.UserID != claimsFromContext(r.Context()).UserID {
+		http.Error(w, fmt.Sprintf("no note found with id %d", noteID), http.StatusNotFound)
+		return nil, false
+	}
+
+	return note, true
+}
+
+func (s *Server) getTrainingNotes(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	trainingNotes, err := s.db.GetTrainingNotes(claimsFromContext(r.Context()).UserID, training.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, trainingNotes)
+}
+
+func (s *Server) createNote(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	var request NoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := validateNote(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	note, err := s.db.CreateNote(model.Note{
+		UserID:          claimsFromContext(r.Context()).UserID,
+		TrainingID:      training.ID,
+		PositionSeconds: request.PositionSeconds,
+		Body:            body,
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, note)
+}
+
+func (s *Server) updateNote(w http.ResponseWriter, r *http.Request) {
+	note, ok := s.ownNote(w, r)
+	if !ok {
+		return
+	}
+
+	var request NoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := validateNote(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.db.UpdateNote(note.ID, request.PositionSeconds, body)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) deleteNote(w http.ResponseWriter, r *http.Request) {
+	note, ok := s.ownNote(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteNote(note.ID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// searchNotes serves /me/notes?q=, matching text within the caller's own
+// notes.
+func (s *Server) searchNotes(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	found, err := s.db.SearchNotes(claimsFromContext(r.Context()).UserID, query, limit)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, found)
+}
+
+// exportCourseNotes downloads the caller's notes on a course as Markdown.
+func (s *Server) exportCourseNotes(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	courseNotes, err := s.db.GetCourseNotes(claimsFromContext(r.Context()).UserID, course.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="notes-course-%d.md"`, course.ID))
+	if _, err := w.Write([]byte(notes.Markdown(course.Name, courseNotes))); err != nil {
+		log.Error("Failed to write notes:", err)
+	}
+}
+
+func (s *Server) addBookmark(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.AddBookmark(claimsFromContext(r.Context()).UserID, training.ID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeBookmark(w http.ResponseWriter, r *http.Request) {
+	trainingID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.RemoveBookmark(claimsFromContext(r.Context()).UserID, trainingID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getBookmarks(w http.ResponseWriter, r *http.Request) {
+	bookmarks, err := s.db.GetBookmarks(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, bookmarks)
+}
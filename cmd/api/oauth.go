@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/model"
+	"game-student-go/internal/oauth"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// oauthStateTTL is how long a user has to sign in at the provider and come
+// back to the callback.
+const oauthStateTTL = 10 * time.Minute
+
+// oauthSigninTTL is how long the app has to collect a finished sign in, and
+// the user to enter their password when linking accounts.
+const oauthSigninTTL = 5 * time.Minute
+
+// oauthStateCookie carries the state to the callback in the browser that
+// started the sign in, so nobody can finish a sign in of theirs in someone
+// else's browser.
+const oauthStateCookie = "oauth_state"
+
+// ConfigureOAuth sets up the identity providers users can sign in with.
+// Providers without a client ID are left off.
+func (s *Server) ConfigureOAuth(cfg *Config) {
+	s.oauthClient = &http.Client{Timeout: 10 * time.Second}
+	s.oauthProviders = map[string]*oauth.Provider{}
+	s.oauthCompleteURL = cfg.OAuthCompleteURL
+
+	if cfg.GoogleClientID != "" {
+		s.oauthProviders["google"] = oauth.NewGoogle(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.GoogleIssuer)
+	}
+	if cfg.GitHubClientID != "" {
+		s.oauthProviders["github"] = oauth.NewGitHub(cfg.GitHubClientID, cfg.GitHubClientSecret, cfg.GitHubURL, cfg.GitHubAPIURL)
+	}
+}
+
+func (s *Server) oauthProvider(w http.ResponseWriter, r *http.Request) (*oauth.Provider, bool) {
+	provider, ok := s.oauthProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		http.Error(w, "Unknown sign in provider", http.StatusNotFound)
+		return nil, false
+	}
+
+	if err := provider.Discover(r.Context(), s.oauthClient); err != nil {
+		log.Error(err)
+		http.Error(w, "Sign in provider unavailable", http.StatusBadGateway)
+		return nil, false
+	}
+
+	return provider, true
+}
+
+func (s *Server) oauthRedirectURI(provider *oauth.Provider) string {
+	return fmt.Sprintf("%s/auth/%s/callback", s.publicURL, provider.Name)
+}
+
+// setOAuthStateCookie keeps state in the browser for the callback of
+// provider; maxAge -1 deletes it. Lax lets the cookie through the top-level
+// redirect back from the provider.
+func (s *Server) setOAuthStateCookie(w http.ResponseWriter, provider *oauth.Provider, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     fmt.Sprintf("/auth/%s/callback", provider.Name),
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(s.publicURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// startOAuthSignin sends the user to sign in at the provider.
+func (s *Server) startOAuthSignin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	verifier, err := oauth.NewVerifier()
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = s.db.CreateOAuthState(model.OAuthState{
+		StateHash:    hashToken(state),
+		Provider:     provider.Name,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	})
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	s.setOAuthStateCookie(w, provider, state, int(oauthStateTTL.Seconds()))
+	http.Redirect(w, r, provider.AuthCodeURL(state, verifier, s.oauthRedirectURI(provider)), http.StatusFound)
+}
+
+// finishOAuthSignin is where the provider sends the user back. It finds
+// their account, creating it on first use, and sends them on to the app
+// with a one-time code that collectOAuthSignin trades for a token.
+func (s *Server) finishOAuthSignin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		http.Error(w, fmt.Sprintf("Sign in with %s failed: %s", provider.Name, providerError), http.StatusUnauthorized)
+		return
+	}
+
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		http.Error(w, "code and state are required", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, "Invalid or expired sign in, start again", http.StatusBadRequest)
+		return
+	}
+	s.setOAuthStateCookie(w, provider, "", -1)
+
+	pending, err := s.db.ConsumeOAuthState(hashToken(state), provider.Name)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Invalid or expired sign in, start again", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := provider.Exchange(r.Context(), s.oauthClient, code, pending.CodeVerifier, s.oauthRedirectURI(provider))
+	if err != nil {
+		log.Warn(err)
+		http.Error(w, fmt.Sprintf("Sign in with %s failed", provider.Name), http.StatusUnauthorized)
+		return
+	}
+
+	identity, err := provider.Identity(r.Context(), s.oauthClient, accessToken)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "Sign in provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	user, link, err := s.oauthUser(provider.Name, identity)
+	if errors.Is(err, oauth.ErrEmailNotVerified) {
+		http.Error(w, fmt.Sprintf("Your %s account has no verified email", provider.Name), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	signinCode, err := randomToken(32)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = s.db.CreateOAuthSignin(model.OAuthSignin{
+		CodeHash:  hashToken(signinCode),
+		UserID:    user.ID,
+		Link:      link,
+		ExpiresAt: time.Now().Add(oauthSigninTTL),
+	})
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// The fragment is not sent on to servers, so the code stays out of logs
+	fragment := url.Values{"code": {signinCode}}
+	if link != nil {
+		fragment.Set("link", provider.Name)
+	}
+	http.Redirect(w, r, s.oauthCompleteURL+"#"+fragment.Encode(), http.StatusFound)
+}
+
+// collectOAuthSignin trades the one-time code of a sign in finished at a
+// provider for a token, the same way a password would. Linking a provider
+// account to an existing one also takes that account's password, which
+// counts against the sign in limits like any other.
+func (s *Server) collectOAuthSignin(w http.ResponseWriter, r *http.Request) {
+	var request OAuthSigninRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	codeHash := hashToken(request.Code)
+	signin, err := s.db.GetOAuthSignin(codeHash)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Invalid or expired sign in, start again", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	user, err := s.db.GetUserByID(signin.UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if user.Deleted {
+		http.Error(w, "Invalid or expired sign in, start again", http.StatusBadRequest)
+		return
+	}
+
+	ip := clientIP(r)
+	if signin.Link != nil {
+		if !s.beginSignin(w, user.Email, ip) {
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)) != nil {
+			http.Error(w, fmt.Sprintf("Enter the password of your account to link it to %s", signin.Link.Provider), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if err := s.db.DeleteOAuthSignin(codeHash); errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Invalid or expired sign in, start again", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if signin.Link != nil {
+		if err := s.db.LinkUserIdentity(*signin.Link); err != nil {
+			writeDBError(w, err)
+			return
+		}
+		log.Infof("Linked %s account %s to user %d", signin.Link.Provider, signin.Link.Subject, user.ID)
+		s.passwordAccepted(user, ip)
+	}
+
+	s.completeSignin(w, user)
+}
+
+// oauthUser finds the account of someone signed in at a provider, creating
+// and linking it when there is none with their verified email. An existing
+// account with that email is not linked right away: it is returned with the
+// identity to link once the user confirms with the account's password, as
+// nobody has checked that the address is theirs.
+func (s *Server) oauthUser(provider string, identity oauth.Identity) (model.User, *model.UserIdentity, error) {
+	user, err := s.db.GetUserByIdentity(provider, identity.Subject)
+	if err == nil || !errors.Is(err, database.ErrNotFound) {
+		return user, nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return model.User{}, nil, oauth.ErrEmailNotVerified
+	}
+
+	link := &model.UserIdentity{
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	user, err = s.db.GetUserByEmail(identity.Email)
+	if err == nil {
+		link.UserID = user.ID
+		return user, link, nil
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return model.User{}, nil, err
+	}
+
+	user, err = s.registerOAuthUser(identity)
+	if err != nil {
+		return model.User{}, nil, err
+	}
+
+	link.UserID = user.ID
+	if err := s.db.LinkUserIdentity(*link); err != nil {
+		return model.User{}, nil, err
+	}
+	log.Infof("Linked %s account %s to new user %d", provider, identity.Subject, user.ID)
+
+	return user, nil, nil
+}
+
+// registerOAuthUser creates the account of someone who first signs in with
+// a provider. They get a random password nobody knows.
+func (s *Server) registerOAuthUser(identity oauth.Identity) (model.User, error) {
+	password, err := randomToken(32)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err := s.registerUser(identity.Email, password)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if identity.Name != "" {
+		user.DisplayName = identity.Name
+		if updated, err := s.db.UpdateUserProfile(user); err != nil {
+			log.Errorf("saving name of user %d: %v", user.ID, err)
+		} else {
+			user = updated
+		}
+	}
+
+	if err := s.sender.SendRegistrationEmail(user.Email); err != nil {
+		log.Errorf("welcoming user %d: %v", user.ID, err)
+	}
+
+	return user, nil
+}
+
+// cleanOAuthStates forgets the sign ins nobody came back from.
+func (s *Server) cleanOAuthStates(_ context.Context) error {
+	return s.db.DeleteExpiredOAuthStates(time.Now())
+}
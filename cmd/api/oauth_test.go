@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/model"
+	"game-student-go/internal/oauth"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (db *fakeDB) CreateOAuthState(state model.OAuthState) error {
+	db.oauthStates[state.StateHash] = state
+	return nil
+}
+
+func (db *fakeDB) ConsumeOAuthState(stateHash, provider string) (*model.OAuthState, error) {
+	state, ok := db.oauthStates[stateHash]
+	if !ok || state.Provider != provider {
+		return nil, fmt.Errorf("no %s sign in with this state: %w", provider, database.ErrNotFound)
+	}
+	delete(db.oauthStates, stateHash)
+	return &state, nil
+}
+
+func (db *fakeDB) GetUserByIdentity(provider, subject string) (model.User, error) {
+	identity, ok := db.identities[provider+"/"+subject]
+	if !ok {
+		return model.User{}, fmt.Errorf("no user linked to %s account %s: %w", provider, subject, database.ErrNotFound)
+	}
+	return db.GetUserByID(identity.UserID)
+}
+
+func (db *fakeDB) GetUserByEmail(email string) (model.User, error) {
+	for _, user := range db.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return model.User{}, fmt.Errorf("no user found with email %s: %w", email, database.ErrNotFound)
+}
+
+func (db *fakeDB) LinkUserIdentity(identity model.UserIdentity) error {
+	db.identities[identity.Provider+"/"+identity.Subject] = identity
+	return nil
+}
+
+func (db *fakeDB) CreateOAuthSignin(signin model.OAuthSignin) error {
+	db.oauthSignins[signin.CodeHash] = signin
+	return nil
+}
+
+func (db *fakeDB) GetOAuthSignin(codeHash string) (*model.OAuthSignin, error) {
+	signin, ok := db.oauthSignins[codeHash]
+	if !ok {
+		return nil, fmt.Errorf("no oauth sign in with this code: %w", database.ErrNotFound)
+	}
+	return &signin, nil
+}
+
+func (db *fakeDB) DeleteOAuthSignin(codeHash string) error {
+	if _, ok := db.oauthSignins[codeHash]; !ok {
+		return fmt.Errorf("no oauth sign in with this code: %w", database.ErrNotFound)
+	}
+	delete(db.oauthSignins, codeHash)
+	return nil
+}
+
+// newGitHubStub answers as GitHub would for the user with the given primary
+// email, whatever the code.
+func newGitHubStub(t *testing.T, email string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/api/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 42, "login": "ana", "name": "Ana"}`))
+	})
+	mux.HandleFunc("/api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"email": email, "primary": true, "verified": true}})
+	})
+
+	stub := httptest.NewServer(mux)
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+const testOAuthCompleteURL = "https://app.escoladojogo.test/auth/complete"
+
+// newOAuthServer has a student, ana@test.com with password "password", and
+// signs in with a GitHub stub whose user has the given email.
+func newOAuthServer(t *testing.T, githubEmail string) (*Server, *fakeDB) {
+	password, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	db := newStaffDB()
+	db.users[studentID] = model.User{ID: studentID, Email: "ana@test.com", Password: string(password), Role: model.RoleStudent}
+	db.oauthStates = map[string]model.OAuthState{}
+	db.identities = map[string]model.UserIdentity{}
+	db.oauthSignins = map[string]model.OAuthSignin{}
+
+	stub := newGitHubStub(t, githubEmail)
+	s := newTestServer(t, db)
+	s.oauthClient = stub.Client()
+	s.oauthProviders = map[string]*oauth.Provider{"github": oauth.NewGitHub("client", "secret", stub.URL, stub.URL+"/api")}
+	s.oauthCompleteURL = testOAuthCompleteURL
+
+	return s, db
+}
+
+// startOAuth starts a sign in and returns the state sent to the provider
+// and the cookie set in the browser.
+func startOAuth(t *testing.T, s *Server) (string, *http.Cookie) {
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/auth/github/login", nil), map[string]string{"provider": "github"})
+	w := httptest.NewRecorder()
+	s.startOAuthSignin(w, r)
+	if w.Code != http.StatusFound {
+		t.Fatalf("Failed to start sign in: %d %s", w.Code, w.Body.String())
+	}
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to parse redirect: %v", err)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected the state cookie, got %v", cookies)
+	}
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+
+	return location.Query().Get("state"), cookies[0]
+}
+
+func finishOAuth(s *Server, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	target := "/auth/github/callback?" + url.Values{"code": {"good-code"}, "state": {state}}.Encode()
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, target, nil), map[string]string{"provider": "github"})
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	s.finishOAuthSignin(w, r)
+	return w
+}
+
+// completed reads the fragment the callback sent the app.
+func completed(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+	assert.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+	location := w.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, testOAuthCompleteURL+"#"), location)
+
+	target, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("Failed to parse redirect: %v", err)
+	}
+	fragment, err := url.ParseQuery(target.Fragment)
+	if err != nil {
+		t.Fatalf("Failed to parse fragment: %v", err)
+	}
+	return fragment
+}
+
+func collectOAuth(s *Server, code, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(OAuthSigninRequest{Code: code, Password: password})
+	w := httptest.NewRecorder()
+	s.collectOAuthSignin(w, httptest.NewRequest(http.MethodPost, "/auth/signin", bytes.NewReader(body)))
+	return w
+}
+
+func TestOAuthCallbackChecksStateCookie(t *testing.T) {
+	s, db := newOAuthServer(t, "ana@test.com")
+
+	state, _ := startOAuth(t, s)
+	assert.Equal(t, http.StatusBadRequest, finishOAuth(s, state, nil).Code, "The browser that started must finish")
+
+	state, _ = startOAuth(t, s)
+	_, other := startOAuth(t, s)
+	assert.Equal(t, http.StatusBadRequest, finishOAuth(s, state, other).Code, "Another sign in's cookie does not do")
+
+	assert.Empty(t, db.oauthSignins)
+}
+
+func TestOAuthSigninOfLinkedAccount(t *testing.T) {
+	s, db := newOAuthServer(t, "ana@test.com")
+	db.identities["github/42"] = model.UserIdentity{Provider: "github", Subject: "42", UserID: studentID}
+
+	state, cookie := startOAuth(t, s)
+	w := finishOAuth(s, state, cookie)
+	fragment := completed(t, w)
+	assert.Empty(t, fragment.Get("link"))
+	assert.NotContains(t, w.Body.String(), "token", "Tokens are only handed out for the code")
+
+	cleared := w.Result().Cookies()
+	if assert.Len(t, cleared, 1) {
+		assert.True(t, cleared[0].MaxAge < 0, "The state cookie is cleared")
+	}
+
+	w = collectOAuth(s, fragment.Get("code"), "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode token: %v", err)
+	}
+	assert.NotEmpty(t, response["token"])
+
+	assert.Equal(t, http.StatusBadRequest, collectOAuth(s, fragment.Get("code"), "").Code, "Codes are used once")
+}
+
+func TestOAuthLinkNeedsPassword(t *testing.T) {
+	s, db := newOAuthServer(t, "Ana@Test.com")
+
+	state, cookie := startOAuth(t, s)
+	fragment := completed(t, finishOAuth(s, state, cookie))
+	assert.Equal(t, "github", fragment.Get("link"), "Emails match whatever their case")
+	assert.Empty(t, db.identities, "Accounts are not linked before the password is checked")
+
+	code := fragment.Get("code")
+	assert.Equal(t, http.StatusUnauthorized, collectOAuth(s, code, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, collectOAuth(s, code, "wrong password").Code)
+	assert.Empty(t, db.identities)
+
+	w := collectOAuth(s, code, "password")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	if assert.Contains(t, db.identities, "github/42") {
+		assert.Equal(t, studentID, db.identities["github/42"].UserID)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, collectOAuth(s, code, "password").Code, "Codes are used once")
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"game-student-go/internal/model"
+	"net/http"
+	"strings"
+)
+
+func (s *Server) getCourseEligibility(userID, courseID int) (model.CourseEligibility, error) {
+	missing, err := s.db.GetMissingPrerequisites(userID, courseID)
+	if err != nil {
+		return model.CourseEligibility{}, err
+	}
+
+	eligibility := model.CourseEligibility{CourseID: courseID, Blocking: []model.Prerequisite{}, Warnings: []model.Prerequisite{}}
+	for _, prerequisite := range missing {
+		if prerequisite.Required {
+			eligibility.Blocking = append(eligibility.Blocking, prerequisite)
+		} else {
+			eligibility.Warnings = append(eligibility.Warnings, prerequisite)
+		}
+	}
+	eligibility.CanEnroll = len(eligibility.Blocking) == 0
+
+	return eligibility, nil
+}
+
+// prerequisitesBlock tells why a student may not start the course, or
+// returns an empty reason when they may. Students who already started the
+// course are never blocked, even if prerequisites were added since.
+func (s *Server) prerequisitesBlock(userID, courseID int) (string, error) {
+	started, err := s.db.HasStartedCourse(userID, courseID)
+	if err != nil || started {
+		return "", err
+	}
+
+	eligibility, err := s.getCourseEligibility(userID, courseID)
+	if err != nil || eligibility.CanEnroll {
+		return "", err
+	}
+
+	names := make([]string, len(eligibility.Blocking))
+	for i, prerequisite := range eligibility.Blocking {
+		names[i] = prerequisite.Name
+	}
+
+	return "Complete the prerequisites first: " + strings.Join(names, ", "), nil
+}
+
+// requirePrerequisites answers 409 and returns false when the student may not
+// start the course yet.
+func (s *Server) requirePrerequisites(w http.ResponseWriter, userID, courseID int) bool {
+	reason, err := s.prerequisitesBlock(userID, courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return false
+	}
+
+	if reason != "" {
+		http.Error(w, reason, http.StatusConflict)
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) getPrerequisites(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prerequisites, err := s.db.GetPrerequisites(courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prerequisites)
+}
+
+// getMyEligibility tells the student whether they may start the course,
+// listing missing required prerequisites as blocking and the others as
+// warnings.
+func (s *Server) getMyEligibility(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	eligibility, err := s.getCourseEligibility(claimsFromContext(r.Context()).UserID, courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, eligibility)
+}
+
+func (s *Server) addPrerequisite(w http.ResponseWriter, r *http.Request) {
+	course, ok := s.managedCourse(w, r)
+	if !ok {
+		return
+	}
+
+	prerequisiteID, err := pathID(r, "prerequisite_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := PrerequisiteRequest{Required: true}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if prerequisiteID == course.ID {
+		http.Error(w, "a course cannot be its own prerequisite", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.AddPrerequisite(course.ID, prerequisiteID, request.Required); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removePrerequisite(w http.ResponseWriter, r *http.Request) {
+	course, ok := s.managedCourse(w, r)
+	if !ok {
+		return
+	}
+
+	prerequisiteID, err := pathID(r, "prerequisite_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.RemovePrerequisite(course.ID, prerequisiteID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishedCourses leaves out the path courses that are not live yet.
+func publishedCourses(path *model.LearningPath) {
+	courses := []model.PathCourse{}
+	for _, course := range path.Courses {
+		if course.Status == model.PublicationPublished {
+			courses = append(courses, course)
+		}
+	}
+	path.Courses = courses
+}
+
+func (s *Server) getLearningPaths(w http.ResponseWriter, _ *http.Request) {
+	paths, err := s.db.GetLearningPaths()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	for i := range paths {
+		publishedCourses(&paths[i])
+	}
+
+	writeJSON(w, http.StatusOK, paths)
+}
+
+func (s *Server) getLearningPath(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	path, err := s.db.GetLearningPath(id)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	publishedCourses(path)
+
+	writeJSON(w, http.StatusOK, path)
+}
+
+// getLearningPathProgress reports the student's progress on each course of
+// the path and points to the first one they have not finished.
+func (s *Server) getLearningPathProgress(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	path, err := s.db.GetLearningPath(id)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	publishedCourses(path)
+
+	userID := claimsFromContext(r.Context()).UserID
+	progress := model.PathProgress{PathID: path.ID, Courses: []model.PathCourseProgress{}, TotalCourses: len(path.Courses)}
+	for _, course := range path.Courses {
+		courseProgress, err := s.db.GetCourseProgress(userID, course.CourseID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		if courseProgress.Finished() {
+			progress.CompletedCourses++
+		} else if progress.NextCourseID == nil {
+			courseID := course.CourseID
+			progress.NextCourseID = &courseID
+		}
+
+		progress.Courses = append(progress.Courses, model.PathCourseProgress{PathCourse: course, Progress: courseProgress})
+	}
+
+	if progress.TotalCourses > 0 {
+		progress.Percentage = float64(progress.CompletedCourses) * 100 / float64(progress.TotalCourses)
+	}
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func decodeLearningPath(r *http.Request) (model.LearningPath, error) {
+	var request LearningPathRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return model.LearningPath{}, err
+	}
+
+	if !slugPattern.MatchString(request.Slug) {
+		return model.LearningPath{}, errors.New("slug must be lowercase words separated by dashes")
+	}
+
+	if strings.TrimSpace(request.Name) == "" {
+		return model.LearningPath{}, errors.New("name is required")
+	}
+
+	path := model.LearningPath{Slug: request.Slug, Name: strings.TrimSpace(request.Name), Description: request.Description}
+	for _, courseID := range request.CourseIDs {
+		path.Courses = append(path.Courses, model.PathCourse{CourseID: courseID})
+	}
+
+	return path, nil
+}
+
+func (s *Server) createLearningPath(w http.ResponseWriter, r *http.Request) {
+	path, err := decodeLearningPath(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.db.SaveLearningPath(path)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+func (s *Server) updateLearningPath(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	path, err := decodeLearningPath(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path.ID = id
+
+	saved, err := s.db.SaveLearningPath(path)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, saved)
+}
+
+func (s *Server) deleteLearningPath(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.DeleteLearningPath(id); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/model"
+	"game-student-go/internal/portfolio"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxPortfolioSlugLength     = 50
+	maxPortfolioNameLength     = 100
+	maxPortfolioHeadlineLength = 200
+)
+
+func validPortfolioKind(kind string) bool {
+	switch kind {
+	case model.PortfolioSubmission, model.PortfolioJamEntry, model.PortfolioCertificate, model.PortfolioBadge:
+		return true
+	}
+
+	return false
+}
+
+// portfolioItems loads the items of a user's portfolio and fills in the
+// links and names that are not stored with them. Only visible items are
+// kept unless all is set.
+func (s *Server) portfolioItems(userID int, all bool) ([]model.PortfolioItem, error) {
+	items, err := s.db.GetPortfolioItems(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []model.PortfolioItem{}
+	for _, item := range items {
+		if !item.Visible && !all {
+			continue
+		}
+
+		switch item.Kind {
+		case model.PortfolioJamEntry:
+			if strings.HasPrefix(item.URL, "/") {
+				item.URL = s.publicURL + item.URL
+			}
+		case model.PortfolioCertificate:
+			item.URL = s.certificateURL(item.Key)
+		case model.PortfolioBadge:
+			if badge, ok := gamification.BadgeByCode(item.Key); ok {
+				item.Title = badge.Name
+				item.Subtitle = badge.Description
+			}
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// getMyPortfolio shows the owner every item, hidden ones included, so they
+// can pick what goes public. Users who never saved a portfolio get an empty
+// one with no slug.
+func (s *Server) getMyPortfolio(w http.ResponseWriter, r *http.Request) {
+	userID := claimsFromContext(r.Context()).UserID
+
+	mine, err := s.db.GetPortfolio(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		mine = &model.Portfolio{UserID: userID}
+	} else if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	mine.Items, err = s.portfolioItems(userID, true)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mine)
+}
+
+func (s *Server) saveMyPortfolio(w http.ResponseWriter, r *http.Request) {
+	var request PortfolioRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !slugPattern.MatchString(request.Slug) || len(request.Slug) > maxPortfolioSlugLength {
+		http.Error(w, fmt.Sprintf("slug must be lowercase words separated by dashes, up to %d characters", maxPortfolioSlugLength),
+			http.StatusBadRequest)
+		return
+	}
+
+	displayName := strings.TrimSpace(request.DisplayName)
+	if displayName == "" || len([]rune(displayName)) > maxPortfolioNameLength {
+		http.Error(w, fmt.Sprintf("display_name must have between 1 and %d characters", maxPortfolioNameLength), http.StatusBadRequest)
+		return
+	}
+
+	headline := strings.TrimSpace(request.Headline)
+	if len([]rune(headline)) > maxPortfolioHeadlineLength {
+		http.Error(w, fmt.Sprintf("headline must be at most %d characters", maxPortfolioHeadlineLength), http.StatusBadRequest)
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	saved, err := s.db.SavePortfolio(model.Portfolio{
+		UserID:      userID,
+		Slug:        request.Slug,
+		DisplayName: displayName,
+		Headline:    headline,
+		Public:      request.Public,
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	saved.Items, err = s.portfolioItems(userID, true)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, saved)
+}
+
+func (s *Server) setPortfolioItemVisibility(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !validPortfolioKind(vars["kind"]) {
+		http.Error(w, fmt.Sprintf("unknown portfolio item kind %q", vars["kind"]), http.StatusBadRequest)
+		return
+	}
+
+	var request PortfolioItemVisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := s.db.SetPortfolioItemVisibility(claimsFromContext(r.Context()).UserID, vars["kind"], vars["key"], request.Visible)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPublicPortfolio serves a portfolio to anyone with its link, as JSON or
+// as an HTML page with OpenGraph tags when a browser or link unfurler asks
+// for HTML. Private portfolios are reported as missing.
+func (s *Server) getPublicPortfolio(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	public, err := s.db.GetPortfolioBySlug(slug)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if !public.Public {
+		http.Error(w, fmt.Sprintf("no portfolio found with slug %q", slug), http.StatusNotFound)
+		return
+	}
+
+	public.Items, err = s.portfolioItems(public.UserID, false)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		writeJSON(w, http.StatusOK, public)
+		return
+	}
+
+	page, err := portfolio.RenderHTML(*public, fmt.Sprintf("%s/portfolio/%s", s.publicURL, public.Slug))
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "could not render portfolio", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(page); err != nil {
+		log.Error("Failed to write portfolio:", err)
+	}
+}
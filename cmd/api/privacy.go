@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/model"
+	"game-student-go/internal/privacy"
+	log "github.com/sirupsen/logrus"
+	"github.com/stripe/stripe-go/v74"
+	"github.com/stripe/stripe-go/v74/customer"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// dataExportBatch is how many exports one run of the export job builds.
+const dataExportBatch = 10
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (s *Server) requestDataExport(w http.ResponseWriter, r *http.Request) {
+	export, err := s.db.CreateDataExport(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, export)
+}
+
+func (s *Server) listMyDataExports(w http.ResponseWriter, r *http.Request) {
+	exports, err := s.db.GetDataExports(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, exports)
+}
+
+func (s *Server) downloadDataExport(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	export, err := s.db.GetDataExport(id)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if export.UserID != claimsFromContext(r.Context()).UserID {
+		http.Error(w, fmt.Sprintf("no data export found with id %d", id), http.StatusNotFound)
+		return
+	}
+
+	if export.Status == model.ExportExpired || (export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt)) {
+		http.Error(w, "Export has expired, request a new one", http.StatusGone)
+		return
+	}
+
+	if export.Status != model.ExportReady {
+		http.Error(w, fmt.Sprintf("Export is %s", export.Status), http.StatusConflict)
+		return
+	}
+
+	file, err := s.store.Open(export.FileKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="meus-dados-%d.zip"`, export.ID))
+	if _, err := io.Copy(w, file); err != nil {
+		log.Error("Failed to write data export:", err)
+	}
+}
+
+// buildDataExports builds the exports waiting in the queue and removes the
+// files of the ones past their download window.
+func (s *Server) buildDataExports(ttl time.Duration) func(context.Context) error {
+	return func(_ context.Context) error {
+		pending, err := s.db.GetPendingDataExports(dataExportBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, export := range pending {
+			if err := s.buildDataExport(export, ttl); err != nil {
+				log.Errorf("building data export %d: %v", export.ID, err)
+				if err := s.db.FailDataExport(export.ID, err.Error()); err != nil {
+					return err
+				}
+			}
+		}
+
+		expired, err := s.db.GetExpiredDataExports(time.Now())
+		if err != nil {
+			return err
+		}
+
+		for _, export := range expired {
+			if err := s.store.Delete(export.FileKey); err != nil {
+				log.Errorf("removing data export %d: %v", export.ID, err)
+				continue
+			}
+			if err := s.db.ExpireDataExport(export.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func (s *Server) buildDataExport(export model.DataExport, ttl time.Duration) error {
+	data, err := s.db.GetPersonalData(export.UserID)
+	if err != nil {
+		return err
+	}
+
+	if data.Profile.StripeId != "" {
+		cards, err := stripeCards(data.Profile.StripeId)
+		if err != nil {
+			return fmt.Errorf("listing cards: %w", err)
+		}
+		if cards != nil {
+			data.Cards = cards
+		}
+	}
+
+	now := time.Now()
+	var archive bytes.Buffer
+	if err := privacy.WriteExport(&archive, *data, now); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("exports/%d/data.zip", export.ID)
+	if err := s.store.Save(key, &archive); err != nil {
+		return err
+	}
+
+	expiresAt := now.Add(ttl)
+	if err := s.db.CompleteDataExport(export.ID, key, expiresAt); err != nil {
+		return err
+	}
+
+	location, err := time.LoadLocation(data.Profile.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	downloadURL := fmt.Sprintf("%s/me/exports/%d/download", s.publicURL, export.ID)
+	err = s.sender.SendDataExportReadyEmail(data.Profile.Email, downloadURL, expiresAt.In(location).Format("02/01/2006 às 15:04 MST"))
+	if err != nil {
+		log.Errorf("notifying user %d of data export %d: %v", export.UserID, export.ID, err)
+	}
+
+	return nil
+}
+
+// deleteMe deletes the caller's account after checking their password. The
+// request is logged first, then the Stripe customer is deleted and the
+// personal data anonymized. Payments are kept since the law requires them
+// to be retained.
+func (s *Server) deleteMe(w http.ResponseWriter, r *http.Request) {
+	var request DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
+		http.Error(w, "Wrong password", http.StatusUnauthorized)
+		return
+	}
+
+	deletionID, err := s.db.LogAccountDeletion(model.AccountDeletion{
+		UserID:    user.ID,
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	log.Infof("Account deletion %d requested by user %d", deletionID, user.ID)
+
+	// Export files are about to lose their rows, so note where they are
+	exports, err := s.db.GetDataExports(user.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if user.StripeId != "" {
+		if _, err := customer.Del(user.StripeId, nil); err != nil {
+			var stripeErr *stripe.Error
+			if !errors.As(err, &stripeErr) || stripeErr.Code != stripe.ErrorCodeResourceMissing {
+				log.Errorf("deleting Stripe customer of user %d: %v", user.ID, err)
+				http.Error(w, "Failed to delete payment provider data, try again later", http.StatusBadGateway)
+				return
+			}
+		}
+	}
+
+	if err := s.db.AnonymizeUser(user.ID, deletionID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	for _, export := range exports {
+		if export.FileKey == "" {
+			continue
+		}
+		if err := s.store.Delete(export.FileKey); err != nil {
+			log.Errorf("removing data export %d of deleted user %d: %v", export.ID, user.ID, err)
+		}
+	}
+	log.Infof("Account deletion %d completed", deletionID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/stripe/stripe-go/v74"
+	"github.com/stripe/stripe-go/v74/customer"
+	"golang.org/x/crypto/bcrypt"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxDisplayNameLength = 100
+	maxBioLength         = 1000
+	minPasswordLength    = 8
+	emailChangeTTL       = 24 * time.Hour
+
+	// maxPasswordBytes is as much as bcrypt hashes.
+	maxPasswordBytes = 72
+
+	// maxEmailLength is as long as the users table holds.
+	maxEmailLength = 50
+)
+
+var (
+	localePattern  = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+	countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// emailVerificationPage is what the link sent to a new address opens. The
+// change is only confirmed when the button is pressed, so mail scanners
+// following links do not confirm it.
+var emailVerificationPage = template.Must(template.New("verify").Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Confirmar email · Escola do Jogo</title>
+<style>
+body { font-family: sans-serif; max-width: 480px; margin: 4rem auto; padding: 0 1rem; color: #222; }
+</style>
+</head>
+<body>
+{{if .Confirmed}}
+<h1>Email confirmado</h1>
+<p>Sua conta agora usa o endereço {{.Email}}.</p>
+{{else}}
+<h1>Confirmar novo email</h1>
+<p>Confirme que este é o novo endereço da sua conta na Escola do Jogo.</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Confirmar email</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type emailVerification struct {
+	Action    string
+	Token     string
+	Confirmed bool
+	Email     string
+}
+
+// validatePassword checks a new password fits what bcrypt can hash.
+func validatePassword(field, password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("%s must have at least %d characters", field, minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%s must have at most %d bytes", field, maxPasswordBytes)
+	}
+
+	return nil
+}
+
+// hashToken is what gets stored for tokens sent by email, so a leaked table
+// cannot be used to confirm anything.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// applyProfile copies the fields present in the request onto user,
+// validating each of them.
+func applyProfile(user *model.User, request ProfileRequest) error {
+	if request.DisplayName != nil {
+		name := strings.TrimSpace(*request.DisplayName)
+		if len([]rune(name)) > maxDisplayNameLength {
+			return fmt.Errorf("display_name must be at most %d characters", maxDisplayNameLength)
+		}
+		user.DisplayName = name
+	}
+
+	if request.AvatarURL != nil {
+		avatar := strings.TrimSpace(*request.AvatarURL)
+		if avatar != "" {
+			if err := validateWebURL("avatar_url", avatar); err != nil {
+				return err
+			}
+		}
+		user.AvatarURL = avatar
+	}
+
+	if request.Bio != nil {
+		bio := strings.TrimSpace(*request.Bio)
+		if len([]rune(bio)) > maxBioLength {
+			return fmt.Errorf("bio must be at most %d characters", maxBioLength)
+		}
+		user.Bio = bio
+	}
+
+	if request.Locale != nil {
+		if !localePattern.MatchString(*request.Locale) {
+			return fmt.Errorf("locale must be a language tag such as pt-BR")
+		}
+		user.Locale = *request.Locale
+	}
+
+	if request.TimeZone != nil {
+		if !validTimeZone(*request.TimeZone) {
+			return fmt.Errorf("unknown time zone")
+		}
+		user.TimeZone = *request.TimeZone
+	}
+
+	if request.Country != nil {
+		if *request.Country != "" && !countryPattern.MatchString(*request.Country) {
+			return fmt.Errorf("country must be a two-letter ISO 3166 code such as BR")
+		}
+		user.Country = *request.Country
+	}
+
+	return nil
+}
+
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// updateMe changes only the profile fields present in the body.
+func (s *Server) updateMe(w http.ResponseWriter, r *http.Request) {
+	var request ProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if err := applyProfile(&user, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.db.UpdateUserProfile(user)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// changeMyEmail starts an email change. The account keeps its current
+// address until the link sent to the new one is followed.
+func (s *Server) changeMyEmail(w http.ResponseWriter, r *http.Request) {
+	var request ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	address, err := mail.ParseAddress(request.Email)
+	if err != nil || address.Address != request.Email {
+		http.Error(w, "email must be a valid address", http.StatusBadRequest)
+		return
+	}
+	if len([]rune(request.Email)) > maxEmailLength {
+		http.Error(w, fmt.Sprintf("email must be at most %d characters", maxEmailLength), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
+		http.Error(w, "Wrong password", http.StatusUnauthorized)
+		return
+	}
+
+	if strings.EqualFold(user.Email, request.Email) {
+		http.Error(w, "email is already the account address", http.StatusBadRequest)
+		return
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "could not start the email change", http.StatusInternalServerError)
+		return
+	}
+
+	change := model.EmailChange{UserID: user.ID, NewEmail: request.Email, ExpiresAt: time.Now().Add(emailChangeTTL)}
+	if err := s.db.CreateEmailChange(change, hashToken(token)); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	verifyURL := fmt.Sprintf("%s/email/verify?token=%s", s.publicURL, url.QueryEscape(token))
+	if err := s.sender.SendEmailVerificationEmail(request.Email, verifyURL); err != nil {
+		log.Errorf("sending email verification to user %d: %v", user.ID, err)
+		http.Error(w, "Failed to send the verification email", http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, change)
+}
+
+// showEmailVerification is the link sent to a new address. It only shows a
+// page asking to confirm, which posts to verifyEmail.
+func (s *Server) showEmailVerification(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	s.writeEmailVerification(w, http.StatusOK, emailVerification{Token: token})
+}
+
+// verifyEmail confirms an email change. It needs no login since the token
+// proves the mailbox, and it warns the old address about the change.
+// Browsers posting the verification page get a page back, API clients the
+// updated user.
+func (s *Server) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	user, oldEmail, err := s.db.ConfirmEmailChange(hashToken(token))
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if user.StripeId != "" {
+		if _, err := customer.Update(user.StripeId, &stripe.CustomerParams{Email: stripe.String(user.Email)}); err != nil {
+			log.Errorf("updating Stripe customer email of user %d: %v", user.ID, err)
+		}
+	}
+
+	if err := s.sender.SendEmailChangedEmail(oldEmail, user.Email); err != nil {
+		log.Errorf("notifying old address of user %d: %v", user.ID, err)
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		writeJSON(w, http.StatusOK, user)
+		return
+	}
+
+	s.writeEmailVerification(w, http.StatusOK, emailVerification{Confirmed: true, Email: user.Email})
+}
+
+func (s *Server) writeEmailVerification(w http.ResponseWriter, status int, page emailVerification) {
+	page.Action = s.publicURL + "/email/verify"
+
+	var body bytes.Buffer
+	if err := emailVerificationPage.Execute(&body, page); err != nil {
+		log.Error(err)
+		http.Error(w, "could not render the page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		log.Error("Failed to write email verification page:", err)
+	}
+}
+
+func (s *Server) changeMyPassword(w http.ResponseWriter, r *http.Request) {
+	var request ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePassword("new_password", request.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.CurrentPassword)); err != nil {
+		http.Error(w, "Wrong password", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.db.SetUserPassword(user.ID, request.NewPassword); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"game-student-go/internal/database"
+	"game-student-go/internal/gamification"
+	"net/http"
+)
+
+func (s *Server) startTraining(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	if !s.requirePrerequisites(w, userID, training.CourseID) {
+		return
+	}
+
+	progress, err := s.db.StartTraining(userID, training.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *Server) completeTraining(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	blocked, err := s.quizBlocksCompletion(userID, training.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if blocked {
+		http.Error(w, "The training quiz must be passed first", http.StatusConflict)
+		return
+	}
+
+	progress, err := s.db.CompleteTraining(userID, training.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	s.issueCertificateIfFinished(userID, training.CourseID)
+	s.grantXP(userID, gamification.ReasonTrainingCompleted, training.ID, training.CourseID)
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *Server) updateTrainingPosition(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	var request TrainingPositionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.PositionSeconds < 0 {
+		http.Error(w, "position_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	progress, err := s.db.UpdateTrainingPosition(claimsFromContext(r.Context()).UserID, training.ID, request.PositionSeconds)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *Server) getCourseProgress(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.db.GetCourseByID(courseID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	progress, err := s.db.GetCourseProgress(claimsFromContext(r.Context()).UserID, courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// continueCourse returns the first training of the course, by sequence, that
+// the caller has not completed yet. It answers 204 once every training is done.
+func (s *Server) continueCourse(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.db.GetCourseByID(courseID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	training, err := s.db.GetNextTraining(claimsFromContext(r.Context()).UserID, courseID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, training)
+}
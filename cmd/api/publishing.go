@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/model"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// previewFor returns the unpublished courses the caller may see: all of them
+// for admins and the ones they teach for instructors.
+func previewFor(claims *JWTClaims) model.Preview {
+	switch {
+	case hasRole(claims, model.RoleAdmin):
+		return model.Preview{All: true}
+	case hasRole(claims, model.RoleInstructor):
+		return model.Preview{InstructorID: claims.UserID}
+	}
+
+	return model.Preview{}
+}
+
+// canManage tells whether the caller may edit the course and preview it
+// before it is published.
+func canManage(claims *JWTClaims, course model.Course) bool {
+	preview := previewFor(claims)
+	if preview.All {
+		return true
+	}
+
+	for _, instructorID := range course.InstructorIDs {
+		if preview.InstructorID != 0 && instructorID == preview.InstructorID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func canSeeCourse(claims *JWTClaims, course model.Course) bool {
+	return course.Status == model.PublicationPublished || canManage(claims, course)
+}
+
+// canSeeTraining tells whether the caller may see the training. A published
+// training stays hidden while its course is not published.
+func canSeeTraining(claims *JWTClaims, training model.Training, course model.Course) bool {
+	if training.Status == model.PublicationPublished && course.Status == model.PublicationPublished {
+		return true
+	}
+
+	return canManage(claims, course)
+}
+
+// managedCourse loads the course in the id path variable and checks the
+// caller may edit it, writing the error response when not.
+func (s *Server) managedCourse(w http.ResponseWriter, r *http.Request) (model.Course, bool) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return model.Course{}, false
+	}
+
+	course, err := s.db.GetCourseByID(courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return model.Course{}, false
+	}
+
+	if !canManage(claimsFromContext(r.Context()), course) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return model.Course{}, false
+	}
+
+	return course, true
+}
+
+// managedTraining is managedCourse for the training in the id path variable
+// and its course.
+func (s *Server) managedTraining(w http.ResponseWriter, r *http.Request) (model.Training, model.Course, bool) {
+	trainingID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return model.Training{}, model.Course{}, false
+	}
+
+	training, err := s.db.GetTrainingByID(trainingID)
+	if err != nil {
+		writeDBError(w, err)
+		return model.Training{}, model.Course{}, false
+	}
+
+	course, err := s.db.GetCourseByID(training.CourseID)
+	if err != nil {
+		writeDBError(w, err)
+		return model.Training{}, model.Course{}, false
+	}
+
+	if !canManage(claimsFromContext(r.Context()), course) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return model.Training{}, model.Course{}, false
+	}
+
+	return training, course, true
+}
+
+// visibleTraining loads the training in the id path variable and its course,
+// answering 404 when the caller may not see it.
+func (s *Server) visibleTraining(w http.ResponseWriter, r *http.Request) (model.Training, model.Course, bool) {
+	trainingID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return model.Training{}, model.Course{}, false
+	}
+
+	training, err := s.db.GetTrainingByID(trainingID)
+	if err != nil {
+		writeDBError(w, err)
+		return model.Training{}, model.Course{}, false
+	}
+
+	course, err := s.db.GetCourseByID(training.CourseID)
+	if err != nil {
+		writeDBError(w, err)
+		return model.Training{}, model.Course{}, false
+	}
+
+	if !canSeeTraining(claimsFromContext(r.Context()), training, course) {
+		http.Error(w, fmt.Sprintf("no training found with id %d", trainingID), http.StatusNotFound)
+		return model.Training{}, model.Course{}, false
+	}
+
+	return training, course, true
+}
+
+func decodePublication(r *http.Request) (PublicationRequest, error) {
+	var request PublicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return PublicationRequest{}, err
+	}
+
+	switch request.Status {
+	case model.PublicationScheduled:
+		if request.PublishAt == nil || !request.PublishAt.After(time.Now()) {
+			return PublicationRequest{}, errors.New("scheduled content needs a publish_at in the future")
+		}
+	case model.PublicationDraft, model.PublicationPublished, model.PublicationArchived:
+		if request.PublishAt != nil {
+			return PublicationRequest{}, errors.New("publish_at is only accepted for scheduled content")
+		}
+	default:
+		return PublicationRequest{}, errors.New("status must be draft, scheduled, published or archived")
+	}
+
+	return request, nil
+}
+
+func (s *Server) setCourseStatus(w http.ResponseWriter, r *http.Request) {
+	course, ok := s.managedCourse(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := decodePublication(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	course, err = s.db.SetCourseStatus(course.ID, request.Status, request.PublishAt)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, course)
+}
+
+func (s *Server) setTrainingStatus(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.managedTraining(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := decodePublication(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	training, err = s.db.SetTrainingStatus(training.ID, request.Status, request.PublishAt)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, training)
+}
+
+// publishScheduled puts scheduled content live, then tells students about
+// the courses that just went live. Courses are marked announced before any
+// email goes out, so a failing run never announces a course twice.
+func (s *Server) publishScheduled(_ context.Context) error {
+	if err := s.db.PublishScheduled(time.Now()); err != nil {
+		return err
+	}
+
+	courses, err := s.db.ClaimUnannouncedCourses()
+	if err != nil || len(courses) == 0 {
+		return err
+	}
+
+	users, err := s.db.GetUsers()
+	if err != nil {
+		return fmt.Errorf("listing users to announce courses: %w", err)
+	}
+
+	for _, course := range courses {
+		course := course
+		courseURL := fmt.Sprintf("%s/courses/%d", s.publicURL, course.ID)
+		s.sendInBackground(fmt.Sprintf("announcing course %d", course.ID), func() error {
+			for _, user := range users {
+				if err := s.sender.SendNewCourseEmail(user.Email, course.Name, courseURL); err != nil {
+					log.Errorf("announcing course %d to user %d: %v", course.ID, user.ID, err)
+				}
+			}
+			return nil
+		})
+	}
+
+	return nil
+}
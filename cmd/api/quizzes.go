@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"game-student-go/internal/database"
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/model"
+	"game-student-go/internal/quizzes"
+	"net/http"
+)
+
+func (s *Server) saveQuiz(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.managedTraining(w, r)
+	if !ok {
+		return
+	}
+
+	var quiz model.Quiz
+	if err := json.NewDecoder(r.Body).Decode(&quiz); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	quiz.TrainingID = training.ID
+
+	if err := quizzes.Validate(quiz); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.db.SaveQuiz(quiz)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// getQuiz shows the quiz of a training. Only staff get to see the answers.
+func (s *Server) getQuiz(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	quiz, err := s.db.GetQuizByTraining(training.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	claims := claimsFromContext(r.Context())
+	if !hasRole(claims, model.RoleInstructor, model.RoleAdmin) {
+		*quiz = quiz.WithoutAnswers()
+	}
+
+	writeJSON(w, http.StatusOK, quiz)
+}
+
+func (s *Server) submitQuizAttempt(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	var request QuizAttemptRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	quiz, err := s.db.GetQuizByTraining(training.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	if !s.requirePrerequisites(w, userID, training.CourseID) {
+		return
+	}
+
+	score, passed := quizzes.Grade(*quiz, request.Answers)
+	attempt, err := s.db.AddQuizAttempt(model.QuizAttempt{
+		QuizID:  quiz.ID,
+		UserID:  userID,
+		Score:   score,
+		Passed:  passed,
+		Answers: request.Answers,
+	}, quiz.MaxAttempts)
+	if errors.Is(err, database.ErrConflict) {
+		http.Error(w, "No attempts left for this quiz", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if attempt.Passed {
+		s.grantXP(userID, gamification.ReasonQuizPassed, quiz.ID, training.CourseID)
+	}
+
+	writeJSON(w, http.StatusCreated, attempt)
+}
+
+func (s *Server) listQuizAttempts(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	quiz, err := s.db.GetQuizByTraining(training.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	attempts, err := s.db.GetQuizAttempts(quiz.ID, claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attempts)
+}
+
+// quizBlocksCompletion reports whether the training has a quiz that must be
+// passed before it counts as completed and the user has not passed it yet.
+func (s *Server) quizBlocksCompletion(userID, trainingID int) (bool, error) {
+	quiz, err := s.db.GetQuizByTraining(trainingID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !quiz.RequiredForCompletion {
+		return false, nil
+	}
+
+	passed, err := s.db.HasPassedQuiz(quiz.ID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return !passed, nil
+}
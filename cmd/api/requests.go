@@ -1,5 +1,12 @@
 package main
 
+import (
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/listing"
+	"game-student-go/internal/model"
+	"time"
+)
+
 type CreateUserRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -14,6 +21,14 @@ type SignInRequest struct {
 	Password string `json:"password"`
 }
 
+// OAuthSigninRequest collects a sign in finished at a provider. The
+// password is only needed when the provider account is being linked to an
+// existing account.
+type OAuthSigninRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
 type AddCardRequest struct {
 	PaymentMethodID string `json:"payment_method_id"`
 }
@@ -23,3 +38,273 @@ type ChargeRequest struct {
 	Currency    string `json:"currency"`
 	Description string `json:"description"`
 }
+
+type TrainingPositionRequest struct {
+	PositionSeconds int `json:"position_seconds"`
+}
+
+type RevokeCertificateRequest struct {
+	Reason string `json:"reason"`
+}
+
+type CertificateVerificationResponse struct {
+	Code        string     `json:"code"`
+	StudentName string     `json:"student_name"`
+	CourseName  string     `json:"course_name"`
+	IssuedAt    time.Time  `json:"issued_at"`
+	Valid       bool       `json:"valid"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+type SubmissionRequest struct {
+	URL string `json:"url"`
+}
+
+type ReviewSubmissionRequest struct {
+	Status   string `json:"status"`
+	Feedback string `json:"feedback"`
+}
+
+type QuizAttemptRequest struct {
+	Answers []model.QuizAnswer `json:"answers"`
+}
+
+type BadgeResponse struct {
+	gamification.Badge
+	AwardedAt time.Time `json:"awarded_at"`
+}
+
+type LeaderboardOptOutRequest struct {
+	OptOut bool `json:"opt_out"`
+}
+
+type TimeZoneRequest struct {
+	TimeZone string `json:"time_zone"`
+}
+
+type SearchResponse struct {
+	Query   string               `json:"query"`
+	Fuzzy   bool                 `json:"fuzzy"`
+	Results []model.SearchResult `json:"results"`
+}
+
+type CategoryRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type CourseTaxonomyRequest struct {
+	CategoryID *int     `json:"category_id"`
+	Difficulty string   `json:"difficulty"`
+	Tags       []string `json:"tags"`
+}
+
+type PublicationRequest struct {
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+type PrerequisiteRequest struct {
+	Required bool `json:"required"`
+}
+
+type LearningPathRequest struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CourseIDs   []int  `json:"course_ids"`
+}
+
+type InstructorRequest struct {
+	DisplayName   string            `json:"display_name"`
+	Bio           string            `json:"bio"`
+	AvatarURL     string            `json:"avatar_url"`
+	SocialLinks   map[string]string `json:"social_links"`
+	PayoutAccount string            `json:"payout_account"`
+}
+
+type CreateInstructorRequest struct {
+	UserID int `json:"user_id"`
+}
+
+type CourseInstructorsRequest struct {
+	InstructorIDs []int `json:"instructor_ids"`
+}
+
+type InstructorPageResponse struct {
+	model.Instructor
+	Courses listing.Page[model.Course] `json:"courses"`
+}
+
+type ReviewRequest struct {
+	Rating int    `json:"rating"`
+	Body   string `json:"body"`
+}
+
+type ReviewReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ReviewReplyRequest struct {
+	Body string `json:"body"`
+}
+
+type ReviewHiddenRequest struct {
+	Hidden bool `json:"hidden"`
+}
+
+type QuestionRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type AnswerRequest struct {
+	Body     string `json:"body"`
+	ParentID *int   `json:"parent_id"`
+}
+
+type AcceptAnswerRequest struct {
+	AnswerID *int `json:"answer_id"`
+}
+
+// ModerationRequest changes only the flags it carries.
+type ModerationRequest struct {
+	Hidden *bool `json:"hidden"`
+	Locked *bool `json:"locked"`
+}
+
+type NoteRequest struct {
+	PositionSeconds int    `json:"position_seconds"`
+	Body            string `json:"body"`
+}
+
+type CohortRequest struct {
+	Name     string    `json:"name"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Capacity int       `json:"capacity"`
+	Price    int64     `json:"price"`
+	Currency string    `json:"currency"`
+}
+
+// EnrollmentRequest carries the Stripe payment method paying for a cohort.
+type EnrollmentRequest struct {
+	PaymentMethodID string `json:"payment_method_id"`
+}
+
+type LiveSessionRequest struct {
+	Title           string    `json:"title"`
+	StartsAt        time.Time `json:"starts_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	MeetingURL      string    `json:"meeting_url"`
+}
+
+type CalendarResponse struct {
+	URL string `json:"url"`
+}
+
+type JamRequest struct {
+	Title              string    `json:"title"`
+	Description        string    `json:"description"`
+	Theme              string    `json:"theme"`
+	ThemeRevealAt      time.Time `json:"theme_reveal_at"`
+	SubmissionDeadline time.Time `json:"submission_deadline"`
+	VotingEndsAt       time.Time `json:"voting_ends_at"`
+	MaxTeamSize        int       `json:"max_team_size"`
+	Criteria           []string  `json:"criteria"`
+}
+
+type JamTeamRequest struct {
+	Name string `json:"name"`
+}
+
+type JoinJamTeamRequest struct {
+	InviteCode string `json:"invite_code"`
+}
+
+type JamInviteRequest struct {
+	Email string `json:"email"`
+}
+
+type JamEntryRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	BuildURL    string `json:"build_url"`
+}
+
+// JamVoteRequest scores an entry from 1 to 5 per criterion id.
+type JamVoteRequest struct {
+	Scores map[int]int `json:"scores"`
+}
+
+type JamResultsResponse struct {
+	Jam     model.GameJam    `json:"jam"`
+	Entries []model.JamEntry `json:"entries"`
+}
+
+type PortfolioRequest struct {
+	Slug        string `json:"slug"`
+	DisplayName string `json:"display_name"`
+	Headline    string `json:"headline"`
+	Public      bool   `json:"public"`
+}
+
+type PortfolioItemVisibilityRequest struct {
+	Visible bool `json:"visible"`
+}
+
+// ProfileRequest updates only the fields that are present.
+type ProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+	Bio         *string `json:"bio"`
+	Locale      *string `json:"locale"`
+	TimeZone    *string `json:"time_zone"`
+	Country     *string `json:"country"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// TwoFactorSignInRequest takes either a code from the authenticator app or
+// a recovery code.
+type TwoFactorSignInRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+type TwoFactorEnrollmentResponse struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type TwoFactorRolesRequest struct {
+	Roles []string `json:"roles"`
+}
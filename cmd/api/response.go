@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Failed to encode response:", err)
+	}
+}
+
+// writeDBError maps database errors to a response, answering 404 for
+// lookups that matched nothing and 409 for duplicates.
+func writeDBError(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, database.ErrConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Error(err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func pathID(r *http.Request, name string) (int, error) {
+	idStr, ok := mux.Vars(r)[name]
+	if !ok {
+		return 0, fmt.Errorf("missing %s", name)
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s format", name)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/listing"
+	"game-student-go/internal/model"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxReviewLength = 5000
+
+func decodeReview(r *http.Request) (ReviewRequest, error) {
+	var request ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return ReviewRequest{}, err
+	}
+
+	if request.Rating < 1 || request.Rating > 5 {
+		return ReviewRequest{}, errors.New("rating must be between 1 and 5")
+	}
+
+	request.Body = strings.TrimSpace(request.Body)
+	if len(request.Body) > maxReviewLength {
+		return ReviewRequest{}, errors.New("review is too long")
+	}
+
+	return request, nil
+}
+
+// createReview lets a student rate a course they are enrolled in, which is
+// any course they started a training of.
+func (s *Server) createReview(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request, err := decodeReview(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	claims := claimsFromContext(r.Context())
+	if !canSeeCourse(claims, course) {
+		http.Error(w, fmt.Sprintf("no course found with id %d", courseID), http.StatusNotFound)
+		return
+	}
+
+	userID := claims.UserID
+	enrolled, err := s.db.HasStartedCourse(userID, courseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if !enrolled {
+		http.Error(w, "Only students enrolled in the course can review it", http.StatusForbidden)
+		return
+	}
+
+	review, err := s.db.CreateReview(model.Review{CourseID: courseID, UserID: userID, Rating: request.Rating, Body: request.Body})
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, review)
+}
+
+func (s *Server) updateReview(w http.ResponseWriter, r *http.Request) {
+	reviewID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request, err := decodeReview(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review, err := s.db.GetReview(reviewID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if review.UserID != claimsFromContext(r.Context()).UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	review, err = s.db.UpdateReview(reviewID, request.Rating, request.Body)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, review)
+}
+
+// getCourseReviews lists the reviews of a course one page at a time, most
+// helpful first unless ?sort= says otherwise. ?sort=-created_at shows the
+// most recent first.
+func (s *Server) getCourseReviews(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	page, err := listing.Parse(query, database.ReviewSorts...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("sort") == "" {
+		page.Desc = true
+	}
+
+	reviews, err := s.db.GetCourseReviews(courseID, page)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listing.NewPage(reviews, page.Limit, func(review model.Review) listing.Cursor {
+		if page.Sort == "created_at" {
+			return listing.Cursor{Value: review.CreatedAt.Format(time.RFC3339Nano), ID: review.ID}
+		}
+		return listing.Cursor{Value: strconv.Itoa(review.HelpfulCount), ID: review.ID}
+	}))
+}
+
+func (s *Server) setReviewHelpful(helpful bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviewID, err := pathID(r, "id")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		review, err := s.db.GetReview(reviewID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		userID := claimsFromContext(r.Context()).UserID
+		if review.UserID == userID {
+			http.Error(w, "You cannot vote on your own review", http.StatusForbidden)
+			return
+		}
+
+		review, err = s.db.SetReviewHelpful(reviewID, userID, helpful)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, review)
+	}
+}
+
+func (s *Server) reportReview(w http.ResponseWriter, r *http.Request) {
+	reviewID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var request ReviewReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.ReportReview(reviewID, claimsFromContext(r.Context()).UserID, request.Reason); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replyToReview posts the public reply of one of the course instructors.
+func (s *Server) replyToReview(w http.ResponseWriter, r *http.Request) {
+	reviewID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var request ReviewReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request.Body = strings.TrimSpace(request.Body)
+	if request.Body == "" || len(request.Body) > maxReviewLength {
+		http.Error(w, "reply must have between 1 and 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	review, err := s.db.GetReview(reviewID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(review.CourseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	claims := claimsFromContext(r.Context())
+	if !canManage(claims, course) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	review, err = s.db.ReplyToReview(reviewID, claims.UserID, request.Body)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, review)
+}
+
+func (s *Server) listReportedReviews(w http.ResponseWriter, _ *http.Request) {
+	reviews, err := s.db.GetReportedReviews()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reviews)
+}
+
+func (s *Server) setReviewHidden(w http.ResponseWriter, r *http.Request) {
+	reviewID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var request ReviewHiddenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review, err := s.db.SetReviewHidden(reviewID, request.Hidden)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, review)
+}
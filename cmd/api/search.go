@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// searchCatalog serves /search?q=. When full text search finds nothing, most
+// likely because of a typo, it retries with trigram similarity and flags the
+// response as fuzzy.
+func (s *Server) searchCatalog(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	results, err := s.db.SearchCatalog(query, limit)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	response := SearchResponse{Query: query, Results: results}
+	if len(results) == 0 {
+		response.Fuzzy = true
+		response.Results, err = s.db.FuzzySearchCatalog(query, limit)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
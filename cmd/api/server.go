@@ -3,10 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"game-student-go/internal/database"
+	"game-student-go/internal/jwtkeys"
+	"game-student-go/internal/listing"
+	"game-student-go/internal/lockout"
 	"game-student-go/internal/model"
 	"game-student-go/internal/notifications"
+	"game-student-go/internal/oauth"
+	"game-student-go/internal/storage"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"github.com/newrelic/go-agent/v3/newrelic"
@@ -17,32 +23,50 @@ import (
 	"github.com/stripe/stripe-go/v74/paymentintent"
 	"github.com/stripe/stripe-go/v74/paymentmethod"
 	"github.com/stripe/stripe-go/v74/setupintent"
-	"golang.org/x/crypto/bcrypt"
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 )
 
 type Server struct {
 	db          database.Client
-	jwtKey      string
+	keys        *jwtkeys.KeySet
 	newRelicApp *newrelic.Application
 	sender      *notifications.Sender
+	publicURL   string
+	store       storage.Storage
+
+	accountLockout   lockout.Policy
+	ipLockout        lockout.Policy
+	oauthProviders   map[string]*oauth.Provider
+	oauthClient      *http.Client
+	oauthCompleteURL string
+	emails           sync.WaitGroup
 	http.Server
 }
 
 type JWTClaims struct {
-	Email string `json:"email"`
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	TwoFactor bool   `json:"two_factor,omitempty"`
 	jwt.StandardClaims
+
+	// twoFactorMissing is set by authenticate when the caller's role requires
+	// 2FA and the token was issued without it. See hasRole.
+	twoFactorMissing bool
 }
 
-func NewServer(port int, db database.Client, jwtKey string, newRelicApp *newrelic.Application, sender *notifications.Sender) *Server {
+func NewServer(port int, db database.Client, keys *jwtkeys.KeySet, newRelicApp *newrelic.Application, sender *notifications.Sender, publicURL string, store storage.Storage) *Server {
 	s := &Server{
 		db:          db,
-		jwtKey:      jwtKey,
+		keys:        keys,
 		newRelicApp: newRelicApp,
 		sender:      sender,
+		publicURL:   publicURL,
+		store:       store,
 	}
 	s.Addr = fmt.Sprintf("0.0.0.0:%d", port)
 	return s
@@ -53,15 +77,157 @@ func (s *Server) Run() error {
 
 	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/users", s.createUser)).Methods("POST")
 	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/signin", s.Signin)).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/.well-known/jwks.json", s.getJWKS)).Methods("GET")
 	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/users/{id}", s.authenticate(s.GetUserByID))).Methods("GET")
-	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses", s.getCourses)).Methods("GET")
-	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}", s.getCourseByID)).Methods("GET")
-	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}", s.getTrainingByID)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses", s.identify(s.getCourses))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id:[0-9]+}", s.identify(s.getCourseByID))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}", s.identify(s.getTrainingByID))).Methods("GET")
 	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/users/{id}/card", s.authenticate(s.addCard))).Methods("POST")
 	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/users/{id}/cards", s.listCards)).Methods("GET")
 	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/users/{id}/cards/{paym_id}/authorize", s.authenticate(s.authorizePayment))).Methods("POST")
-	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/payment/{payment_id}/capture", s.authenticate(s.captureFunds))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/payment/{payment_id}/capture", s.authenticate(s.authorize(s.captureFunds, model.RoleAdmin)))).Methods("POST")
 	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/stripe/webhook", s.handleStripeWebhook)).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/start", s.authenticate(s.startTraining))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/complete", s.authenticate(s.completeTraining))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/position", s.authenticate(s.updateTrainingPosition))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/progress", s.authenticate(s.getCourseProgress))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/continue", s.authenticate(s.continueCourse))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/certificates", s.authenticate(s.listMyCertificates))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/certificates/{code}", s.verifyCertificate)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/certificates/{code}/pdf", s.downloadCertificate)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/certificates/{code}/revoke", s.authenticate(s.authorize(s.revokeCertificate, model.RoleAdmin)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/submissions", s.authenticate(s.createSubmission))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/submissions", s.authenticate(s.listMySubmissions))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/submissions/{id}", s.authenticate(s.getSubmission))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/submissions/{id}", s.authenticate(s.resubmitSubmission))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/submissions/{id}/attachment", s.authenticate(s.downloadSubmissionAttachment))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/submissions/{id}/review", s.authenticate(s.authorize(s.reviewSubmission, model.RoleInstructor, model.RoleAdmin)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/quiz", s.authenticate(s.getQuiz))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/quiz", s.authenticate(s.authorize(s.saveQuiz, model.RoleInstructor, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/quiz/attempts", s.authenticate(s.submitQuizAttempt))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/quiz/attempts", s.authenticate(s.listQuizAttempts))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/xp", s.authenticate(s.getMyXP))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/xp/ledger", s.authenticate(s.getMyXPLedger))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/badges", s.authenticate(s.getMyBadges))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/xp/recompute", s.authenticate(s.authorize(s.recomputeXP, model.RoleAdmin)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/review/submissions", s.authenticate(s.authorize(s.listReviewQueue, model.RoleInstructor, model.RoleAdmin)))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/leaderboards/{scope}", s.authenticate(s.getLeaderboard))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/leaderboard", s.authenticate(s.setLeaderboardOptOut))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/activity", s.authenticate(s.getMyActivity))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/streak", s.authenticate(s.getMyStreak))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/time-zone", s.authenticate(s.setMyTimeZone))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/search", s.searchCatalog)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/facets", s.getCourseFacets)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/categories", s.getCategories)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/tags", s.getTags)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/categories", s.authenticate(s.authorize(s.createCategory, model.RoleAdmin)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/categories/{id}", s.authenticate(s.authorize(s.updateCategory, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/categories/{id}", s.authenticate(s.authorize(s.deleteCategory, model.RoleAdmin)))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/courses/{id}/taxonomy", s.authenticate(s.authorize(s.setCourseTaxonomy, model.RoleAdmin, model.RoleInstructor)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/courses/{id}/status", s.authenticate(s.authorize(s.setCourseStatus, model.RoleAdmin, model.RoleInstructor)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/trainings/{id}/status", s.authenticate(s.authorize(s.setTrainingStatus, model.RoleAdmin, model.RoleInstructor)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/prerequisites", s.getPrerequisites)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/eligibility", s.authenticate(s.getMyEligibility))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/courses/{id}/prerequisites/{prerequisite_id}", s.authenticate(s.authorize(s.addPrerequisite, model.RoleAdmin, model.RoleInstructor)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/courses/{id}/prerequisites/{prerequisite_id}", s.authenticate(s.authorize(s.removePrerequisite, model.RoleAdmin, model.RoleInstructor)))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/paths", s.getLearningPaths)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/paths/{id}", s.getLearningPath)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/paths/{id}/progress", s.authenticate(s.getLearningPathProgress))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/paths", s.authenticate(s.authorize(s.createLearningPath, model.RoleAdmin)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/paths/{id}", s.authenticate(s.authorize(s.updateLearningPath, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/paths/{id}", s.authenticate(s.authorize(s.deleteLearningPath, model.RoleAdmin)))).Methods("DELETE")
+
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/instructors/{id}", s.getInstructorPage)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/instructor", s.authenticate(s.authorize(s.getMyInstructorProfile, model.RoleInstructor, model.RoleAdmin)))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/instructor", s.authenticate(s.authorize(s.updateMyInstructorProfile, model.RoleInstructor, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/instructors", s.authenticate(s.authorize(s.createInstructor, model.RoleAdmin)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/courses/{id}/instructors", s.authenticate(s.authorize(s.setCourseInstructors, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/reviews", s.getCourseReviews)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/reviews", s.authenticate(s.createReview))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/reviews/{id}", s.authenticate(s.updateReview))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/reviews/{id}/helpful", s.authenticate(s.setReviewHelpful(true)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/reviews/{id}/helpful", s.authenticate(s.setReviewHelpful(false)))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/reviews/{id}/report", s.authenticate(s.reportReview))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/reviews/{id}/reply", s.authenticate(s.authorize(s.replyToReview, model.RoleInstructor, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/reviews/reported", s.authenticate(s.authorize(s.listReportedReviews, model.RoleAdmin)))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/reviews/{id}/hidden", s.authenticate(s.authorize(s.setReviewHidden, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/questions", s.authenticate(s.getTrainingQuestions))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/questions", s.authenticate(s.askQuestion))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}", s.authenticate(s.getThread))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}", s.authenticate(s.updateQuestion))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}", s.authenticate(s.deleteQuestion))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}/answers", s.authenticate(s.answerQuestion))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}/upvote", s.authenticate(s.voteQuestion(true)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}/upvote", s.authenticate(s.voteQuestion(false)))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}/accepted-answer", s.authenticate(s.acceptAnswer))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/questions/{id}/moderation", s.authenticate(s.moderateQuestion))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/answers/{id}", s.authenticate(s.updateAnswer))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/answers/{id}", s.authenticate(s.deleteAnswer))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/answers/{id}/upvote", s.authenticate(s.voteAnswer(true)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/answers/{id}/upvote", s.authenticate(s.voteAnswer(false)))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/answers/{id}/moderation", s.authenticate(s.moderateAnswer))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/notes", s.authenticate(s.getTrainingNotes))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/notes", s.authenticate(s.createNote))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/notes/{id}", s.authenticate(s.updateNote))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/notes/{id}", s.authenticate(s.deleteNote))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/notes", s.authenticate(s.searchNotes))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/notes/export", s.authenticate(s.exportCourseNotes))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/bookmark", s.authenticate(s.addBookmark))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/trainings/{id}/bookmark", s.authenticate(s.removeBookmark))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/bookmarks", s.authenticate(s.getBookmarks))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/cohorts", s.identify(s.getCourseCohorts))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/courses/{id}/cohorts", s.authenticate(s.createCohort))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/cohorts/{id}", s.identify(s.getCohort))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/cohorts/{id}", s.authenticate(s.updateCohort))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/cohorts/{id}", s.authenticate(s.deleteCohort))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/cohorts/{id}/enrollment", s.authenticate(s.enrollInCohort))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/cohorts/{id}/enrollments", s.authenticate(s.getCohortEnrollments))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/cohorts/{id}/sessions", s.authenticate(s.getCohortSessions))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/cohorts/{id}/sessions", s.authenticate(s.createLiveSession))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/sessions/{id}", s.authenticate(s.updateLiveSession))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/sessions/{id}", s.authenticate(s.deleteLiveSession))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/cohorts", s.authenticate(s.getMyCohorts))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/calendar", s.authenticate(s.getMyCalendar))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/calendar/{token:[0-9a-f]+}.ics", s.getCalendarFeed)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/jams", s.identify(s.listJams))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/jams/{id}", s.identify(s.getJam))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/jams", s.authenticate(s.authorize(s.createJam, model.RoleAdmin)))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/jams/{id}", s.authenticate(s.authorize(s.updateJam, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/jams/{id}/teams", s.identify(s.listJamTeams))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/jams/{id}/teams", s.authenticate(s.createJamTeam))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/jams/{id}/teams/join", s.authenticate(s.joinJamTeam))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/teams/{id}/invites", s.authenticate(s.inviteToJamTeam))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/teams/{id}/members/me", s.authenticate(s.leaveJamTeam))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/teams/{id}/entry", s.identify(s.getTeamEntry))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/teams/{id}/entry", s.authenticate(s.submitJamEntry))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/jams/{id}/entries", s.identify(s.listJamEntries))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/jams/{id}/results", s.identify(s.getJamResults))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/entries/{id}/build", s.identify(s.downloadJamBuild))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/entries/{id}/screenshots/{index}", s.identify(s.getJamScreenshot))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/entries/{id}/votes", s.authenticate(s.voteJamEntry))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/portfolio", s.authenticate(s.getMyPortfolio))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/portfolio", s.authenticate(s.saveMyPortfolio))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/portfolio/items/{kind}/{key}", s.authenticate(s.setPortfolioItemVisibility))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/portfolio/{slug}", s.getPublicPortfolio)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me", s.authenticate(s.getMe))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me", s.authenticate(s.updateMe))).Methods("PATCH")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/email", s.authenticate(s.changeMyEmail))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/email/verify", s.showEmailVerification)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/email/verify", s.verifyEmail)).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/password", s.authenticate(s.changeMyPassword))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me", s.authenticate(s.deleteMe))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/exports", s.authenticate(s.requestDataExport))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/exports", s.authenticate(s.listMyDataExports))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/exports/{id}/download", s.authenticate(s.downloadDataExport))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/signin/2fa", s.SigninTwoFactor)).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/2fa", s.authenticate(s.enrollTwoFactor))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/2fa", s.authenticate(s.disableTwoFactor))).Methods("DELETE")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/me/2fa/verify", s.authenticate(s.verifyTwoFactor))).Methods("POST")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/security/two-factor-roles", s.authenticate(s.authorize(s.getTwoFactorRoles, model.RoleAdmin)))).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/admin/security/two-factor-roles", s.authenticate(s.authorize(s.setTwoFactorRoles, model.RoleAdmin)))).Methods("PUT")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/auth/{provider}/login", s.startOAuthSignin)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/auth/{provider}/callback", s.finishOAuthSignin)).Methods("GET")
+	router.HandleFunc(newrelic.WrapHandleFunc(s.newRelicApp, "/auth/signin", s.collectOAuthSignin)).Methods("POST")
 
 	s.Handler = router
 
@@ -70,6 +236,27 @@ func (s *Server) Run() error {
 	return s.ListenAndServe()
 }
 
+// registerUser creates an account along with its Stripe customer.
+func (s *Server) registerUser(email, password string) (model.User, error) {
+	params := &stripe.CustomerParams{
+		Email: stripe.String(email),
+	}
+	stripeCustomer, err := customer.New(params)
+	if err != nil {
+		return model.User{}, fmt.Errorf("creating Stripe customer: %w", err)
+	}
+
+	user, err := s.db.CreateUser(email, password, stripeCustomer.ID)
+	if err != nil {
+		if _, delErr := customer.Del(stripeCustomer.ID, nil); delErr != nil {
+			log.Errorf("deleting Stripe customer %s of failed registration: %v", stripeCustomer.ID, delErr)
+		}
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
 func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
 	var request CreateUserRequest
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -78,17 +265,16 @@ func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	params := &stripe.CustomerParams{
-		Email: stripe.String(request.Email),
-	}
-	stripeCustomer, err := customer.New(params)
-	if err != nil {
-		log.Error("Failed to create Stripe customer:", err)
-		http.Error(w, "Failed to create Stripe customer", http.StatusInternalServerError)
+	if err := validatePassword("password", request.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	user, err := s.db.CreateUser(request.Email, request.Password, stripeCustomer.ID)
+	user, err := s.registerUser(request.Email, request.Password)
+	if errors.Is(err, database.ErrConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		log.Error("Failed to create user:", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -124,28 +310,61 @@ func (s *Server) Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := s.db.GetUserByEmail(creds.Email)
+	ip := clientIP(r)
+	if !s.beginSignin(w, creds.Email, ip) {
+		return
+	}
+
+	user, ok, err := s.checkPassword(creds.Email, creds.Password)
 	if err != nil {
+		log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(creds.Password)); err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+	if !ok {
+		http.Error(w, signinFailedMessage, http.StatusUnauthorized)
+		return
+	}
+
+	s.passwordAccepted(*user, ip)
+	s.completeSignin(w, *user)
+}
+
+// completeSignin answers a sign in whose first factor checked out: users
+// with 2FA still have to prove it, the others get their token.
+func (s *Server) completeSignin(w http.ResponseWriter, user model.User) {
+	if user.TwoFactorEnabled {
+		s.challengeTwoFactor(w, user)
 		return
 	}
 
-	expirationTime := time.Now().Add(5 * time.Minute)
+	s.writeToken(w, user, false)
+}
+
+// tokenTTL is how long tokens last. Keys retired from signing must be kept
+// for verification at least this long.
+const tokenTTL = 5 * time.Minute
+
+// writeToken answers a successful sign in with a token for user. twoFactor
+// tells whether the user also proved a second factor.
+func (s *Server) writeToken(w http.ResponseWriter, user model.User, twoFactor bool) {
+	now := time.Now()
 	claims := &JWTClaims{
-		Email: creds.Email,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		TwoFactor: twoFactor,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			Issuer:    s.publicURL,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(tokenTTL).Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.jwtKey))
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
+		log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -157,6 +376,13 @@ func (s *Server) Signin(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getJWKS publishes the keys our tokens verify with. Verifiers may cache it
+// briefly; keys are published well before they start signing.
+func (s *Server) getJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, s.keys.JWKS())
+}
+
 func (s *Server) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, ok := vars["id"]
@@ -171,9 +397,17 @@ func (s *Server) GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Accounts hold the email and billing profile, so only their owner and
+	// admins see them.
+	claims := claimsFromContext(r.Context())
+	if claims.UserID != userId && !hasRole(claims, model.RoleAdmin) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	user, err := s.db.GetUserByID(userId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
@@ -185,27 +419,61 @@ func (s *Server) GetUserByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.Server.Shutdown(ctx)
+	err := s.Server.Shutdown(ctx)
+	s.emails.Wait()
+	return err
+}
+
+// sendInBackground sends an email without holding up the request or job
+// that asked for it. Failures are logged with what was being sent.
+func (s *Server) sendInBackground(what string, send func() error) {
+	s.emails.Add(1)
+	go func() {
+		defer s.emails.Done()
+		if err := send(); err != nil {
+			log.Errorf("%s: %v", what, err)
+		}
+	}()
 }
 
-func (s *Server) getCourses(w http.ResponseWriter, _ *http.Request) {
-	courses, err := s.db.GetCourses()
+// getCourses lists published courses one page at a time, filtered as
+// described in courseFilterFromQuery and paged as described in listing.Parse.
+// Admins and instructors may pass ?preview=true to include the unpublished
+// courses they manage.
+func (s *Server) getCourses(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := listing.Parse(query, database.CourseSorts...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	jsonResponse, err := json.Marshal(courses)
+	filter, err := courseFilterFromQuery(query)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_, err = w.Write(jsonResponse)
+	if query.Get("preview") == "true" {
+		filter.Preview = previewFor(claimsFromContext(r.Context()))
+		if filter.Preview == (model.Preview{}) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	courses, err := s.db.GetCourses(filter, page)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+
+	writeJSON(w, http.StatusOK, listing.NewPage(courses, page.Limit, func(course model.Course) listing.Cursor {
+		if page.Sort == "name" {
+			return listing.Cursor{Value: course.Name, ID: course.ID}
+		}
+		return listing.Cursor{ID: course.ID}
+	}))
 }
 
 func (s *Server) getCourseByID(w http.ResponseWriter, r *http.Request) {
@@ -224,7 +492,12 @@ func (s *Server) getCourseByID(w http.ResponseWriter, r *http.Request) {
 
 	course, err := s.db.GetCourseByID(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
+		return
+	}
+
+	if !canSeeCourse(claimsFromContext(r.Context()), course) {
+		http.Error(w, fmt.Sprintf("no course found with id %d", id), http.StatusNotFound)
 		return
 	}
 
@@ -257,7 +530,18 @@ func (s *Server) getTrainingByID(w http.ResponseWriter, r *http.Request) {
 
 	training, err := s.db.GetTrainingByID(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(training.CourseID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if !canSeeTraining(claimsFromContext(r.Context()), training, course) {
+		http.Error(w, fmt.Sprintf("no training found with id %d", id), http.StatusNotFound)
 		return
 	}
 
@@ -354,10 +638,23 @@ func (s *Server) listCards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the Stripe customer ID
-	customerID := user.StripeId
-
 	// List all cards for the Stripe customer
+	cards, err := stripeCards(user.StripeId)
+	if err != nil {
+		http.Error(w, "Error retrieving cards", http.StatusInternalServerError)
+		return
+	}
+
+	// Encode and return the cards
+	if err := json.NewEncoder(w).Encode(cards); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// stripeCards lists the cards saved with a Stripe customer.
+func stripeCards(customerID string) ([]model.Card, error) {
 	params := &stripe.PaymentMethodListParams{
 		Customer: stripe.String(customerID),
 		Type:     stripe.String(string(stripe.PaymentMethodTypeCard)),
@@ -376,17 +673,8 @@ func (s *Server) listCards(w http.ResponseWriter, r *http.Request) {
 		}
 		cards = append(cards, card)
 	}
-	if err := result.Err(); err != nil {
-		http.Error(w, "Error retrieving cards", http.StatusInternalServerError)
-		return
-	}
 
-	// Encode and return the cards
-	if err := json.NewEncoder(w).Encode(cards); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
+	return cards, result.Err()
 }
 
 func (s *Server) authorizePayment(w http.ResponseWriter, r *http.Request) {
@@ -520,7 +808,12 @@ func (s *Server) handleStripeWebhook(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Cohort seats are found by the payment metadata when the request
+		// that took them never got to record the payment.
 		payment, err := s.db.GetPayment(paymentIntent.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			payment, err = s.cohortPayment(&paymentIntent)
+		}
 
 		if err != nil {
 			http.Error(w, "Payment not found", http.StatusBadRequest)
@@ -535,6 +828,13 @@ func (s *Server) handleStripeWebhook(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Answer with an error so Stripe retries if the seat cannot be
+		// confirmed now.
+		if err := s.db.SetPaymentEnrollmentStatus(payment.ID, model.EnrollmentActive); err != nil {
+			writeDBError(w, err)
+			return
+		}
+
 	case "payment_intent.payment_failed":
 		var paymentIntent stripe.PaymentIntent
 		err := json.Unmarshal(event.Data.Raw, &paymentIntent)
@@ -543,6 +843,14 @@ func (s *Server) handleStripeWebhook(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		fmt.Printf("PaymentIntent failed!\n")
+
+		// Release the cohort seat the payment was holding, if any.
+		if payment, err := s.db.GetPayment(paymentIntent.ID); err == nil {
+			if err := s.db.SetPaymentEnrollmentStatus(payment.ID, model.EnrollmentCancelled); err != nil {
+				writeDBError(w, err)
+				return
+			}
+		}
 	default:
 		fmt.Fprintf(w, "Unhandled event type: %s\n", event.Type)
 		return
@@ -10,6 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"game-student-go/internal/database"
+	"game-student-go/internal/notifications"
+	"game-student-go/internal/storage"
+	"github.com/sendgrid/sendgrid-go"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"net/http"
@@ -57,7 +60,19 @@ func TestMain(m *testing.M) {
 	}
 	defer db.Close()
 
-	server = NewServer(port, db, cfg.JWTKey, nil)
+	keys, err := loadSigningKeys(cfg)
+	if err != nil {
+		log.Fatalf("loading signing keys: %v", err)
+	}
+
+	store, err := storage.NewLocalStorage(cfg.StorageDir)
+	if err != nil {
+		log.Fatalf("creating storage: %v", err)
+	}
+
+	sender := notifications.NewSender(sendgrid.NewSendClient(cfg.SendgridAPIKey))
+
+	server = NewServer(port, db, keys, nil, sender, cfg.PublicURL, store)
 
 	go func() {
 		if err := server.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
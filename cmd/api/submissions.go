@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/gamification"
+	"game-student-go/internal/model"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const maxAttachmentBytes = 50 << 20
+
+var zipMagic = []byte("PK\x03\x04")
+
+var (
+	errNotZip         = errors.New("file must be a zip archive")
+	errUploadTooLarge = fmt.Errorf("file must be at most %d MB", maxAttachmentBytes>>20)
+)
+
+// parseSubmissionRequest accepts either a JSON body or a multipart form with
+// a "url" field and an optional "file" zip upload. The caller closes the file.
+func parseSubmissionRequest(w http.ResponseWriter, r *http.Request) (string, multipart.File, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		var request SubmissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return "", nil, err
+		}
+		return request.URL, nil, validateWebURL("url", request.URL)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return "", nil, fmt.Errorf("parsing upload: %w", err)
+	}
+
+	submissionURL := r.FormValue("url")
+	if err := validateWebURL("url", submissionURL); err != nil {
+		return "", nil, err
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return submissionURL, nil, nil
+		}
+		return "", nil, fmt.Errorf("reading upload: %w", err)
+	}
+
+	return submissionURL, file, nil
+}
+
+func validateWebURL(field, value string) error {
+	parsed, err := url.ParseRequestURI(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s must be an http or https address", field)
+	}
+
+	return nil
+}
+
+// storeAttachment checks and stores the zip uploaded with the submission
+// of a training, returning its key. Every upload gets a key of its own, so
+// the submission is only written once its file is in place.
+func (s *Server) storeAttachment(userID, trainingID int, file multipart.File) (string, error) {
+	token, err := randomToken(8)
+	if err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("submissions/%d/%d/%s.zip", userID, trainingID, token)
+	return key, s.saveZip(key, file)
+}
+
+// saveZip stores an uploaded zip archive under key. Archives over
+// maxAttachmentBytes are refused rather than cut short.
+func (s *Server) saveZip(key string, file multipart.File) error {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return fmt.Errorf("reading upload: %w", err)
+	}
+	if size > maxAttachmentBytes {
+		return errUploadTooLarge
+	}
+
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(len(zipMagic))
+	if err != nil || !bytes.Equal(magic, zipMagic) {
+		return errNotZip
+	}
+
+	return s.store.Save(key, reader)
+}
+
+// writeRequestError answers a request body that could not be read: 413
+// when it was too big and 400 otherwise.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// writeUploadError answers an upload that was not stored: 413 when it is
+// too big, 400 when it is not what was asked for and 500 otherwise.
+func writeUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUploadTooLarge), errors.Is(err, errScreenshotTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errNotZip), errors.Is(err, errNotScreenshot):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// deleteUpload removes a stored upload that ended up unused.
+func (s *Server) deleteUpload(key string) {
+	if key == "" {
+		return
+	}
+
+	if err := s.store.Delete(key); err != nil {
+		log.Error(err)
+	}
+}
+
+func (s *Server) deleteUploads(keys []string) {
+	for _, key := range keys {
+		s.deleteUpload(key)
+	}
+}
+
+// canViewSubmission allows the author and the staff reviewing it.
+func canViewSubmission(claims *JWTClaims, submission *model.Submission) bool {
+	return claims.UserID == submission.UserID || hasRole(claims, model.RoleInstructor, model.RoleAdmin)
+}
+
+func (s *Server) createSubmission(w http.ResponseWriter, r *http.Request) {
+	training, _, ok := s.visibleTraining(w, r)
+	if !ok {
+		return
+	}
+
+	if !training.ProjectURL.Valid {
+		http.Error(w, "Training has no project", http.StatusBadRequest)
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	if !s.requirePrerequisites(w, userID, training.CourseID) {
+		return
+	}
+
+	submissionURL, file, err := parseSubmissionRequest(w, r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	var attachmentKey string
+	if file != nil {
+		defer file.Close()
+		if attachmentKey, err = s.storeAttachment(userID, training.ID, file); err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	}
+
+	submission, err := s.db.CreateSubmission(userID, training.ID, submissionURL, attachmentKey)
+	if err != nil {
+		s.deleteUpload(attachmentKey)
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, submission)
+}
+
+func (s *Server) resubmitSubmission(w http.ResponseWriter, r *http.Request) {
+	submissionID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	submission, err := s.db.GetSubmission(submissionID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if submission.UserID != claimsFromContext(r.Context()).UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if submission.Status == model.SubmissionApproved {
+		http.Error(w, "Submission was already approved", http.StatusConflict)
+		return
+	}
+
+	submissionURL, file, err := parseSubmissionRequest(w, r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	var attachmentKey string
+	if file != nil {
+		defer file.Close()
+		if attachmentKey, err = s.storeAttachment(submission.UserID, submission.TrainingID, file); err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	}
+
+	previousKey := submission.AttachmentKey
+	submission, err = s.db.ResubmitSubmission(submissionID, submissionURL, attachmentKey)
+	if err != nil {
+		s.deleteUpload(attachmentKey)
+		writeDBError(w, err)
+		return
+	}
+
+	if attachmentKey != "" && previousKey != "" {
+		s.deleteUpload(previousKey)
+	}
+
+	writeJSON(w, http.StatusOK, submission)
+}
+
+func (s *Server) listMySubmissions(w http.ResponseWriter, r *http.Request) {
+	submissions, err := s.db.GetSubmissionsByUser(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, submissions)
+}
+
+func (s *Server) getSubmission(w http.ResponseWriter, r *http.Request) {
+	submissionID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	submission, err := s.db.GetSubmission(submissionID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if !canViewSubmission(claimsFromContext(r.Context()), submission) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, submission)
+}
+
+func (s *Server) downloadSubmissionAttachment(w http.ResponseWriter, r *http.Request) {
+	submissionID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	submission, err := s.db.GetSubmission(submissionID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if !canViewSubmission(claimsFromContext(r.Context()), submission) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if !submission.HasAttachment {
+		http.Error(w, "Submission has no attachment", http.StatusNotFound)
+		return
+	}
+
+	file, err := s.store.Open(submission.AttachmentKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="submission-%d.zip"`, submission.ID))
+	if _, err := io.Copy(w, file); err != nil {
+		log.Error("Failed to write attachment:", err)
+	}
+}
+
+// listReviewQueue lists submissions by status for instructors, defaulting to
+// the ones still waiting for a review.
+func (s *Server) listReviewQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = model.SubmissionPending
+	}
+
+	submissions, err := s.db.GetSubmissionsByStatus(status)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, submissions)
+}
+
+func (s *Server) reviewSubmission(w http.ResponseWriter, r *http.Request) {
+	submissionID, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var request ReviewSubmissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Status != model.SubmissionApproved && request.Status != model.SubmissionChangesRequested {
+		http.Error(w, "status must be approved or changes_requested", http.StatusBadRequest)
+		return
+	}
+
+	submission, err := s.db.ReviewSubmission(submissionID, claimsFromContext(r.Context()).UserID, request.Status, request.Feedback)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	training, err := s.db.GetTrainingByID(submission.TrainingID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if submission.Status == model.SubmissionApproved {
+		s.grantXP(submission.UserID, gamification.ReasonSubmissionApproved, submission.ID, training.CourseID)
+	}
+	s.notifySubmissionReviewed(submission, training)
+
+	writeJSON(w, http.StatusOK, submission)
+}
+
+func (s *Server) notifySubmissionReviewed(submission *model.Submission, training model.Training) {
+	student, err := s.db.GetUserByID(submission.UserID)
+	if err != nil {
+		log.Errorf("loading student to notify review: %v", err)
+		return
+	}
+
+	approved := submission.Status == model.SubmissionApproved
+	if err := s.sender.SendSubmissionReviewedEmail(student.Email, training.Name, approved, submission.Feedback); err != nil {
+		log.Errorf("sending submission review email: %v", err)
+	}
+}
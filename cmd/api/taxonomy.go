@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"game-student-go/internal/model"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+const maxCourseTags = 20
+
+func validDifficulty(difficulty string) bool {
+	return difficulty == model.DifficultyBeginner || difficulty == model.DifficultyIntermediate || difficulty == model.DifficultyAdvanced
+}
+
+// courseFilterFromQuery reads the course filters: ?q= searches by name,
+// ?has_free_trainings=true|false, ?category= takes a category slug, ?tag= may
+// be repeated to require every tag, ?difficulty= takes a difficulty level and
+// ?instructor= an instructor id.
+func courseFilterFromQuery(query url.Values) (model.CourseFilter, error) {
+	filter := model.CourseFilter{
+		Search:     query.Get("q"),
+		Category:   query.Get("category"),
+		Difficulty: query.Get("difficulty"),
+	}
+
+	// Tags are stored normalized, so the filter has to be too
+	for _, tag := range query["tag"] {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
+	if hasFree := query.Get("has_free_trainings"); hasFree != "" {
+		value, err := strconv.ParseBool(hasFree)
+		if err != nil {
+			return model.CourseFilter{}, errors.New("has_free_trainings must be true or false")
+		}
+		filter.HasFreeTrainings = &value
+	}
+
+	if instructor := query.Get("instructor"); instructor != "" {
+		id, err := strconv.Atoi(instructor)
+		if err != nil {
+			return model.CourseFilter{}, errors.New("instructor must be an instructor id")
+		}
+		filter.InstructorID = id
+	}
+
+	if filter.Difficulty != "" && !validDifficulty(filter.Difficulty) {
+		return model.CourseFilter{}, errors.New("difficulty must be beginner, intermediate or advanced")
+	}
+
+	return filter, nil
+}
+
+func (s *Server) getCourseFacets(w http.ResponseWriter, r *http.Request) {
+	filter, err := courseFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	facets, err := s.db.GetCourseFacets(filter)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, facets)
+}
+
+func (s *Server) getCategories(w http.ResponseWriter, _ *http.Request) {
+	categories, err := s.db.GetCategories()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, categories)
+}
+
+func (s *Server) getTags(w http.ResponseWriter, _ *http.Request) {
+	tags, err := s.db.GetTags()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+func decodeCategory(r *http.Request) (model.Category, error) {
+	var request CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return model.Category{}, err
+	}
+
+	if !slugPattern.MatchString(request.Slug) {
+		return model.Category{}, errors.New("slug must be lowercase words separated by dashes")
+	}
+
+	if strings.TrimSpace(request.Name) == "" {
+		return model.Category{}, errors.New("name is required")
+	}
+
+	return model.Category{Slug: request.Slug, Name: strings.TrimSpace(request.Name)}, nil
+}
+
+func (s *Server) createCategory(w http.ResponseWriter, r *http.Request) {
+	category, err := decodeCategory(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.db.CreateCategory(category)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) updateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	category, err := decodeCategory(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	category.ID = id
+
+	updated, err := s.db.UpdateCategory(category)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) deleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.DeleteCategory(id); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setCourseTaxonomy(w http.ResponseWriter, r *http.Request) {
+	course, ok := s.managedCourse(w, r)
+	if !ok {
+		return
+	}
+
+	var request CourseTaxonomyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Difficulty != "" && !validDifficulty(request.Difficulty) {
+		http.Error(w, "difficulty must be beginner, intermediate or advanced", http.StatusBadRequest)
+		return
+	}
+
+	// Tags are normalized so "Pixel Art" and "pixel art" end up the same tag
+	seen := map[string]bool{}
+	var tags []string
+	for _, tag := range request.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > 50 {
+			http.Error(w, "tags must be at most 50 characters", http.StatusBadRequest)
+			return
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	if len(tags) > maxCourseTags {
+		http.Error(w, "too many tags", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.SetCourseTaxonomy(course.ID, request.CategoryID, request.Difficulty, tags); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	course, err := s.db.GetCourseByID(course.ID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, course)
+}
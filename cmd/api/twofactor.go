@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"game-student-go/internal/database"
+	"game-student-go/internal/model"
+	"game-student-go/internal/totp"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodeCount     = 10
+	twoFactorChallengeTTL = 5 * time.Minute
+	maxChallengeAttempts  = 5
+)
+
+// challengeTwoFactor answers the first step of a sign in to an account with
+// 2FA: instead of a token the caller gets a short-lived challenge to trade,
+// with a code, at /signin/2fa.
+func (s *Server) challengeTwoFactor(w http.ResponseWriter, user model.User) {
+	challenge, err := randomToken(32)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := time.Now().Add(twoFactorChallengeTTL)
+	if err := s.db.CreateTwoFactorChallenge(user.ID, hashToken(challenge), expiresAt); err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+		ExpiresAt:         expiresAt,
+	})
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused
+// recovery code of the user. Both work only once.
+func (s *Server) checkSecondFactor(userID int, code string) (bool, error) {
+	twoFactor, err := s.db.GetTwoFactor(userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if twoFactor.EnabledAt == nil {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(twoFactor.Secret, code, time.Now(), twoFactor.LastStep); ok {
+		return s.db.UseTOTPStep(userID, step)
+	}
+
+	return s.db.UseRecoveryCode(userID, hashToken(strings.ToLower(strings.TrimSpace(code))))
+}
+
+// SigninTwoFactor is the second step of signing in to an account with 2FA.
+func (s *Server) SigninTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request TwoFactorSignInRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challenge := hashToken(request.ChallengeToken)
+	userID, err := s.db.AttemptTwoFactorChallenge(challenge, maxChallengeAttempts)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Invalid or expired challenge, sign in again", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	user, err := s.db.GetUserByID(userID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	// Wrong codes count like wrong passwords against the account
+	ip := clientIP(r)
+	if !s.beginSignin(w, user.Email, ip) {
+		return
+	}
+
+	ok, err := s.checkSecondFactor(userID, request.Code)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.db.DeleteTwoFactorChallenge(challenge); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	s.signinSucceeded(user.Email, ip)
+	s.writeToken(w, user, true)
+}
+
+// enrollTwoFactor starts 2FA enrollment. The secret and recovery codes are
+// only shown here; 2FA stays off until verifyTwoFactor sees a valid code.
+func (s *Server) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "could not start the enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	codes, err := totp.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, "could not start the enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(code)
+	}
+
+	if err := s.db.StartTwoFactorEnrollment(user.ID, secret, hashes); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, TwoFactorEnrollmentResponse{
+		Secret:        secret,
+		URI:           totp.URI("Escola do Jogo", user.Email, secret),
+		RecoveryCodes: codes,
+	})
+}
+
+// verifyTwoFactor turns 2FA on with the first code from the authenticator
+// and answers with a token that counts as signed in with 2FA.
+func (s *Server) verifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := claimsFromContext(r.Context()).UserID
+	twoFactor, err := s.db.GetTwoFactor(userID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	if twoFactor.EnabledAt != nil {
+		http.Error(w, "Two-factor authentication is already on", http.StatusConflict)
+		return
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, request.Code, time.Now(), twoFactor.LastStep)
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.db.EnableTwoFactor(userID, step); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	user, err := s.db.GetUserByID(userID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	s.writeToken(w, user, true)
+}
+
+// disableTwoFactor turns 2FA off given the password and a code. Members of
+// roles that require 2FA can't turn it off.
+func (s *Server) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request DisableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.db.GetUserByID(claimsFromContext(r.Context()).UserID)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	required, err := s.twoFactorRequired(user.Role)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if required {
+		http.Error(w, fmt.Sprintf("Two-factor authentication is required for the %s role", user.Role), http.StatusForbidden)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
+		http.Error(w, "Wrong password", http.StatusUnauthorized)
+		return
+	}
+
+	ok, err := s.checkSecondFactor(user.ID, request.Code)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.db.DisableTwoFactor(user.ID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) twoFactorRequired(role string) (bool, error) {
+	roles, err := s.db.GetTwoFactorRoles()
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range roles {
+		if r == role {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *Server) getTwoFactorRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := s.db.GetTwoFactorRoles()
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TwoFactorRolesRequest{Roles: roles})
+}
+
+// setTwoFactorRoles chooses which privileged roles need 2FA. Admins must
+// have 2FA on themselves before requiring it of their own role, or they
+// would lock themselves out of this very endpoint.
+func (s *Server) setTwoFactorRoles(w http.ResponseWriter, r *http.Request) {
+	var request TwoFactorRolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claims := claimsFromContext(r.Context())
+	for _, role := range request.Roles {
+		if role != model.RoleInstructor && role != model.RoleAdmin {
+			http.Error(w, fmt.Sprintf("2FA can only be required of the %s and %s roles", model.RoleInstructor, model.RoleAdmin),
+				http.StatusBadRequest)
+			return
+		}
+		if role == claims.Role && !claims.TwoFactor {
+			http.Error(w, "Sign in with two-factor authentication before requiring it of your role", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := s.db.SetTwoFactorRoles(request.Roles); err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
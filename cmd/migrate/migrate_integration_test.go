@@ -0,0 +1,106 @@
+//go:build integration
+
+package main
+
+import (
+	"database/sql"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchema = "migrate_test"
+
+// setupMigrations runs the migrations in a schema of their own, so they can
+// be taken step by step with data put in between.
+func setupMigrations(t *testing.T) (*migrate.Migrate, *sql.DB) {
+	conn := "user=ps_user password=ps_password dbname=backend sslmode=disable host=localhost"
+	admin, err := sql.Open("postgres", conn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA IF EXISTS " + testSchema + " CASCADE")
+		admin.Close()
+	})
+
+	if _, err := admin.Exec("DROP SCHEMA IF EXISTS " + testSchema + " CASCADE"); err != nil {
+		t.Fatalf("Failed to drop schema: %v", err)
+	}
+	if _, err := admin.Exec("CREATE SCHEMA " + testSchema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	db, err := sql.Open("postgres", conn+" search_path="+testSchema)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{SchemaName: testSchema})
+	if err != nil {
+		t.Fatalf("Failed to create migration driver: %v", err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../migrations", "postgres", driver)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	return m, db
+}
+
+func TestLeaderboardsMigrationKeepsExistingGrants(t *testing.T) {
+	m, db := setupMigrations(t)
+	if err := m.Migrate(12); err != nil {
+		t.Fatalf("Failed to migrate to 12: %v", err)
+	}
+
+	var userID, courseID, trainingID int
+	if err := db.QueryRow(`INSERT INTO users (email, password) VALUES ('xp@test.com', 'x') RETURNING id`).Scan(&userID); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	if err := db.QueryRow(`INSERT INTO courses (name) VALUES ('Course') RETURNING id`).Scan(&courseID); err != nil {
+		t.Fatalf("Failed to insert course: %v", err)
+	}
+	err := db.QueryRow(`
+		INSERT INTO trainings (sequence, topic, name, url, is_free, course_id)
+		VALUES (1, 'Topic', 'Training', 'https://example.com', TRUE, $1) RETURNING id`,
+		courseID).Scan(&trainingID)
+	if err != nil {
+		t.Fatalf("Failed to insert training: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO xp_ledger (user_id, amount, reason, source_id) VALUES ($1, 10, 'training_completed', $2)`,
+		userID, trainingID)
+	if err != nil {
+		t.Fatalf("Failed to insert grant: %v", err)
+	}
+
+	if err := m.Migrate(13); err != nil {
+		t.Fatalf("Failed to migrate to 13 with grants in place: %v", err)
+	}
+
+	var grantCourseID sql.NullInt64
+	if err := db.QueryRow(`SELECT course_id FROM xp_ledger WHERE user_id = $1`, userID).Scan(&grantCourseID); err != nil {
+		t.Fatalf("Failed to read grant: %v", err)
+	}
+	assert.Equal(t, int64(courseID), grantCourseID.Int64, "Grants are backfilled with their course")
+
+	_, err = db.Exec(`UPDATE xp_ledger SET amount = 20 WHERE user_id = $1`, userID)
+	assert.Error(t, err, "Grants stay immutable")
+
+	if _, err := db.Exec(`DELETE FROM trainings WHERE id = $1`, trainingID); err != nil {
+		t.Fatalf("Failed to delete training: %v", err)
+	}
+	if _, err := db.Exec(`DELETE FROM courses WHERE id = $1`, courseID); err != nil {
+		t.Fatalf("Failed to delete a course with grants: %v", err)
+	}
+
+	if err := m.Steps(-1); err != nil {
+		t.Fatalf("Failed to migrate down from 13: %v", err)
+	}
+	_, err = db.Exec(`UPDATE xp_ledger SET amount = 20 WHERE user_id = $1`, userID)
+	assert.Error(t, err, "Grants stay immutable after rolling back")
+}
@@ -0,0 +1,71 @@
+// Package calendar writes iCalendar (RFC 5545) feeds of live sessions.
+package calendar
+
+import (
+	"fmt"
+	"game-student-go/internal/model"
+	"strings"
+	"time"
+)
+
+const timeFormat = "20060102T150405Z"
+
+// maxLineOctets is the longest content line RFC 5545 allows before folding.
+const maxLineOctets = 75
+
+// Feed renders the sessions as an iCalendar feed named name. Event UIDs are
+// qualified by domain so they stay stable across refreshes.
+func Feed(name, domain string, sessions []model.LiveSession, now time.Time) string {
+	var b strings.Builder
+	line(&b, "BEGIN:VCALENDAR")
+	line(&b, "VERSION:2.0")
+	line(&b, "PRODID:-//Escola do Jogo//game-student-go//PT")
+	line(&b, "CALSCALE:GREGORIAN")
+	line(&b, "METHOD:PUBLISH")
+	line(&b, "X-WR-CALNAME:"+escape(name))
+
+	stamp := now.UTC().Format(timeFormat)
+	for _, session := range sessions {
+		summary := session.Title
+		if session.CohortName != "" {
+			summary = fmt.Sprintf("%s (%s)", session.Title, session.CohortName)
+		}
+
+		line(&b, "BEGIN:VEVENT")
+		line(&b, fmt.Sprintf("UID:session-%d@%s", session.ID, domain))
+		line(&b, "DTSTAMP:"+stamp)
+		line(&b, "DTSTART:"+session.StartsAt.UTC().Format(timeFormat))
+		line(&b, "DTEND:"+session.EndsAt().UTC().Format(timeFormat))
+		line(&b, "SUMMARY:"+escape(summary))
+		line(&b, "LOCATION:"+escape(session.MeetingURL))
+		line(&b, "URL:"+session.MeetingURL)
+		line(&b, "END:VEVENT")
+	}
+
+	line(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// escape escapes TEXT values.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// line writes a content line, folding it into CRLF and space continuations
+// without splitting UTF-8 sequences.
+func line(b *strings.Builder, s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with the space, which counts.
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,48 @@
+package calendar
+
+import (
+	"game-student-go/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeed(t *testing.T) {
+	sessions := []model.LiveSession{{
+		ID:              7,
+		CohortName:      "Turma 1",
+		Title:           "Q&A; shaders, lights",
+		StartsAt:        time.Date(2024, 3, 5, 22, 0, 0, 0, time.FixedZone("BRT", -3*3600)),
+		DurationMinutes: 90,
+		MeetingURL:      "https://meet.example.com/abc",
+	}}
+
+	feed := Feed("Minhas aulas", "example.com", sessions, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	assert.True(t, strings.HasPrefix(feed, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(feed, "END:VCALENDAR\r\n"))
+	assert.Contains(t, feed, "UID:session-7@example.com\r\n")
+	assert.Contains(t, feed, "DTSTAMP:20240301T120000Z\r\n")
+	assert.Contains(t, feed, "DTSTART:20240306T010000Z\r\n")
+	assert.Contains(t, feed, "DTEND:20240306T023000Z\r\n")
+	assert.Contains(t, feed, `SUMMARY:Q&A\; shaders\, lights (Turma 1)`+"\r\n")
+}
+
+func TestLineFolding(t *testing.T) {
+	content := "SUMMARY:" + strings.Repeat("ã", 80)
+	var b strings.Builder
+	line(&b, content)
+
+	folded := strings.TrimSuffix(b.String(), "\r\n")
+	lines := strings.Split(folded, "\r\n")
+	assert.Greater(t, len(lines), 1)
+	for i, l := range lines {
+		assert.LessOrEqual(t, len(l), maxLineOctets, "line %d is too long", i)
+		if i > 0 {
+			assert.True(t, strings.HasPrefix(l, " "))
+		}
+	}
+	assert.Equal(t, content, strings.ReplaceAll(folded, "\r\n ", ""))
+}
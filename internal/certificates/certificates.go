@@ -0,0 +1,104 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"game-student-go/internal/model"
+	"strings"
+)
+
+// Landscape A4 in PDF points.
+const (
+	pageWidth  = 842
+	pageHeight = 595
+)
+
+// NewCode returns a random, URL safe verification code.
+func NewCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating certificate code: %w", err)
+	}
+
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+// RenderPDF draws a single page certificate. It writes the PDF by hand using
+// the standard Helvetica fonts so no font files or third party libraries are
+// needed.
+func RenderPDF(certificate model.Certificate, verifyURL string) []byte {
+	var content bytes.Buffer
+	content.WriteString("0.2 0.2 0.2 RG 4 w 30 30 782 535 re S\n")
+	centered(&content, "F2", 36, 470, "Certificado de Conclusão")
+	centered(&content, "F1", 16, 420, "Certificamos que")
+	centered(&content, "F2", 28, 375, certificate.StudentName)
+	centered(&content, "F1", 16, 330, "concluiu o curso")
+	centered(&content, "F2", 24, 290, certificate.CourseName)
+	centered(&content, "F1", 14, 230, "Emitido em "+certificate.IssuedAt.Format("02/01/2006"))
+	centered(&content, "F1", 11, 90, "Código de verificação: "+certificate.Code)
+	centered(&content, "F1", 11, 70, verifyURL)
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return pdf.Bytes()
+}
+
+// centered writes a line of text horizontally centered on the page. Widths
+// are estimated from the average Helvetica glyph, which is close enough for
+// short headings.
+func centered(content *bytes.Buffer, font string, size, y int, text string) {
+	encoded := encodeText(text)
+	width := float64(len(encoded)) * float64(size) * 0.5
+	x := (pageWidth - width) / 2
+	fmt.Fprintf(content, "BT /%s %d Tf %.1f %d Td (%s) Tj ET\n", font, size, x, y, escape(encoded))
+}
+
+// encodeText converts text to WinAnsiEncoding, which matches Latin-1 for the
+// accented letters used in Portuguese. Anything outside it becomes '?'.
+func encodeText(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r < 0x20 || r > 0xff || (r >= 0x7f && r < 0xa0) {
+			r = '?'
+		}
+		encoded = append(encoded, byte(r))
+	}
+
+	return encoded
+}
+
+func escape(text []byte) string {
+	var escaped strings.Builder
+	for _, b := range text {
+		if b == '(' || b == ')' || b == '\\' {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(b)
+	}
+
+	return escaped.String()
+}
@@ -0,0 +1,40 @@
+package certificates
+
+import (
+	"bytes"
+	"game-student-go/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCode(t *testing.T) {
+	code, err := NewCode()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	other, err := NewCode()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	assert.Len(t, code, 20)
+	assert.NotEqual(t, code, other)
+}
+
+func TestRenderPDF(t *testing.T) {
+	pdf := RenderPDF(model.Certificate{
+		Code:        "ABC123",
+		StudentName: "João (Jota)",
+		CourseName:  "Unity Gameplay Programming",
+		IssuedAt:    time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+	}, "http://localhost:8080/certificates/ABC123")
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), "Jo\xe3o \\(Jota\\)")
+	assert.Contains(t, string(pdf), "Unity Gameplay Programming")
+	assert.Contains(t, string(pdf), "01/07/2023")
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"game-student-go/internal/model"
+	"time"
+)
+
+func (c *client) SetUserTimeZone(userID int, timeZone string) error {
+	_, err := c.db.Exec(`UPDATE users SET time_zone = $2 WHERE id = $1`, userID, timeZone)
+	if err != nil {
+		return fmt.Errorf("updating time zone: %w", err)
+	}
+
+	return nil
+}
+
+// RecordActivity counts one learning event on the given local day.
+func (c *client) RecordActivity(userID int, day time.Time, kind string) error {
+	var column string
+	switch kind {
+	case model.ActivityTrainingCompleted:
+		column = "trainings_completed"
+	case model.ActivityQuizPassed:
+		column = "quizzes_passed"
+	default:
+		return fmt.Errorf("unknown activity kind: %s", kind)
+	}
+
+	_, err := c.db.Exec(`
+		INSERT INTO learning_activity (user_id, day, `+column+`)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, day) DO UPDATE
+		SET `+column+` = learning_activity.`+column+` + 1`,
+		userID, day.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("recording activity: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) GetActivity(userID int, since time.Time) ([]model.ActivityDay, error) {
+	rows, err := c.db.Query(`
+		SELECT day, trainings_completed, quizzes_passed
+		FROM learning_activity
+		WHERE user_id = $1 AND day >= $2
+		ORDER BY day`,
+		userID, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("querying activity: %w", err)
+	}
+	defer rows.Close()
+
+	days := []model.ActivityDay{}
+	for rows.Next() {
+		var day model.ActivityDay
+		if err := rows.Scan(&day.Date, &day.TrainingsCompleted, &day.QuizzesPassed); err != nil {
+			return nil, fmt.Errorf("scanning activity: %w", err)
+		}
+		day.Count = day.TrainingsCompleted + day.QuizzesPassed
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+// GetStreakReminders finds students whose local time is past the given hour,
+// who studied yesterday but not yet today, and who were not reminded today.
+// Students whose time zone Postgres doesn't know are skipped rather than
+// failing everyone's reminders.
+func (c *client) GetStreakReminders(hour int) ([]model.StreakReminder, error) {
+	rows, err := c.db.Query(`
+		WITH local AS (
+			SELECT id, email, time_zone,
+			       (CURRENT_TIMESTAMP AT TIME ZONE time_zone)::date AS today,
+			       EXTRACT(HOUR FROM CURRENT_TIMESTAMP AT TIME ZONE time_zone) AS hour
+			FROM users
+			WHERE deleted_at IS NULL AND time_zone IN (SELECT name FROM pg_timezone_names)
+		)
+		SELECT l.id, l.email, l.time_zone, l.today
+		FROM local l
+		JOIN learning_activity yesterday ON yesterday.user_id = l.id AND yesterday.day = l.today - 1
+		WHERE l.hour >= $1
+		  AND NOT EXISTS (SELECT 1 FROM learning_activity a WHERE a.user_id = l.id AND a.day = l.today)
+		  AND NOT EXISTS (SELECT 1 FROM streak_reminders r WHERE r.user_id = l.id AND r.day = l.today)`,
+		hour)
+	if err != nil {
+		return nil, fmt.Errorf("querying streak reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var reminders []model.StreakReminder
+	for rows.Next() {
+		var reminder model.StreakReminder
+		if err := rows.Scan(&reminder.UserID, &reminder.Email, &reminder.TimeZone, &reminder.Day); err != nil {
+			return nil, fmt.Errorf("scanning streak reminder: %w", err)
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+func (c *client) MarkStreakReminderSent(userID int, day time.Time) error {
+	_, err := c.db.Exec(`INSERT INTO streak_reminders (user_id, day) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		userID, day.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("marking streak reminder: %w", err)
+	}
+
+	return nil
+}
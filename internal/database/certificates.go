@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+const certificateColumns = `id, code, user_id, course_id, student_name, course_name, issued_at, revoked_at, revoked_reason`
+
+func scanCertificate(row rowScanner) (*model.Certificate, error) {
+	var certificate model.Certificate
+	var revokedAt sql.NullTime
+	var revokedReason sql.NullString
+	err := row.Scan(&certificate.ID, &certificate.Code, &certificate.UserID, &certificate.CourseID, &certificate.StudentName,
+		&certificate.CourseName, &certificate.IssuedAt, &revokedAt, &revokedReason)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		certificate.RevokedAt = &revokedAt.Time
+	}
+	certificate.RevokedReason = revokedReason.String
+
+	return &certificate, nil
+}
+
+// IssueCertificate stores a certificate for the user and course. A student
+// only ever gets one certificate per course, so issuing again returns the
+// existing one untouched.
+func (c *client) IssueCertificate(certificate model.Certificate) (*model.Certificate, error) {
+	query := `
+		INSERT INTO certificates (code, user_id, course_id, student_name, course_name)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, course_id) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING ` + certificateColumns
+
+	issued, err := scanCertificate(c.db.QueryRow(query, certificate.Code, certificate.UserID, certificate.CourseID,
+		certificate.StudentName, certificate.CourseName))
+	if err != nil {
+		return nil, fmt.Errorf("issuing certificate: %w", err)
+	}
+
+	return issued, nil
+}
+
+func (c *client) GetCertificateByCode(code string) (*model.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE code = $1`
+
+	certificate, err := scanCertificate(c.db.QueryRow(query, code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no certificate found with code %s: %w", code, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying certificate by code: %w", err)
+	}
+
+	return certificate, nil
+}
+
+func (c *client) GetCertificatesByUser(userID int) ([]model.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE user_id = $1 ORDER BY issued_at DESC`
+
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying certificates of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	certificates := []model.Certificate{}
+	for rows.Next() {
+		certificate, err := scanCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning certificate: %w", err)
+		}
+		certificates = append(certificates, *certificate)
+	}
+
+	return certificates, rows.Err()
+}
+
+func (c *client) RevokeCertificate(code, reason string) (*model.Certificate, error) {
+	query := `
+		UPDATE certificates
+		SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP), revoked_reason = $2
+		WHERE code = $1
+		RETURNING ` + certificateColumns
+
+	certificate, err := scanCertificate(c.db.QueryRow(query, code, reason))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no certificate found with code %s: %w", code, ErrNotFound)
+		}
+		return nil, fmt.Errorf("revoking certificate: %w", err)
+	}
+
+	return certificate, nil
+}
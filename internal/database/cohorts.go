@@ -0,0 +1,562 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"github.com/stripe/stripe-go/v74"
+	"time"
+)
+
+const cohortColumns = `cohorts.id, cohorts.course_id, cohorts.name, cohorts.starts_at, cohorts.ends_at, cohorts.capacity,
+	(SELECT COUNT(*) FROM cohort_enrollments e WHERE e.cohort_id = cohorts.id AND e.status <> 'cancelled'),
+	cohorts.price, cohorts.currency, cohorts.created_at`
+
+func scanCohort(row rowScanner) (*model.Cohort, error) {
+	var cohort model.Cohort
+	err := row.Scan(&cohort.ID, &cohort.CourseID, &cohort.Name, &cohort.StartsAt, &cohort.EndsAt, &cohort.Capacity,
+		&cohort.Enrolled, &cohort.Price, &cohort.Currency, &cohort.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cohort, nil
+}
+
+func (c *client) CreateCohort(cohort model.Cohort) (*model.Cohort, error) {
+	query := `
+		INSERT INTO cohorts (course_id, name, starts_at, ends_at, capacity, price, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + cohortColumns
+
+	created, err := scanCohort(c.db.QueryRow(query, cohort.CourseID, cohort.Name, cohort.StartsAt, cohort.EndsAt,
+		cohort.Capacity, cohort.Price, cohort.Currency))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no course found with id %d: %w", cohort.CourseID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("creating cohort: %w", err)
+	}
+
+	return created, nil
+}
+
+// UpdateCohort changes the schedule, capacity and price of a cohort. The
+// capacity cannot drop below the seats already taken, and the price is fixed
+// once anyone holds a seat, as they were charged or quoted it.
+func (c *client) UpdateCohort(cohort model.Cohort) (*model.Cohort, error) {
+	query := `
+		UPDATE cohorts SET name = $2, starts_at = $3, ends_at = $4, capacity = $5, price = $6, currency = $7
+		WHERE id = $1
+		  AND $5 >= (SELECT COUNT(*) FROM cohort_enrollments e WHERE e.cohort_id = $1 AND e.status <> 'cancelled')
+		  AND (price = $6 AND currency = $7
+		       OR NOT EXISTS (SELECT 1 FROM cohort_enrollments e WHERE e.cohort_id = $1 AND e.status <> 'cancelled'))
+		RETURNING ` + cohortColumns
+
+	updated, err := scanCohort(c.db.QueryRow(query, cohort.ID, cohort.Name, cohort.StartsAt, cohort.EndsAt,
+		cohort.Capacity, cohort.Price, cohort.Currency))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			current, err := c.GetCohort(cohort.ID)
+			if err != nil {
+				return nil, err
+			}
+			if current.Enrolled > 0 && (current.Price != cohort.Price || current.Currency != cohort.Currency) {
+				return nil, fmt.Errorf("cohort %d has students, its price cannot change: %w", cohort.ID, ErrConflict)
+			}
+			return nil, fmt.Errorf("cohort %d has more students than %d seats: %w", cohort.ID, cohort.Capacity, ErrConflict)
+		}
+		return nil, fmt.Errorf("updating cohort: %w", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteCohort removes a cohort nobody enrolled in yet.
+func (c *client) DeleteCohort(id int) error {
+	result, err := c.db.Exec(`
+		DELETE FROM cohorts
+		WHERE id = $1
+		  AND NOT EXISTS (SELECT 1 FROM cohort_enrollments e WHERE e.cohort_id = $1 AND e.status <> 'cancelled')`,
+		id)
+	if err != nil {
+		return fmt.Errorf("deleting cohort: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		if _, err := c.GetCohort(id); err != nil {
+			return err
+		}
+		return fmt.Errorf("cohort %d has enrolled students: %w", id, ErrConflict)
+	}
+
+	return nil
+}
+
+func (c *client) GetCohort(id int) (*model.Cohort, error) {
+	cohort, err := scanCohort(c.db.QueryRow(`SELECT `+cohortColumns+` FROM cohorts WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no cohort found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying cohort: %w", err)
+	}
+
+	return cohort, nil
+}
+
+// GetCourseCohorts returns the cohorts of a course that have not ended yet,
+// soonest first.
+func (c *client) GetCourseCohorts(courseID int) ([]model.Cohort, error) {
+	query := `SELECT ` + cohortColumns + ` FROM cohorts
+		WHERE course_id = $1 AND ends_at > CURRENT_TIMESTAMP
+		ORDER BY starts_at, id`
+
+	return c.queryCohorts(query, courseID)
+}
+
+// GetUserCohorts returns the cohorts a user holds a seat in.
+func (c *client) GetUserCohorts(userID int) ([]model.Cohort, error) {
+	query := `SELECT ` + cohortColumns + ` FROM cohorts
+		JOIN cohort_enrollments e ON e.cohort_id = cohorts.id
+		WHERE e.user_id = $1 AND e.status <> 'cancelled'
+		ORDER BY cohorts.starts_at DESC, cohorts.id`
+
+	return c.queryCohorts(query, userID)
+}
+
+func (c *client) queryCohorts(query string, args ...interface{}) ([]model.Cohort, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying cohorts: %w", err)
+	}
+	defer rows.Close()
+
+	cohorts := []model.Cohort{}
+	for rows.Next() {
+		cohort, err := scanCohort(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning cohort: %w", err)
+		}
+		cohorts = append(cohorts, *cohort)
+	}
+
+	return cohorts, rows.Err()
+}
+
+const enrollmentColumns = `cohort_id, user_id, status, payment_id, created_at`
+
+func scanEnrollment(row rowScanner) (*model.Enrollment, error) {
+	var enrollment model.Enrollment
+	var paymentID sql.NullInt64
+	err := row.Scan(&enrollment.CohortID, &enrollment.UserID, &enrollment.Status, &paymentID, &enrollment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if paymentID.Valid {
+		id := int(paymentID.Int64)
+		enrollment.PaymentID = &id
+	}
+
+	return &enrollment, nil
+}
+
+// Enroll takes a seat in a cohort for a user, failing with ErrConflict when
+// the cohort is full or the user already holds a seat. A cancelled
+// enrollment can be taken up again.
+func (c *client) Enroll(cohortID, userID int, status string) (*model.Enrollment, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the cohort so concurrent enrollments cannot oversell its seats.
+	var capacity, taken int
+	err = tx.QueryRow(`SELECT capacity FROM cohorts WHERE id = $1 FOR UPDATE`, cohortID).Scan(&capacity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no cohort found with id %d: %w", cohortID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("locking cohort: %w", err)
+	}
+
+	err = tx.QueryRow(`SELECT COUNT(*) FROM cohort_enrollments WHERE cohort_id = $1 AND status <> 'cancelled'`,
+		cohortID).Scan(&taken)
+	if err != nil {
+		return nil, fmt.Errorf("counting enrollments: %w", err)
+	}
+	if taken >= capacity {
+		return nil, fmt.Errorf("cohort %d is full: %w", cohortID, ErrConflict)
+	}
+
+	query := `
+		INSERT INTO cohort_enrollments (cohort_id, user_id, status)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (cohort_id, user_id) DO UPDATE
+		SET status = EXCLUDED.status, payment_id = NULL, created_at = CURRENT_TIMESTAMP
+		WHERE cohort_enrollments.status = 'cancelled'
+		RETURNING ` + enrollmentColumns
+
+	enrollment, err := scanEnrollment(tx.QueryRow(query, cohortID, userID, status))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %d is already enrolled in cohort %d: %w", userID, cohortID, ErrConflict)
+		}
+		return nil, fmt.Errorf("enrolling in cohort: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing enrollment: %w", err)
+	}
+
+	return enrollment, nil
+}
+
+func (c *client) GetEnrollment(cohortID, userID int) (*model.Enrollment, error) {
+	query := `SELECT ` + enrollmentColumns + ` FROM cohort_enrollments WHERE cohort_id = $1 AND user_id = $2`
+	enrollment, err := scanEnrollment(c.db.QueryRow(query, cohortID, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %d is not enrolled in cohort %d: %w", userID, cohortID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying enrollment: %w", err)
+	}
+
+	return enrollment, nil
+}
+
+func (c *client) GetCohortEnrollments(cohortID int) ([]model.Enrollment, error) {
+	rows, err := c.db.Query(`SELECT `+enrollmentColumns+` FROM cohort_enrollments
+		WHERE cohort_id = $1
+		ORDER BY created_at, user_id`, cohortID)
+	if err != nil {
+		return nil, fmt.Errorf("querying enrollments: %w", err)
+	}
+	defer rows.Close()
+
+	enrollments := []model.Enrollment{}
+	for rows.Next() {
+		enrollment, err := scanEnrollment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning enrollment: %w", err)
+		}
+		enrollments = append(enrollments, *enrollment)
+	}
+
+	return enrollments, rows.Err()
+}
+
+// AddEnrollmentPayment records the payment of a pending enrollment and links
+// the two. Recording a payment intent again returns the payment already
+// recorded, so the webhook and the enrollment request may both do it.
+func (c *client) AddEnrollmentPayment(cohortID, userID int, pi *stripe.PaymentIntent) (*model.Payment, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM cohort_enrollments WHERE cohort_id = $1 AND user_id = $2 FOR UPDATE`,
+		cohortID, userID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %d is not enrolled in cohort %d: %w", userID, cohortID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("locking enrollment: %w", err)
+	}
+
+	payment := &model.Payment{
+		StripePaymentIntentID: pi.ID,
+		UserID:                userID,
+		Amount:                pi.Amount,
+		Currency:              string(pi.Currency),
+		Status:                string(pi.Status),
+	}
+	if pi.PaymentMethod != nil {
+		payment.StripePayMethodID = pi.PaymentMethod.ID
+	}
+
+	err = tx.QueryRow(`SELECT id, created_at, updated_at FROM payments WHERE stripe_payment_intent_id = $1`, pi.ID).
+		Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`
+			INSERT INTO payments (stripe_payment_intent_id, stripe_pay_method_id, user_id, amount, currency, status)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at, updated_at`,
+			payment.StripePaymentIntentID, payment.StripePayMethodID, payment.UserID, payment.Amount, payment.Currency,
+			payment.Status).Scan(&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("recording enrollment payment: %w", err)
+	}
+
+	if status == model.EnrollmentPending {
+		_, err := tx.Exec(`UPDATE cohort_enrollments SET payment_id = $3 WHERE cohort_id = $1 AND user_id = $2`,
+			cohortID, userID, payment.ID)
+		if err != nil {
+			return nil, fmt.Errorf("linking enrollment payment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing enrollment payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+func (c *client) SetEnrollmentStatus(cohortID, userID int, status string) error {
+	result, err := c.db.Exec(`UPDATE cohort_enrollments SET status = $3 WHERE cohort_id = $1 AND user_id = $2`,
+		cohortID, userID, status)
+	if err != nil {
+		return fmt.Errorf("updating enrollment: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return fmt.Errorf("user %d is not enrolled in cohort %d: %w", userID, cohortID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// CancelPendingEnrollment gives back the seat of an enrollment still waiting
+// for its payment. Settled enrollments are left alone.
+func (c *client) CancelPendingEnrollment(cohortID, userID int) error {
+	_, err := c.db.Exec(`UPDATE cohort_enrollments SET status = 'cancelled' WHERE cohort_id = $1 AND user_id = $2 AND status = 'pending'`,
+		cohortID, userID)
+	if err != nil {
+		return fmt.Errorf("cancelling enrollment: %w", err)
+	}
+
+	return nil
+}
+
+// GetExpiredEnrollments lists the enrollments still pending that were taken
+// before the given time, along with the payment intent holding each seat.
+func (c *client) GetExpiredEnrollments(before time.Time) ([]model.PendingEnrollment, error) {
+	rows, err := c.db.Query(`
+		SELECT e.cohort_id, e.user_id, COALESCE(p.stripe_payment_intent_id, '')
+		FROM cohort_enrollments e
+		LEFT JOIN payments p ON p.id = e.payment_id
+		WHERE e.status = 'pending' AND e.created_at < $1
+		ORDER BY e.created_at`, before)
+	if err != nil {
+		return nil, fmt.Errorf("querying expired enrollments: %w", err)
+	}
+	defer rows.Close()
+
+	var enrollments []model.PendingEnrollment
+	for rows.Next() {
+		var enrollment model.PendingEnrollment
+		if err := rows.Scan(&enrollment.CohortID, &enrollment.UserID, &enrollment.PaymentIntentID); err != nil {
+			return nil, fmt.Errorf("scanning expired enrollment: %w", err)
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+
+	return enrollments, rows.Err()
+}
+
+// SetPaymentEnrollmentStatus settles the pending enrollment paid by the
+// payment, if any. Payments for anything else are left alone.
+func (c *client) SetPaymentEnrollmentStatus(paymentID int, status string) error {
+	_, err := c.db.Exec(`UPDATE cohort_enrollments SET status = $2 WHERE payment_id = $1 AND status = 'pending'`,
+		paymentID, status)
+	if err != nil {
+		return fmt.Errorf("settling enrollment of payment %d: %w", paymentID, err)
+	}
+
+	return nil
+}
+
+const liveSessionColumns = `live_sessions.id, live_sessions.cohort_id, cohorts.name, live_sessions.title,
+	live_sessions.starts_at, live_sessions.duration_minutes, live_sessions.meeting_url, live_sessions.created_at`
+
+const liveSessionSource = `live_sessions JOIN cohorts ON cohorts.id = live_sessions.cohort_id`
+
+func scanLiveSession(row rowScanner) (*model.LiveSession, error) {
+	var session model.LiveSession
+	err := row.Scan(&session.ID, &session.CohortID, &session.CohortName, &session.Title, &session.StartsAt,
+		&session.DurationMinutes, &session.MeetingURL, &session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (c *client) CreateLiveSession(session model.LiveSession) (*model.LiveSession, error) {
+	query := `
+		INSERT INTO live_sessions (cohort_id, title, starts_at, duration_minutes, meeting_url)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	var id int
+	err := c.db.QueryRow(query, session.CohortID, session.Title, session.StartsAt, session.DurationMinutes,
+		session.MeetingURL).Scan(&id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no cohort found with id %d: %w", session.CohortID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("creating live session: %w", err)
+	}
+
+	return c.GetLiveSession(id)
+}
+
+// UpdateLiveSession reschedules a session. Moving it clears its reminder so
+// students are reminded of the new time.
+func (c *client) UpdateLiveSession(session model.LiveSession) (*model.LiveSession, error) {
+	query := `
+		UPDATE live_sessions
+		SET title = $2, duration_minutes = $4, meeting_url = $5, starts_at = $3,
+		    reminded_at = CASE WHEN starts_at = $3 THEN reminded_at END
+		WHERE id = $1`
+
+	result, err := c.db.Exec(query, session.ID, session.Title, session.StartsAt, session.DurationMinutes,
+		session.MeetingURL)
+	if err != nil {
+		return nil, fmt.Errorf("updating live session: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return nil, fmt.Errorf("no live session found with id %d: %w", session.ID, ErrNotFound)
+	}
+
+	return c.GetLiveSession(session.ID)
+}
+
+func (c *client) DeleteLiveSession(id int) error {
+	result, err := c.db.Exec(`DELETE FROM live_sessions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting live session: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("no live session found with id %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (c *client) GetLiveSession(id int) (*model.LiveSession, error) {
+	query := `SELECT ` + liveSessionColumns + ` FROM ` + liveSessionSource + ` WHERE live_sessions.id = $1`
+	session, err := scanLiveSession(c.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no live session found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying live session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (c *client) GetCohortSessions(cohortID int) ([]model.LiveSession, error) {
+	query := `SELECT ` + liveSessionColumns + ` FROM ` + liveSessionSource + `
+		WHERE live_sessions.cohort_id = $1
+		ORDER BY live_sessions.starts_at, live_sessions.id`
+
+	return c.queryLiveSessions(query, cohortID)
+}
+
+// GetUserSessions returns the sessions of every cohort a user is actively
+// enrolled in, for their calendar feed.
+func (c *client) GetUserSessions(userID int) ([]model.LiveSession, error) {
+	query := `SELECT ` + liveSessionColumns + ` FROM ` + liveSessionSource + `
+		JOIN cohort_enrollments e ON e.cohort_id = live_sessions.cohort_id
+		WHERE e.user_id = $1 AND e.status = 'active'
+		ORDER BY live_sessions.starts_at, live_sessions.id`
+
+	return c.queryLiveSessions(query, userID)
+}
+
+func (c *client) queryLiveSessions(query string, args ...interface{}) ([]model.LiveSession, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying live sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []model.LiveSession{}
+	for rows.Next() {
+		session, err := scanLiveSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning live session: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// GetSessionReminders returns the active students of every session starting
+// between from and until that has not been reminded of yet.
+func (c *client) GetSessionReminders(from, until time.Time) ([]model.SessionReminder, error) {
+	rows, err := c.db.Query(`
+		SELECT s.id, u.id, u.email, u.time_zone, s.title, s.starts_at, s.meeting_url
+		FROM live_sessions s
+		JOIN cohort_enrollments e ON e.cohort_id = s.cohort_id AND e.status = 'active'
+		JOIN users u ON u.id = e.user_id AND u.deleted_at IS NULL
+		WHERE s.reminded_at IS NULL AND s.starts_at > $1 AND s.starts_at <= $2
+		ORDER BY s.starts_at, s.id, u.id`,
+		from, until)
+	if err != nil {
+		return nil, fmt.Errorf("querying session reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var reminders []model.SessionReminder
+	for rows.Next() {
+		var reminder model.SessionReminder
+		err := rows.Scan(&reminder.SessionID, &reminder.UserID, &reminder.Email, &reminder.TimeZone, &reminder.Title,
+			&reminder.StartsAt, &reminder.MeetingURL)
+		if err != nil {
+			return nil, fmt.Errorf("scanning session reminder: %w", err)
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, rows.Err()
+}
+
+func (c *client) MarkSessionReminded(sessionID int) error {
+	_, err := c.db.Exec(`UPDATE live_sessions SET reminded_at = CURRENT_TIMESTAMP WHERE id = $1`, sessionID)
+	if err != nil {
+		return fmt.Errorf("marking session reminder: %w", err)
+	}
+
+	return nil
+}
+
+// EnsureCalendarToken returns the user's calendar feed token, storing the
+// given one if the user has none yet.
+func (c *client) EnsureCalendarToken(userID int, token string) (string, error) {
+	var stored string
+	err := c.db.QueryRow(`UPDATE users SET calendar_token = COALESCE(calendar_token, $2) WHERE id = $1 RETURNING calendar_token`,
+		userID, token).Scan(&stored)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)
+		}
+		return "", fmt.Errorf("storing calendar token: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (c *client) GetUserIDByCalendarToken(token string) (int, error) {
+	var userID int
+	err := c.db.QueryRow(`SELECT id FROM users WHERE calendar_token = $1`, token).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("no calendar found: %w", ErrNotFound)
+		}
+		return 0, fmt.Errorf("querying calendar token: %w", err)
+	}
+
+	return userID, nil
+}
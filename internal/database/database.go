@@ -2,12 +2,15 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"game-student-go/internal/listing"
 	"game-student-go/internal/model"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"github.com/stripe/stripe-go/v74"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 	"time"
 )
 
@@ -17,7 +20,7 @@ type Client interface {
 	GetUsers() ([]model.User, error)
 	GetUserByEmail(email string) (model.User, error)
 	GetUserByID(id int) (model.User, error)
-	GetCourses() ([]model.Course, error)
+	GetCourses(filter model.CourseFilter, page listing.Params) ([]model.Course, error)
 	GetCourseByID(id int) (model.Course, error)
 	GetTrainingByID(id int) (model.Training, error)
 	AddCard(userID int, stripePayMethodID string) (*model.Card, error)
@@ -25,12 +28,216 @@ type Client interface {
 	AddPayment(pi *stripe.PaymentIntent, userID int) (*model.Payment, error)
 	GetPayment(paymentIntentID string) (*model.Payment, error)
 	UpdatePaymentStatus(payment *model.Payment) (*model.Payment, error)
+	StartTraining(userID, trainingID int) (*model.TrainingProgress, error)
+	CompleteTraining(userID, trainingID int) (*model.TrainingProgress, error)
+	UpdateTrainingPosition(userID, trainingID, positionSeconds int) (*model.TrainingProgress, error)
+	GetCourseProgress(userID, courseID int) (model.CourseProgress, error)
+	GetNextTraining(userID, courseID int) (model.Training, error)
+	IssueCertificate(certificate model.Certificate) (*model.Certificate, error)
+	GetCertificateByCode(code string) (*model.Certificate, error)
+	GetCertificatesByUser(userID int) ([]model.Certificate, error)
+	RevokeCertificate(code, reason string) (*model.Certificate, error)
+	CreateSubmission(userID, trainingID int, url, attachmentKey string) (*model.Submission, error)
+	ResubmitSubmission(id int, url, attachmentKey string) (*model.Submission, error)
+	GetSubmission(id int) (*model.Submission, error)
+	GetSubmissionsByUser(userID int) ([]model.Submission, error)
+	GetSubmissionsByStatus(status string) ([]model.Submission, error)
+	ReviewSubmission(id, reviewerID int, status, feedback string) (*model.Submission, error)
+	SaveQuiz(quiz model.Quiz) (*model.Quiz, error)
+	GetQuizByTraining(trainingID int) (*model.Quiz, error)
+	AddQuizAttempt(attempt model.QuizAttempt, maxAttempts int) (*model.QuizAttempt, error)
+	GetQuizAttempts(quizID, userID int) ([]model.QuizAttempt, error)
+	HasPassedQuiz(quizID, userID int) (bool, error)
+	GrantXP(grant model.XPGrant) (bool, error)
+	GetUserXP(userID int) (int, error)
+	GetXPLedger(userID int) ([]model.XPGrant, error)
+	RecomputeXP() error
+	CountCertificatesByUser(userID int) (int, error)
+	AwardBadge(userID int, badge string) error
+	GetUserBadges(userID int) ([]model.UserBadge, error)
+	RefreshLeaderboards(period string, seasonStart time.Time) error
+	GetLeaderboard(scope string, scopeID int, period string, seasonStart time.Time, limit int) ([]model.LeaderboardEntry, error)
+	GetLeaderboardEntry(scope string, scopeID int, period string, seasonStart time.Time, userID int) (*model.LeaderboardEntry, error)
+	SetLeaderboardOptOut(userID int, optOut bool) error
+	SetUserTimeZone(userID int, timeZone string) error
+	UpdateUserProfile(user model.User) (model.User, error)
+	SetUserPassword(userID int, password string) error
+	CreateEmailChange(change model.EmailChange, tokenHash string) error
+	ConfirmEmailChange(tokenHash string) (model.User, string, error)
+	GetPersonalData(userID int) (*model.PersonalData, error)
+	CreateDataExport(userID int) (*model.DataExport, error)
+	GetDataExport(id int) (*model.DataExport, error)
+	GetDataExports(userID int) ([]model.DataExport, error)
+	GetPendingDataExports(limit int) ([]model.DataExport, error)
+	GetExpiredDataExports(now time.Time) ([]model.DataExport, error)
+	CompleteDataExport(id int, fileKey string, expiresAt time.Time) error
+	FailDataExport(id int, reason string) error
+	ExpireDataExport(id int) error
+	LogAccountDeletion(deletion model.AccountDeletion) (int, error)
+	AnonymizeUser(userID, deletionID int) error
+	StartTwoFactorEnrollment(userID int, secret string, recoveryCodeHashes []string) error
+	GetTwoFactor(userID int) (*model.TwoFactor, error)
+	EnableTwoFactor(userID int, step int64) error
+	DisableTwoFactor(userID int) error
+	UseTOTPStep(userID int, step int64) (bool, error)
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	CreateTwoFactorChallenge(userID int, tokenHash string, expiresAt time.Time) error
+	AttemptTwoFactorChallenge(tokenHash string, maxAttempts int) (int, error)
+	DeleteTwoFactorChallenge(tokenHash string) error
+	GetTwoFactorRoles() ([]string, error)
+	SetTwoFactorRoles(roles []string) error
+	RecordSigninAttempt(key string, now time.Time, window time.Duration) (*model.SigninThrottle, error)
+	LockSignin(key string, until time.Time) error
+	UncountSigninAttempt(key string) error
+	ClearSigninFailures(key string) error
+	DeleteStaleSigninThrottles(before time.Time) error
+	CreateOAuthState(state model.OAuthState) error
+	ConsumeOAuthState(stateHash, provider string) (*model.OAuthState, error)
+	DeleteExpiredOAuthStates(now time.Time) error
+	CreateOAuthSignin(signin model.OAuthSignin) error
+	GetOAuthSignin(codeHash string) (*model.OAuthSignin, error)
+	DeleteOAuthSignin(codeHash string) error
+	GetUserByIdentity(provider, subject string) (model.User, error)
+	LinkUserIdentity(identity model.UserIdentity) error
+	GetUserIdentities(userID int) ([]model.UserIdentity, error)
+	RecordActivity(userID int, day time.Time, kind string) error
+	GetActivity(userID int, since time.Time) ([]model.ActivityDay, error)
+	GetStreakReminders(hour int) ([]model.StreakReminder, error)
+	MarkStreakReminderSent(userID int, day time.Time) error
+	SearchCatalog(query string, limit int) ([]model.SearchResult, error)
+	FuzzySearchCatalog(query string, limit int) ([]model.SearchResult, error)
+	GetCategories() ([]model.Category, error)
+	CreateCategory(category model.Category) (*model.Category, error)
+	UpdateCategory(category model.Category) (*model.Category, error)
+	DeleteCategory(id int) error
+	GetTags() ([]string, error)
+	SetCourseTaxonomy(courseID int, categoryID *int, difficulty string, tags []string) error
+	GetCourseFacets(filter model.CourseFilter) (model.CourseFacets, error)
+	SetCourseStatus(courseID int, status string, publishAt *time.Time) (model.Course, error)
+	SetTrainingStatus(trainingID int, status string, publishAt *time.Time) (model.Training, error)
+	PublishScheduled(now time.Time) error
+	ClaimUnannouncedCourses() ([]model.Course, error)
+	AddPrerequisite(courseID, prerequisiteID int, required bool) error
+	RemovePrerequisite(courseID, prerequisiteID int) error
+	GetPrerequisites(courseID int) ([]model.Prerequisite, error)
+	GetMissingPrerequisites(userID, courseID int) ([]model.Prerequisite, error)
+	HasStartedCourse(userID, courseID int) (bool, error)
+	SaveLearningPath(path model.LearningPath) (*model.LearningPath, error)
+	DeleteLearningPath(id int) error
+	GetLearningPaths() ([]model.LearningPath, error)
+	GetLearningPath(id int) (*model.LearningPath, error)
+	CreateInstructor(userID int) (*model.Instructor, error)
+	GetInstructor(userID int) (*model.Instructor, error)
+	UpdateInstructor(instructor model.Instructor) (*model.Instructor, error)
+	SetCourseInstructors(courseID int, instructorIDs []int) error
+	CreateReview(review model.Review) (*model.Review, error)
+	UpdateReview(id, rating int, body string) (*model.Review, error)
+	GetReview(id int) (*model.Review, error)
+	GetCourseReviews(courseID int, page listing.Params) ([]model.Review, error)
+	SetReviewHelpful(reviewID, userID int, helpful bool) (*model.Review, error)
+	ReportReview(reviewID, userID int, reason string) error
+	GetReportedReviews() ([]model.Review, error)
+	SetReviewHidden(id int, hidden bool) (*model.Review, error)
+	ReplyToReview(reviewID, instructorID int, body string) (*model.Review, error)
+	CreateQuestion(question model.Question) (*model.Question, error)
+	GetQuestion(id int) (*model.Question, error)
+	GetTrainingQuestions(trainingID int, page listing.Params, includeHidden bool) ([]model.Question, error)
+	UpdateQuestion(id int, title, body string) (*model.Question, error)
+	SetQuestionHidden(id int, hidden bool) (*model.Question, error)
+	SetQuestionLocked(id int, locked bool) (*model.Question, error)
+	AcceptAnswer(questionID int, answerID *int) (*model.Question, error)
+	DeleteQuestion(id int) error
+	CreateAnswer(answer model.Answer) (*model.Answer, error)
+	GetAnswer(id int) (*model.Answer, error)
+	GetAnswers(questionID int, includeHidden bool) ([]model.Answer, error)
+	UpdateAnswer(id int, body string) (*model.Answer, error)
+	SetAnswerHidden(id int, hidden bool) (*model.Answer, error)
+	DeleteAnswer(id int) error
+	SetQuestionVote(questionID, userID int, up bool) (*model.Question, error)
+	SetAnswerVote(answerID, userID int, up bool) (*model.Answer, error)
+	CreateNote(note model.Note) (*model.Note, error)
+	GetNote(id int) (*model.Note, error)
+	UpdateNote(id, positionSeconds int, body string) (*model.Note, error)
+	DeleteNote(id int) error
+	GetTrainingNotes(userID, trainingID int) ([]model.Note, error)
+	GetCourseNotes(userID, courseID int) ([]model.Note, error)
+	SearchNotes(userID int, text string, limit int) ([]model.Note, error)
+	AddBookmark(userID, trainingID int) error
+	RemoveBookmark(userID, trainingID int) error
+	GetBookmarks(userID int) ([]model.Bookmark, error)
+	CreateCohort(cohort model.Cohort) (*model.Cohort, error)
+	UpdateCohort(cohort model.Cohort) (*model.Cohort, error)
+	DeleteCohort(id int) error
+	GetCohort(id int) (*model.Cohort, error)
+	GetCourseCohorts(courseID int) ([]model.Cohort, error)
+	GetUserCohorts(userID int) ([]model.Cohort, error)
+	Enroll(cohortID, userID int, status string) (*model.Enrollment, error)
+	GetEnrollment(cohortID, userID int) (*model.Enrollment, error)
+	GetCohortEnrollments(cohortID int) ([]model.Enrollment, error)
+	AddEnrollmentPayment(cohortID, userID int, pi *stripe.PaymentIntent) (*model.Payment, error)
+	SetEnrollmentStatus(cohortID, userID int, status string) error
+	CancelPendingEnrollment(cohortID, userID int) error
+	GetExpiredEnrollments(before time.Time) ([]model.PendingEnrollment, error)
+	SetPaymentEnrollmentStatus(paymentID int, status string) error
+	CreateLiveSession(session model.LiveSession) (*model.LiveSession, error)
+	UpdateLiveSession(session model.LiveSession) (*model.LiveSession, error)
+	DeleteLiveSession(id int) error
+	GetLiveSession(id int) (*model.LiveSession, error)
+	GetCohortSessions(cohortID int) ([]model.LiveSession, error)
+	GetUserSessions(userID int) ([]model.LiveSession, error)
+	GetSessionReminders(from, until time.Time) ([]model.SessionReminder, error)
+	MarkSessionReminded(sessionID int) error
+	EnsureCalendarToken(userID int, token string) (string, error)
+	GetUserIDByCalendarToken(token string) (int, error)
+	CreateJam(jam model.GameJam) (*model.GameJam, error)
+	UpdateJam(jam model.GameJam) (*model.GameJam, error)
+	GetJam(id int) (*model.GameJam, error)
+	GetJams() ([]model.GameJam, error)
+	CreateJamTeam(team model.JamTeam, userID int) (*model.JamTeam, error)
+	JoinJamTeam(jamID int, inviteCode string, userID int) (*model.JamTeam, error)
+	LeaveJamTeam(teamID, userID int) error
+	GetJamTeam(id int) (*model.JamTeam, error)
+	GetJamTeams(jamID int) ([]model.JamTeam, error)
+	SaveJamEntry(entry model.JamEntry) (*model.JamEntry, error)
+	GetJamEntry(id int) (*model.JamEntry, error)
+	GetTeamEntry(teamID int) (*model.JamEntry, error)
+	GetJamEntries(jamID int) ([]model.JamEntry, error)
+	SaveJamVotes(entryID, userID int, scores map[int]int) error
+	PublishJamResults(now time.Time) ([]int, error)
+	SavePortfolio(portfolio model.Portfolio) (*model.Portfolio, error)
+	GetPortfolio(userID int) (*model.Portfolio, error)
+	GetPortfolioBySlug(slug string) (*model.Portfolio, error)
+	GetPortfolioItems(userID int) ([]model.PortfolioItem, error)
+	SetPortfolioItemVisibility(userID int, kind, key string, visible bool) error
+}
+
+// ErrNotFound is wrapped by lookups that match no rows so callers can tell
+// a missing record apart from a failing query.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is wrapped when an insert clashes with an existing record.
+var ErrConflict = errors.New("already exists")
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
 }
 
 type client struct {
 	db *sql.DB
 }
 
+// rowScanner is satisfied by both *sql.Row and *sql.Rows so scan helpers can
+// serve single lookups and listings alike.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
 func NewClient(connStr string) (Client, error) {
 	db, err := sql.Open("postgres", connStr)
 
@@ -41,16 +248,30 @@ func NewClient(connStr string) (Client, error) {
 	return &client{db: db}, nil
 }
 
+// userColumns selects a user, password hash included, to be read with
+// scanUser.
+const userColumns = `id, email, password, COALESCE(stripe_id, ''), role, time_zone, display_name, avatar_url, bio, locale, country,
+	totp_enabled_at IS NOT NULL, deleted_at IS NOT NULL`
+
+func scanUser(row rowScanner) (model.User, error) {
+	var user model.User
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.StripeId, &user.Role, &user.TimeZone, &user.DisplayName,
+		&user.AvatarURL, &user.Bio, &user.Locale, &user.Country, &user.TwoFactorEnabled, &user.Deleted)
+	return user, err
+}
+
 func (c *client) CreateUser(email, password, stripeId string) (model.User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return model.User{}, fmt.Errorf("hashing password: %w", err)
 	}
 
-	query := `INSERT INTO users (email, password, stripe_id) VALUES ($1, $2, $3) RETURNING id, email, stripe_id`
-	var user model.User
-	err = c.db.QueryRow(query, email, hashedPassword, stripeId).Scan(&user.ID, &user.Email, &user.StripeId)
+	query := `INSERT INTO users (email, password, stripe_id) VALUES ($1, $2, $3) RETURNING ` + userColumns
+	user, err := scanUser(c.db.QueryRow(query, email, hashedPassword, stripeId))
 	if err != nil {
+		if isUniqueViolation(err) {
+			return model.User{}, fmt.Errorf("email %s is already in use: %w", email, ErrConflict)
+		}
 		return model.User{}, fmt.Errorf("executing user insert and returning data: %w", err)
 	}
 
@@ -58,7 +279,7 @@ func (c *client) CreateUser(email, password, stripeId string) (model.User, error
 }
 
 func (c *client) GetUsers() ([]model.User, error) {
-	rows, err := c.db.Query("SELECT ID, email FROM users")
+	rows, err := c.db.Query("SELECT ID, email FROM users WHERE deleted_at IS NULL")
 	if err != nil {
 		return nil, err
 	}
@@ -84,12 +305,10 @@ func (c *client) Close() {
 }
 
 func (c *client) GetUserByEmail(email string) (model.User, error) {
-	query := `SELECT id, email, password FROM users WHERE email = $1`
-	var user model.User
-	err := c.db.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.Password)
+	user, err := scanUser(c.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE lower(email) = lower($1)`, email))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return model.User{}, fmt.Errorf("no user found with email: %s", email)
+			return model.User{}, fmt.Errorf("no user found with email %s: %w", email, ErrNotFound)
 		}
 		return model.User{}, fmt.Errorf("querying for user by email: %w", err)
 	}
@@ -98,12 +317,10 @@ func (c *client) GetUserByEmail(email string) (model.User, error) {
 }
 
 func (c *client) GetUserByID(id int) (model.User, error) {
-	query := `SELECT id, email FROM users WHERE id = $1`
-	var user model.User
-	err := c.db.QueryRow(query, id).Scan(&user.ID, &user.Email)
+	user, err := scanUser(c.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return model.User{}, fmt.Errorf("no user found with id: %d", id)
+			return model.User{}, fmt.Errorf("no user found with id %d: %w", id, ErrNotFound)
 		}
 		return model.User{}, fmt.Errorf("querying for user by id: %w", err)
 	}
@@ -111,8 +328,133 @@ func (c *client) GetUserByID(id int) (model.User, error) {
 	return user, nil
 }
 
-func (c *client) GetCourses() ([]model.Course, error) {
-	rows, err := c.db.Query("SELECT ID, name, description, logo_url FROM courses")
+// courseColumns selects a course along with its tags, instructors and
+// rating, to be read with scanCourse.
+const courseColumns = `courses.id, courses.name, courses.description, courses.logo_url, courses.category_id, courses.difficulty,
+	COALESCE((SELECT array_agg(tags.name ORDER BY tags.name) FROM course_tags JOIN tags ON tags.id = course_tags.tag_id
+	          WHERE course_tags.course_id = courses.id), '{}'),
+	courses.status, courses.publish_at, courses.published_at,
+	COALESCE((SELECT array_agg(ci.instructor_id ORDER BY ci.instructor_id) FROM course_instructors ci
+	          WHERE ci.course_id = courses.id), '{}'),
+	(SELECT COALESCE(ROUND(AVG(r.rating), 2), 0)::float8 FROM course_reviews r WHERE r.course_id = courses.id AND r.hidden_at IS NULL),
+	(SELECT COUNT(*) FROM course_reviews r WHERE r.course_id = courses.id AND r.hidden_at IS NULL)`
+
+func scanCourse(row rowScanner) (model.Course, error) {
+	var course model.Course
+	var categoryID sql.NullInt64
+	var difficulty sql.NullString
+	var publishAt, publishedAt sql.NullTime
+	var instructors []int64
+	err := row.Scan(&course.ID, &course.Name, &course.Description, &course.LogoURL, &categoryID, &difficulty, pq.Array(&course.Tags),
+		&course.Status, &publishAt, &publishedAt, pq.Array(&instructors), &course.Rating, &course.RatingCount)
+	if err != nil {
+		return model.Course{}, err
+	}
+
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		course.CategoryID = &id
+	}
+	course.InstructorIDs = make([]int, len(instructors))
+	for i, id := range instructors {
+		course.InstructorIDs[i] = int(id)
+	}
+	if publishAt.Valid {
+		course.PublishAt = &publishAt.Time
+	}
+	if publishedAt.Valid {
+		course.PublishedAt = &publishedAt.Time
+	}
+	course.Difficulty = difficulty.String
+
+	return course, nil
+}
+
+// courseSorts maps the sort fields accepted by GetCourses to their columns.
+var courseSorts = map[string]string{
+	"id":   "courses.id",
+	"name": "courses.name",
+}
+
+// CourseSorts lists the fields courses can be sorted by, the default first.
+var CourseSorts = []string{"name", "id"}
+
+// courseConditions turns a filter into WHERE conditions over the courses
+// table, appending their values to args.
+func courseConditions(filter model.CourseFilter, args []interface{}) ([]string, []interface{}) {
+	var conditions []string
+
+	switch {
+	case filter.Preview.All:
+	case filter.Preview.InstructorID != 0:
+		args = append(args, filter.Preview.InstructorID)
+		conditions = append(conditions, fmt.Sprintf(
+			"(courses.status = 'published' OR EXISTS (SELECT 1 FROM course_instructors ci WHERE ci.course_id = courses.id AND ci.instructor_id = $%d))", len(args)))
+	default:
+		conditions = append(conditions, "courses.status = 'published'")
+	}
+
+	if filter.Search != "" {
+		args = append(args, "%"+escapeLike(filter.Search)+"%")
+		conditions = append(conditions, fmt.Sprintf("courses.name ILIKE $%d", len(args)))
+	}
+
+	if filter.HasFreeTrainings != nil {
+		exists := "EXISTS (SELECT 1 FROM trainings t WHERE t.course_id = courses.id AND t.is_free AND t.status = 'published')"
+		if !*filter.HasFreeTrainings {
+			exists = "NOT " + exists
+		}
+		conditions = append(conditions, exists)
+	}
+
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		conditions = append(conditions, fmt.Sprintf("courses.category_id = (SELECT id FROM categories WHERE slug = $%d)", len(args)))
+	}
+
+	for _, tag := range filter.Tags {
+		args = append(args, tag)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM course_tags ct JOIN tags tg ON tg.id = ct.tag_id WHERE ct.course_id = courses.id AND tg.name = $%d)", len(args)))
+	}
+
+	if filter.InstructorID != 0 {
+		args = append(args, filter.InstructorID)
+		conditions = append(conditions, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM course_instructors ci WHERE ci.course_id = courses.id AND ci.instructor_id = $%d)", len(args)))
+	}
+
+	if filter.Difficulty != "" {
+		args = append(args, filter.Difficulty)
+		conditions = append(conditions, fmt.Sprintf("courses.difficulty = $%d", len(args)))
+	}
+
+	return conditions, args
+}
+
+// GetCourses returns one page of courses matching the filter. It fetches one
+// row past the limit so the caller can tell whether there is a next page.
+func (c *client) GetCourses(filter model.CourseFilter, page listing.Params) ([]model.Course, error) {
+	conditions, args := courseConditions(filter, nil)
+
+	column, ok := courseSorts[page.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown course sort: %s", page.Sort)
+	}
+
+	keyset, orderBy, args := page.Keyset(column, "courses.id", args)
+	if keyset != "" {
+		conditions = append(conditions, keyset)
+	}
+
+	query := "SELECT " + courseColumns + " FROM courses"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, page.Limit+1)
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d", orderBy, len(args))
+
+	rows, err := c.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -120,8 +462,8 @@ func (c *client) GetCourses() ([]model.Course, error) {
 
 	var courses []model.Course
 	for rows.Next() {
-		var course model.Course
-		if err := rows.Scan(&course.ID, &course.Name, &course.Description, &course.LogoURL); err != nil {
+		course, err := scanCourse(rows)
+		if err != nil {
 			return nil, err
 		}
 		courses = append(courses, course)
@@ -130,13 +472,17 @@ func (c *client) GetCourses() ([]model.Course, error) {
 	return courses, nil
 }
 
+// escapeLike escapes the LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (c *client) GetCourseByID(id int) (model.Course, error) {
-	query := `SELECT id, name, description, logo_url FROM courses WHERE id = $1`
-	var course model.Course
-	err := c.db.QueryRow(query, id).Scan(&course.ID, &course.Name, &course.Description, &course.LogoURL)
+	query := `SELECT ` + courseColumns + ` FROM courses WHERE id = $1`
+	course, err := scanCourse(c.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return model.Course{}, fmt.Errorf("no course found with id: %v", id)
+			return model.Course{}, fmt.Errorf("no course found with id %v: %w", id, ErrNotFound)
 		}
 		return model.Course{}, fmt.Errorf("querying for course by id: %w", err)
 	}
@@ -144,13 +490,32 @@ func (c *client) GetCourseByID(id int) (model.Course, error) {
 	return course, nil
 }
 
-func (c *client) GetTrainingByID(id int) (model.Training, error) {
-	query := `SELECT id, sequence, topic, name, url, is_free, project_url, course_id FROM trainings WHERE id = $1`
+// trainingColumns selects a training, to be read with scanTraining.
+const trainingColumns = `trainings.id, trainings.sequence, trainings.topic, trainings.name, trainings.url, trainings.is_free,
+	trainings.project_url, trainings.course_id, trainings.status, trainings.publish_at`
+
+func scanTraining(row rowScanner) (model.Training, error) {
 	var training model.Training
-	err := c.db.QueryRow(query, id).Scan(&training.ID, &training.Sequence, &training.Topic, &training.Name, &training.URL, &training.IsFree, &training.ProjectURL, &training.CourseID)
+	var publishAt sql.NullTime
+	err := row.Scan(&training.ID, &training.Sequence, &training.Topic, &training.Name, &training.URL, &training.IsFree,
+		&training.ProjectURL, &training.CourseID, &training.Status, &publishAt)
+	if err != nil {
+		return model.Training{}, err
+	}
+
+	if publishAt.Valid {
+		training.PublishAt = &publishAt.Time
+	}
+
+	return training, nil
+}
+
+func (c *client) GetTrainingByID(id int) (model.Training, error) {
+	query := `SELECT ` + trainingColumns + ` FROM trainings WHERE id = $1`
+	training, err := scanTraining(c.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return model.Training{}, fmt.Errorf("no training found with id: %v", id)
+			return model.Training{}, fmt.Errorf("no training found with id %v: %w", id, ErrNotFound)
 		}
 		return model.Training{}, fmt.Errorf("querying for training by id: %w", err)
 	}
@@ -211,7 +576,7 @@ func (c *client) AddPayment(pi *stripe.PaymentIntent, userID int) (*model.Paymen
 
 	query := `
 				INSERT INTO payments (stripe_payment_intent_id, stripe_pay_method_id, user_id, amount, currency, status, created_at, updated_at)
-				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 				RETURNING id
 			`
 
@@ -246,7 +611,7 @@ func (c *client) GetPayment(paymentIntentID string) (*model.Payment, error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no payment found with ID: %s", paymentIntentID)
+			return nil, fmt.Errorf("no payment found with ID %s: %w", paymentIntentID, ErrNotFound)
 		}
 
 		return nil, fmt.Errorf("unable to get payment: %w", err)
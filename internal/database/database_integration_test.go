@@ -1,9 +1,15 @@
 package database
 
 import (
+	"game-student-go/internal/listing"
+	"game-student-go/internal/model"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v74"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func setupDatabase(t *testing.T) Client {
@@ -17,6 +23,10 @@ func setupDatabase(t *testing.T) Client {
 			t.Fatalf("Failed to clean up users table: %v", err)
 		}
 
+		if _, err = c.(*client).db.Exec("DELETE FROM trainings"); err != nil {
+			t.Fatalf("Failed to clean up trainings table: %v", err)
+		}
+
 		if _, err = c.(*client).db.Exec("DELETE FROM courses"); err != nil {
 			t.Fatalf("Failed to clean up users table: %v", err)
 		}
@@ -28,6 +38,10 @@ func setupDatabase(t *testing.T) Client {
 		t.Fatalf("Failed to clean up users table: %v", err)
 	}
 
+	if _, err = c.(*client).db.Exec("DELETE FROM trainings"); err != nil {
+		t.Fatalf("Failed to clean up trainings table: %v", err)
+	}
+
 	if _, err = c.(*client).db.Exec("DELETE FROM courses"); err != nil {
 		t.Fatalf("Failed to clean up users table: %v", err)
 	}
@@ -91,12 +105,12 @@ func TestGetUsers(t *testing.T) {
 func TestGetCourses(t *testing.T) {
 	db := setupDatabase(t)
 
-	if _, err := db.(*client).db.Exec("INSERT INTO courses (name, description, logo_url) VALUES ('Intro to Programming', 'A beginner course for programming.', 'http://example.com/logo.png')"); err != nil {
+	if _, err := db.(*client).db.Exec("INSERT INTO courses (name, description, logo_url, status) VALUES ('Intro to Programming', 'A beginner course for programming.', 'http://example.com/logo.png', 'published')"); err != nil {
 		t.Fatalf("Failed to clean up users table: %v", err)
 	}
 
 	// Assuming some courses exist in the database
-	courses, err := db.GetCourses()
+	courses, err := db.GetCourses(model.CourseFilter{}, listing.Params{Limit: 10, Sort: "name"})
 	if err != nil {
 		t.Fatalf("Failed to fetch courses: %v", err)
 	}
@@ -123,3 +137,1444 @@ func TestGetCourseByID(t *testing.T) {
 	// Assert that the course has the correct ID
 	assert.Equal(t, id, course.ID)
 }
+
+func insertCourseWithTrainings(t *testing.T, db Client, trainings int) (int, []int) {
+	row := db.(*client).db.QueryRow("INSERT INTO courses (name, description, logo_url, status) VALUES ('Intro to Programming', 'A beginner course for programming.', 'http://example.com/logo.png', 'published') RETURNING id")
+	var courseID int
+	if err := row.Scan(&courseID); err != nil {
+		t.Fatalf("Failed to insert course: %v", err)
+	}
+
+	var trainingIDs []int
+	for i := 1; i <= trainings; i++ {
+		row := db.(*client).db.QueryRow("INSERT INTO trainings (sequence, topic, name, url, is_free, course_id, status) VALUES ($1, 'Basics', 'Lesson', 'http://example.com/video', false, $2, 'published') RETURNING id", i, courseID)
+		var trainingID int
+		if err := row.Scan(&trainingID); err != nil {
+			t.Fatalf("Failed to insert training: %v", err)
+		}
+		trainingIDs = append(trainingIDs, trainingID)
+	}
+
+	return courseID, trainingIDs
+}
+
+func TestCourseProgress(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("TestUser@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	courseID, trainingIDs := insertCourseWithTrainings(t, db, 2)
+
+	if _, err := db.StartTraining(user.ID, trainingIDs[0]); err != nil {
+		t.Fatalf("Failed to start training: %v", err)
+	}
+	completed, err := db.CompleteTraining(user.ID, trainingIDs[0])
+	if err != nil {
+		t.Fatalf("Failed to complete training: %v", err)
+	}
+	assert.NotNil(t, completed.CompletedAt)
+
+	progress, err := db.GetCourseProgress(user.ID, courseID)
+	if err != nil {
+		t.Fatalf("Failed to fetch course progress: %v", err)
+	}
+	assert.Equal(t, 2, progress.TotalTrainings)
+	assert.Equal(t, 1, progress.CompletedTrainings)
+	assert.Equal(t, 50.0, progress.Percentage)
+
+	next, err := db.GetNextTraining(user.ID, courseID)
+	if err != nil {
+		t.Fatalf("Failed to fetch next training: %v", err)
+	}
+	assert.Equal(t, trainingIDs[1], next.ID)
+
+	if _, err := db.CompleteTraining(user.ID, trainingIDs[1]); err != nil {
+		t.Fatalf("Failed to complete training: %v", err)
+	}
+	_, err = db.GetNextTraining(user.ID, courseID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestIssueCertificate(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("TestUser@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	courseID, _ := insertCourseWithTrainings(t, db, 1)
+
+	issued, err := db.IssueCertificate(model.Certificate{Code: "FIRST", UserID: user.ID, CourseID: courseID, StudentName: user.Email, CourseName: "Intro to Programming"})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	// Issuing again keeps the original certificate
+	again, err := db.IssueCertificate(model.Certificate{Code: "SECOND", UserID: user.ID, CourseID: courseID, StudentName: user.Email, CourseName: "Intro to Programming"})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	assert.Equal(t, issued.Code, again.Code)
+
+	revoked, err := db.RevokeCertificate(issued.Code, "plagiarism")
+	if err != nil {
+		t.Fatalf("Failed to revoke certificate: %v", err)
+	}
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = db.GetCertificateByCode("UNKNOWN")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSubmissionReview(t *testing.T) {
+	db := setupDatabase(t)
+
+	student, err := db.CreateUser("TestUser@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	instructor, err := db.CreateUser("TestInstructor@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	_, trainingIDs := insertCourseWithTrainings(t, db, 1)
+
+	submission, err := db.CreateSubmission(student.ID, trainingIDs[0], "https://github.com/student/game", "")
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	assert.Equal(t, model.SubmissionPending, submission.Status)
+
+	_, err = db.CreateSubmission(student.ID, trainingIDs[0], "https://github.com/student/game", "")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	queue, err := db.GetSubmissionsByStatus(model.SubmissionPending)
+	if err != nil {
+		t.Fatalf("Failed to fetch review queue: %v", err)
+	}
+	assert.Len(t, queue, 1)
+
+	reviewed, err := db.ReviewSubmission(submission.ID, instructor.ID, model.SubmissionChangesRequested, "Add a title screen")
+	if err != nil {
+		t.Fatalf("Failed to review submission: %v", err)
+	}
+	assert.Equal(t, "Add a title screen", reviewed.Feedback)
+
+	resubmitted, err := db.ResubmitSubmission(submission.ID, "https://github.com/student/game-v2", "")
+	if err != nil {
+		t.Fatalf("Failed to resubmit: %v", err)
+	}
+	assert.Equal(t, model.SubmissionPending, resubmitted.Status)
+	assert.Nil(t, resubmitted.ReviewedAt)
+}
+
+func TestSaveQuiz(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("TestUser@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	_, trainingIDs := insertCourseWithTrainings(t, db, 1)
+
+	fps := 60.0
+	saved, err := db.SaveQuiz(model.Quiz{
+		TrainingID:   trainingIDs[0],
+		PassingScore: 50,
+		Questions: []model.QuizQuestion{
+			{Kind: model.QuestionSingleChoice, Prompt: "Which engine uses GDScript?", Points: 1, Options: []model.QuizOption{
+				{Text: "Unity"}, {Text: "Godot", Correct: true},
+			}},
+			{Kind: model.QuestionNumeric, Prompt: "Target frame rate?", Points: 1, CorrectNumber: &fps},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to save quiz: %v", err)
+	}
+
+	quiz, err := db.GetQuizByTraining(trainingIDs[0])
+	if err != nil {
+		t.Fatalf("Failed to fetch quiz: %v", err)
+	}
+	assert.Equal(t, saved.ID, quiz.ID)
+	assert.Len(t, quiz.Questions, 2)
+	assert.Len(t, quiz.Questions[0].Options, 2)
+	assert.Equal(t, fps, *quiz.Questions[1].CorrectNumber)
+
+	attempt := model.QuizAttempt{QuizID: quiz.ID, UserID: user.ID, Score: 100, Passed: true, Answers: []model.QuizAnswer{}}
+	if _, err := db.AddQuizAttempt(attempt, 1); err != nil {
+		t.Fatalf("Failed to add attempt: %v", err)
+	}
+	_, err = db.AddQuizAttempt(attempt, 1)
+	assert.ErrorIs(t, err, ErrConflict, "Attempts stop at the quiz limit")
+
+	passed, err := db.HasPassedQuiz(quiz.ID, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to check quiz: %v", err)
+	}
+	assert.True(t, passed)
+}
+
+func TestGrantXP(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("TestUser@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	granted, err := db.GrantXP(model.XPGrant{UserID: user.ID, Reason: "training_completed", SourceID: 1, Amount: 10})
+	if err != nil {
+		t.Fatalf("Failed to grant xp: %v", err)
+	}
+	assert.True(t, granted)
+
+	// The same source is only rewarded once
+	granted, err = db.GrantXP(model.XPGrant{UserID: user.ID, Reason: "training_completed", SourceID: 1, Amount: 10})
+	if err != nil {
+		t.Fatalf("Failed to grant xp: %v", err)
+	}
+	assert.False(t, granted)
+
+	if _, err := db.GrantXP(model.XPGrant{UserID: user.ID, Reason: "quiz_passed", SourceID: 1, Amount: 20}); err != nil {
+		t.Fatalf("Failed to grant xp: %v", err)
+	}
+
+	if err := db.RecomputeXP(); err != nil {
+		t.Fatalf("Failed to recompute xp: %v", err)
+	}
+
+	total, err := db.GetUserXP(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch xp: %v", err)
+	}
+	assert.Equal(t, 30, total)
+
+	if _, err := db.(*client).db.Exec(`DELETE FROM xp_ledger WHERE user_id = $1`, user.ID); err != nil {
+		t.Fatalf("Failed to delete grants: %v", err)
+	}
+	if err := db.RecomputeXP(); err != nil {
+		t.Fatalf("Failed to recompute xp: %v", err)
+	}
+	total, _ = db.GetUserXP(user.ID)
+	assert.Equal(t, 0, total, "Users without grants go back to zero")
+}
+
+func TestRefreshLeaderboards(t *testing.T) {
+	db := setupDatabase(t)
+
+	first, err := db.CreateUser("First@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	second, err := db.CreateUser("Second@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	hidden, err := db.CreateUser("Hidden@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	courseID, _ := insertCourseWithTrainings(t, db, 0)
+
+	grants := []model.XPGrant{
+		{UserID: first.ID, Reason: "quiz_passed", SourceID: 1, Amount: 20, CourseID: courseID},
+		{UserID: second.ID, Reason: "training_completed", SourceID: 1, Amount: 10},
+		{UserID: hidden.ID, Reason: "submission_approved", SourceID: 1, Amount: 50},
+	}
+	for _, grant := range grants {
+		if _, err := db.GrantXP(grant); err != nil {
+			t.Fatalf("Failed to grant xp: %v", err)
+		}
+	}
+
+	if err := db.SetLeaderboardOptOut(hidden.ID, true); err != nil {
+		t.Fatalf("Failed to opt out: %v", err)
+	}
+
+	season := time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := db.RefreshLeaderboards(model.PeriodAllTime, season); err != nil {
+		t.Fatalf("Failed to refresh leaderboards: %v", err)
+	}
+
+	global, err := db.GetLeaderboard(model.LeaderboardGlobal, 0, model.PeriodAllTime, season, 10)
+	if err != nil {
+		t.Fatalf("Failed to fetch leaderboard: %v", err)
+	}
+	assert.Equal(t, []model.LeaderboardEntry{{Rank: 1, UserID: first.ID, XP: 20}, {Rank: 2, UserID: second.ID, XP: 10}}, global)
+
+	course, err := db.GetLeaderboard(model.LeaderboardCourse, courseID, model.PeriodAllTime, season, 10)
+	if err != nil {
+		t.Fatalf("Failed to fetch leaderboard: %v", err)
+	}
+	assert.Len(t, course, 1)
+
+	_, err = db.GetLeaderboardEntry(model.LeaderboardGlobal, 0, model.PeriodAllTime, season, hidden.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRecordActivity(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("TestUser@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	day := time.Date(2023, 7, 1, 22, 0, 0, 0, time.FixedZone("BRT", -3*60*60))
+	for _, kind := range []string{model.ActivityTrainingCompleted, model.ActivityTrainingCompleted, model.ActivityQuizPassed} {
+		if err := db.RecordActivity(user.ID, day, kind); err != nil {
+			t.Fatalf("Failed to record activity: %v", err)
+		}
+	}
+
+	activity, err := db.GetActivity(user.ID, day.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("Failed to fetch activity: %v", err)
+	}
+	assert.Len(t, activity, 1)
+	assert.Equal(t, "2023-07-01", activity[0].Date.Format("2006-01-02"))
+	assert.Equal(t, 2, activity[0].TrainingsCompleted)
+	assert.Equal(t, 3, activity[0].Count)
+}
+
+func TestGetCoursesPagination(t *testing.T) {
+	db := setupDatabase(t)
+
+	for _, name := range []string{"Godot Basics", "Unity Gameplay", "Pixel Art", "Godot Shaders"} {
+		if _, err := db.(*client).db.Exec("INSERT INTO courses (name, description, logo_url, status) VALUES ($1, '', '', 'published')", name); err != nil {
+			t.Fatalf("Failed to insert course: %v", err)
+		}
+	}
+
+	page := listing.Params{Limit: 2, Sort: "name"}
+	first, err := db.GetCourses(model.CourseFilter{}, page)
+	if err != nil {
+		t.Fatalf("Failed to fetch courses: %v", err)
+	}
+	// One extra row tells there is a next page
+	assert.Len(t, first, 3)
+	assert.Equal(t, "Godot Basics", first[0].Name)
+
+	page.After = &listing.Cursor{Value: first[1].Name, ID: first[1].ID}
+	second, err := db.GetCourses(model.CourseFilter{}, page)
+	if err != nil {
+		t.Fatalf("Failed to fetch courses: %v", err)
+	}
+	assert.Len(t, second, 2)
+	assert.Equal(t, "Pixel Art", second[0].Name)
+
+	godot, err := db.GetCourses(model.CourseFilter{Search: "godot"}, listing.Params{Limit: 10, Sort: "id"})
+	if err != nil {
+		t.Fatalf("Failed to fetch courses: %v", err)
+	}
+	assert.Len(t, godot, 2)
+}
+
+func TestSearchCatalog(t *testing.T) {
+	db := setupDatabase(t)
+
+	if _, err := db.(*client).db.Exec("INSERT INTO courses (name, description, logo_url, status) VALUES ('Programação de jogos com Godot', 'Crie seus primeiros jogos 2D.', '', 'published')"); err != nil {
+		t.Fatalf("Failed to insert course: %v", err)
+	}
+
+	results, err := db.SearchCatalog("jogo", 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, model.SearchResultCourse, results[0].Type)
+		assert.Contains(t, results[0].Title, "<mark>")
+	}
+
+	if _, err := db.(*client).db.Exec(`INSERT INTO courses (name, description, logo_url, status) VALUES ('Roteiro <script>alert(1)</script>', 'Escreva roteiros', '', 'published')`); err != nil {
+		t.Fatalf("Failed to insert course: %v", err)
+	}
+	results, err = db.SearchCatalog("roteiro", 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if assert.Len(t, results, 1) {
+		assert.Contains(t, results[0].Title, "<mark>Roteiro</mark>")
+		assert.NotContains(t, results[0].Title, "<script>", "Course text is escaped")
+	}
+
+	results, err = db.FuzzySearchCatalog("Programacao de jogos com Godto", 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	assert.Len(t, results, 1)
+}
+
+func TestCourseTaxonomy(t *testing.T) {
+	db := setupDatabase(t)
+
+	courseID, _ := insertCourseWithTrainings(t, db, 1)
+	if _, err := db.(*client).db.Exec("INSERT INTO courses (name, description, logo_url) VALUES ('Other', '', '')"); err != nil {
+		t.Fatalf("Failed to insert course: %v", err)
+	}
+
+	category, err := db.CreateCategory(model.Category{Slug: "test-category", Name: "Test Category"})
+	if err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+	defer db.DeleteCategory(category.ID)
+
+	err = db.SetCourseTaxonomy(courseID, &category.ID, model.DifficultyBeginner, []string{"2d", "platformer"})
+	if err != nil {
+		t.Fatalf("Failed to set taxonomy: %v", err)
+	}
+
+	filter := model.CourseFilter{Category: "test-category", Tags: []string{"2d", "platformer"}}
+	courses, err := db.GetCourses(filter, listing.Params{Limit: 10, Sort: "id"})
+	if err != nil {
+		t.Fatalf("Failed to fetch courses: %v", err)
+	}
+	if assert.Len(t, courses, 1) {
+		assert.Equal(t, model.DifficultyBeginner, courses[0].Difficulty)
+		assert.ElementsMatch(t, []string{"2d", "platformer"}, courses[0].Tags)
+	}
+
+	facets, err := db.GetCourseFacets(model.CourseFilter{Category: "test-category"})
+	if err != nil {
+		t.Fatalf("Failed to fetch facets: %v", err)
+	}
+	assert.Contains(t, facets.Tags, model.FacetCount{Value: "platformer", Count: 1})
+	assert.Contains(t, facets.Difficulties, model.FacetCount{Value: model.DifficultyBeginner, Count: 1})
+}
+
+func TestPublishScheduled(t *testing.T) {
+	db := setupDatabase(t)
+
+	row := db.(*client).db.QueryRow("INSERT INTO courses (name, description, logo_url) VALUES ('Upcoming', '', '') RETURNING id")
+	var courseID int
+	if err := row.Scan(&courseID); err != nil {
+		t.Fatalf("Failed to insert course: %v", err)
+	}
+
+	courses, err := db.GetCourses(model.CourseFilter{}, listing.Params{Limit: 10, Sort: "id"})
+	if err != nil {
+		t.Fatalf("Failed to fetch courses: %v", err)
+	}
+	assert.Empty(t, courses, "Drafts must not be listed")
+
+	publishAt := time.Now().Add(time.Hour)
+	course, err := db.SetCourseStatus(courseID, model.PublicationScheduled, &publishAt)
+	if err != nil {
+		t.Fatalf("Failed to schedule course: %v", err)
+	}
+	assert.Equal(t, model.PublicationScheduled, course.Status)
+
+	if err := db.PublishScheduled(time.Now()); err != nil {
+		t.Fatalf("Failed to publish scheduled content: %v", err)
+	}
+	course, err = db.GetCourseByID(courseID)
+	if err != nil {
+		t.Fatalf("Failed to fetch course: %v", err)
+	}
+	assert.Equal(t, model.PublicationScheduled, course.Status)
+
+	if err := db.PublishScheduled(publishAt.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to publish scheduled content: %v", err)
+	}
+	course, err = db.GetCourseByID(courseID)
+	if err != nil {
+		t.Fatalf("Failed to fetch course: %v", err)
+	}
+	assert.Equal(t, model.PublicationPublished, course.Status)
+	assert.NotNil(t, course.PublishedAt)
+
+	announcing, err := db.ClaimUnannouncedCourses()
+	if err != nil {
+		t.Fatalf("Failed to claim unannounced courses: %v", err)
+	}
+	if assert.Len(t, announcing, 1) {
+		assert.Equal(t, courseID, announcing[0].ID)
+	}
+
+	announcing, err = db.ClaimUnannouncedCourses()
+	if err != nil {
+		t.Fatalf("Failed to claim unannounced courses: %v", err)
+	}
+	assert.Empty(t, announcing)
+}
+
+func TestPrerequisites(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("TestUser@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	intro, introTrainings := insertCourseWithTrainings(t, db, 1)
+	gameplay, _ := insertCourseWithTrainings(t, db, 1)
+
+	if err := db.AddPrerequisite(gameplay, intro, true); err != nil {
+		t.Fatalf("Failed to add prerequisite: %v", err)
+	}
+
+	err = db.AddPrerequisite(intro, gameplay, true)
+	assert.ErrorIs(t, err, ErrConflict, "Prerequisite cycles must be rejected")
+
+	missing, err := db.GetMissingPrerequisites(user.ID, gameplay)
+	if err != nil {
+		t.Fatalf("Failed to fetch missing prerequisites: %v", err)
+	}
+	if assert.Len(t, missing, 1) {
+		assert.Equal(t, intro, missing[0].CourseID)
+		assert.True(t, missing[0].Required)
+	}
+
+	if _, err := db.CompleteTraining(user.ID, introTrainings[0]); err != nil {
+		t.Fatalf("Failed to complete training: %v", err)
+	}
+	missing, err = db.GetMissingPrerequisites(user.ID, gameplay)
+	if err != nil {
+		t.Fatalf("Failed to fetch missing prerequisites: %v", err)
+	}
+	assert.Empty(t, missing)
+
+	empty, _ := insertCourseWithTrainings(t, db, 0)
+	draft, _ := insertCourseWithTrainings(t, db, 1)
+	if _, err := db.(*client).db.Exec("UPDATE courses SET status = 'draft' WHERE id = $1", draft); err != nil {
+		t.Fatalf("Failed to unpublish course: %v", err)
+	}
+	for _, prerequisite := range []int{empty, draft} {
+		if err := db.AddPrerequisite(gameplay, prerequisite, true); err != nil {
+			t.Fatalf("Failed to add prerequisite: %v", err)
+		}
+	}
+
+	missing, err = db.GetMissingPrerequisites(user.ID, gameplay)
+	if err != nil {
+		t.Fatalf("Failed to fetch missing prerequisites: %v", err)
+	}
+	assert.Empty(t, missing, "Courses that cannot be finished must not block")
+
+	prerequisites, err := db.GetPrerequisites(gameplay)
+	if err != nil {
+		t.Fatalf("Failed to fetch prerequisites: %v", err)
+	}
+	assert.Len(t, prerequisites, 2, "Unpublished prerequisites must be left out")
+}
+
+func TestSaveLearningPath(t *testing.T) {
+	db := setupDatabase(t)
+
+	first, _ := insertCourseWithTrainings(t, db, 1)
+	second, _ := insertCourseWithTrainings(t, db, 1)
+
+	path, err := db.SaveLearningPath(model.LearningPath{
+		Slug:    "test-path",
+		Name:    "Test Path",
+		Courses: []model.PathCourse{{CourseID: second}, {CourseID: first}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to save learning path: %v", err)
+	}
+	defer db.DeleteLearningPath(path.ID)
+
+	saved, err := db.GetLearningPath(path.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch learning path: %v", err)
+	}
+	if assert.Len(t, saved.Courses, 2) {
+		assert.Equal(t, second, saved.Courses[0].CourseID)
+		assert.Equal(t, model.PublicationPublished, saved.Courses[0].Status)
+	}
+}
+
+func TestInstructors(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("Teacher@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	instructor, err := db.CreateInstructor(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to create instructor: %v", err)
+	}
+	assert.Equal(t, defaultInstructorName, instructor.DisplayName, "Emails must not become public names")
+
+	promoted, err := db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch user: %v", err)
+	}
+	assert.Equal(t, model.RoleInstructor, promoted.Role)
+
+	instructor.SocialLinks = map[string]string{"github": "https://github.com/teacher"}
+	instructor, err = db.UpdateInstructor(*instructor)
+	if err != nil {
+		t.Fatalf("Failed to update instructor: %v", err)
+	}
+	assert.Equal(t, "https://github.com/teacher", instructor.SocialLinks["github"])
+
+	courseID, _ := insertCourseWithTrainings(t, db, 1)
+	if err := db.SetCourseInstructors(courseID, []int{user.ID}); err != nil {
+		t.Fatalf("Failed to set course instructors: %v", err)
+	}
+
+	courses, err := db.GetCourses(model.CourseFilter{InstructorID: user.ID}, listing.Params{Limit: 10, Sort: "name"})
+	if err != nil {
+		t.Fatalf("Failed to fetch courses: %v", err)
+	}
+	if assert.Len(t, courses, 1) {
+		assert.Equal(t, []int{user.ID}, courses[0].InstructorIDs)
+	}
+}
+
+func TestCourseReviews(t *testing.T) {
+	db := setupDatabase(t)
+
+	author, err := db.CreateUser("Author@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	reader, err := db.CreateUser("Reader@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	courseID, _ := insertCourseWithTrainings(t, db, 1)
+
+	review, err := db.CreateReview(model.Review{CourseID: courseID, UserID: author.ID, Rating: 4, Body: "Good"})
+	if err != nil {
+		t.Fatalf("Failed to create review: %v", err)
+	}
+	_, err = db.CreateReview(model.Review{CourseID: courseID, UserID: author.ID, Rating: 5})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	for i := 0; i < 2; i++ {
+		review, err = db.SetReviewHelpful(review.ID, reader.ID, true)
+		if err != nil {
+			t.Fatalf("Failed to vote: %v", err)
+		}
+	}
+	assert.Equal(t, 1, review.HelpfulCount, "Repeated votes must count once")
+
+	course, err := db.GetCourseByID(courseID)
+	if err != nil {
+		t.Fatalf("Failed to fetch course: %v", err)
+	}
+	assert.Equal(t, 4.0, course.Rating)
+	assert.Equal(t, 1, course.RatingCount)
+
+	reviews, err := db.GetCourseReviews(courseID, listing.Params{Limit: 10, Sort: "helpful", Desc: true})
+	if err != nil {
+		t.Fatalf("Failed to fetch reviews: %v", err)
+	}
+	assert.Len(t, reviews, 1)
+
+	if _, err := db.SetReviewHidden(review.ID, true); err != nil {
+		t.Fatalf("Failed to hide review: %v", err)
+	}
+	course, err = db.GetCourseByID(courseID)
+	if err != nil {
+		t.Fatalf("Failed to fetch course: %v", err)
+	}
+	assert.Equal(t, 0, course.RatingCount, "Hidden reviews must not count")
+}
+
+func TestDiscussionThread(t *testing.T) {
+	db := setupDatabase(t)
+
+	student, err := db.CreateUser("Student@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	_, trainingIDs := insertCourseWithTrainings(t, db, 1)
+
+	question, err := db.CreateQuestion(model.Question{TrainingID: trainingIDs[0], UserID: student.ID, Title: "Why?", Body: "Explain"})
+	if err != nil {
+		t.Fatalf("Failed to create question: %v", err)
+	}
+
+	answer, err := db.CreateAnswer(model.Answer{QuestionID: question.ID, UserID: student.ID, Body: "Because"})
+	if err != nil {
+		t.Fatalf("Failed to create answer: %v", err)
+	}
+	if _, err := db.CreateAnswer(model.Answer{QuestionID: question.ID, ParentID: &answer.ID, UserID: student.ID, Body: "Thanks"}); err != nil {
+		t.Fatalf("Failed to reply to answer: %v", err)
+	}
+
+	answer, err = db.SetAnswerVote(answer.ID, student.ID, true)
+	if err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	assert.Equal(t, 1, answer.Upvotes)
+
+	question, err = db.AcceptAnswer(question.ID, &answer.ID)
+	if err != nil {
+		t.Fatalf("Failed to accept answer: %v", err)
+	}
+	assert.Equal(t, &answer.ID, question.AcceptedAnswerID)
+	assert.Equal(t, 2, question.AnswerCount)
+
+	if _, err := db.SetQuestionHidden(question.ID, true); err != nil {
+		t.Fatalf("Failed to hide question: %v", err)
+	}
+	questions, err := db.GetTrainingQuestions(trainingIDs[0], listing.Params{Limit: 10, Sort: "created_at"}, false)
+	if err != nil {
+		t.Fatalf("Failed to fetch questions: %v", err)
+	}
+	assert.Empty(t, questions)
+
+	if err := db.DeleteAnswer(answer.ID); err != nil {
+		t.Fatalf("Failed to delete answer: %v", err)
+	}
+	answers, err := db.GetAnswers(question.ID, true)
+	if err != nil {
+		t.Fatalf("Failed to fetch answers: %v", err)
+	}
+	assert.Empty(t, answers, "Replies must go with the answer they reply to")
+}
+
+func TestNotes(t *testing.T) {
+	db := setupDatabase(t)
+
+	student, err := db.CreateUser("Student@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	courseID, trainingIDs := insertCourseWithTrainings(t, db, 2)
+
+	if _, err := db.CreateNote(model.Note{UserID: student.ID, TrainingID: trainingIDs[1], PositionSeconds: 10, Body: "Second 100% done"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	note, err := db.CreateNote(model.Note{UserID: student.ID, TrainingID: trainingIDs[0], PositionSeconds: 90, Body: "First"})
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	assert.NotEmpty(t, note.TrainingName)
+
+	note, err = db.UpdateNote(note.ID, 30, "First, edited")
+	if err != nil {
+		t.Fatalf("Failed to update note: %v", err)
+	}
+	assert.Equal(t, 30, note.PositionSeconds)
+
+	notes, err := db.GetCourseNotes(student.ID, courseID)
+	if err != nil {
+		t.Fatalf("Failed to fetch notes: %v", err)
+	}
+	if assert.Len(t, notes, 2) {
+		assert.Equal(t, trainingIDs[0], notes[0].TrainingID, "Notes must follow the training sequence")
+	}
+
+	found, err := db.SearchNotes(student.ID, "100%", 10)
+	if err != nil {
+		t.Fatalf("Failed to search notes: %v", err)
+	}
+	assert.Len(t, found, 1)
+
+	if err := db.AddBookmark(student.ID, trainingIDs[0]); err != nil {
+		t.Fatalf("Failed to bookmark: %v", err)
+	}
+	if err := db.AddBookmark(student.ID, trainingIDs[0]); err != nil {
+		t.Fatalf("Failed to bookmark twice: %v", err)
+	}
+	bookmarks, err := db.GetBookmarks(student.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch bookmarks: %v", err)
+	}
+	assert.Len(t, bookmarks, 1)
+
+	if err := db.RemoveBookmark(student.ID, trainingIDs[0]); err != nil {
+		t.Fatalf("Failed to remove bookmark: %v", err)
+	}
+	assert.ErrorIs(t, db.RemoveBookmark(student.ID, trainingIDs[0]), ErrNotFound)
+	assert.ErrorIs(t, db.DeleteNote(note.ID+1000), ErrNotFound)
+}
+
+func TestCohorts(t *testing.T) {
+	db := setupDatabase(t)
+
+	first, err := db.CreateUser("First@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	second, err := db.CreateUser("Second@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	courseID, _ := insertCourseWithTrainings(t, db, 1)
+
+	now := time.Now()
+	cohort, err := db.CreateCohort(model.Cohort{CourseID: courseID, Name: "Turma 1", StartsAt: now, EndsAt: now.Add(30 * 24 * time.Hour), Capacity: 1, Currency: "brl"})
+	if err != nil {
+		t.Fatalf("Failed to create cohort: %v", err)
+	}
+
+	if _, err := db.Enroll(cohort.ID, first.ID, model.EnrollmentActive); err != nil {
+		t.Fatalf("Failed to enroll: %v", err)
+	}
+	_, err = db.Enroll(cohort.ID, second.ID, model.EnrollmentActive)
+	assert.ErrorIs(t, err, ErrConflict, "The cohort is full")
+
+	if err := db.SetEnrollmentStatus(cohort.ID, first.ID, model.EnrollmentCancelled); err != nil {
+		t.Fatalf("Failed to cancel enrollment: %v", err)
+	}
+	if _, err := db.Enroll(cohort.ID, second.ID, model.EnrollmentActive); err != nil {
+		t.Fatalf("Failed to enroll in the released seat: %v", err)
+	}
+
+	session, err := db.CreateLiveSession(model.LiveSession{CohortID: cohort.ID, Title: "Kickoff", StartsAt: now.Add(30 * time.Minute), DurationMinutes: 60, MeetingURL: "https://meet.example.com/kickoff"})
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	sessions, err := db.GetUserSessions(second.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch sessions: %v", err)
+	}
+	assert.Len(t, sessions, 1)
+
+	reminders, err := db.GetSessionReminders(now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to fetch reminders: %v", err)
+	}
+	if assert.Len(t, reminders, 1) {
+		assert.Equal(t, second.ID, reminders[0].UserID)
+	}
+
+	if err := db.MarkSessionReminded(session.ID); err != nil {
+		t.Fatalf("Failed to mark reminder: %v", err)
+	}
+	session.StartsAt = now.Add(45 * time.Minute)
+	if _, err := db.UpdateLiveSession(*session); err != nil {
+		t.Fatalf("Failed to reschedule session: %v", err)
+	}
+	reminders, err = db.GetSessionReminders(now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to fetch reminders: %v", err)
+	}
+	assert.Len(t, reminders, 1, "Rescheduled sessions must be reminded again")
+
+	token, err := db.EnsureCalendarToken(second.ID, "abc123")
+	if err != nil {
+		t.Fatalf("Failed to store calendar token: %v", err)
+	}
+	token, err = db.EnsureCalendarToken(second.ID, "def456")
+	if err != nil {
+		t.Fatalf("Failed to store calendar token: %v", err)
+	}
+	assert.Equal(t, "abc123", token)
+
+	assert.ErrorIs(t, db.DeleteCohort(cohort.ID), ErrConflict)
+}
+
+func TestCohortPayments(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("Student@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	courseID, _ := insertCourseWithTrainings(t, db, 1)
+
+	now := time.Now()
+	cohort, err := db.CreateCohort(model.Cohort{CourseID: courseID, Name: "Turma 1", StartsAt: now, EndsAt: now.Add(30 * 24 * time.Hour), Capacity: 5, Price: 5000, Currency: "brl"})
+	if err != nil {
+		t.Fatalf("Failed to create cohort: %v", err)
+	}
+
+	if _, err := db.Enroll(cohort.ID, user.ID, model.EnrollmentPending); err != nil {
+		t.Fatalf("Failed to enroll: %v", err)
+	}
+
+	defer db.(*client).db.Exec("DELETE FROM payments WHERE stripe_payment_intent_id = 'pi_test'")
+	pi := &stripe.PaymentIntent{ID: "pi_test", Amount: 5000, Currency: "brl", Status: stripe.PaymentIntentStatusRequiresConfirmation}
+	payment, err := db.AddEnrollmentPayment(cohort.ID, user.ID, pi)
+	if err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	again, err := db.AddEnrollmentPayment(cohort.ID, user.ID, pi)
+	if err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	assert.Equal(t, payment.ID, again.ID, "Recording a payment twice must not duplicate it")
+
+	enrollment, err := db.GetEnrollment(cohort.ID, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch enrollment: %v", err)
+	}
+	if assert.NotNil(t, enrollment.PaymentID) {
+		assert.Equal(t, payment.ID, *enrollment.PaymentID)
+	}
+
+	changed := *cohort
+	changed.Price = 1000
+	_, err = db.UpdateCohort(changed)
+	assert.ErrorIs(t, err, ErrConflict, "The price is fixed once someone enrolled")
+
+	expired, err := db.GetExpiredEnrollments(now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to fetch expired enrollments: %v", err)
+	}
+	if assert.Len(t, expired, 1) {
+		assert.Equal(t, "pi_test", expired[0].PaymentIntentID)
+	}
+
+	if err := db.CancelPendingEnrollment(cohort.ID, user.ID); err != nil {
+		t.Fatalf("Failed to cancel enrollment: %v", err)
+	}
+	expired, err = db.GetExpiredEnrollments(now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to fetch expired enrollments: %v", err)
+	}
+	assert.Empty(t, expired)
+
+	if _, err := db.UpdateCohort(changed); err != nil {
+		t.Fatalf("Failed to change the price of an empty cohort: %v", err)
+	}
+}
+
+func TestGameJam(t *testing.T) {
+	db := setupDatabase(t)
+
+	var users []model.User
+	for _, email := range []string{"a@test.com", "b@test.com", "c@test.com"} {
+		user, err := db.CreateUser(email, "TestPassword", "")
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	now := time.Now()
+	jam, err := db.CreateJam(model.GameJam{
+		Title:              "Jam",
+		Theme:              "Loops",
+		ThemeRevealAt:      now.Add(-3 * time.Hour),
+		SubmissionDeadline: now.Add(-2 * time.Hour),
+		VotingEndsAt:       now.Add(-time.Hour),
+		MaxTeamSize:        1,
+		Criteria:           []model.JamCriterion{{Name: "Fun"}, {Name: "Art"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create jam: %v", err)
+	}
+	defer db.(*client).db.Exec("DELETE FROM game_jams WHERE id = $1", jam.ID)
+	assert.Len(t, jam.Criteria, 2)
+
+	first, err := db.CreateJamTeam(model.JamTeam{JamID: jam.ID, Name: "First", InviteCode: "AAAA"}, users[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to create team: %v", err)
+	}
+	_, err = db.JoinJamTeam(jam.ID, "AAAA", users[1].ID)
+	assert.ErrorIs(t, err, ErrConflict, "The team is full")
+
+	second, err := db.CreateJamTeam(model.JamTeam{JamID: jam.ID, Name: "Second", InviteCode: "BBBB"}, users[1].ID)
+	if err != nil {
+		t.Fatalf("Failed to create team: %v", err)
+	}
+
+	var entries []*model.JamEntry
+	for _, team := range []*model.JamTeam{first, second} {
+		entry, err := db.SaveJamEntry(model.JamEntry{JamID: jam.ID, TeamID: team.ID, Title: team.Name, BuildURL: "https://example.com/build"})
+		if err != nil {
+			t.Fatalf("Failed to save entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	uploaded := model.JamEntry{JamID: jam.ID, TeamID: first.ID, Title: first.Name, BuildKey: "build.zip", ScreenshotKeys: []string{"shot.png"}}
+	if _, err := db.SaveJamEntry(uploaded); err != nil {
+		t.Fatalf("Failed to save entry: %v", err)
+	}
+	resaved, err := db.SaveJamEntry(model.JamEntry{JamID: jam.ID, TeamID: first.ID, Title: "Renamed"})
+	if err != nil {
+		t.Fatalf("Failed to save entry: %v", err)
+	}
+	assert.Equal(t, "build.zip", resaved.BuildKey, "Entries saved without a build keep theirs")
+	assert.Equal(t, []string{"shot.png"}, resaved.ScreenshotKeys)
+
+	fun, art := jam.Criteria[0].ID, jam.Criteria[1].ID
+	if err := db.SaveJamVotes(entries[0].ID, users[2].ID, map[int]int{fun: 2, art: 3}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+	if err := db.SaveJamVotes(entries[1].ID, users[2].ID, map[int]int{fun: 5, art: 4}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	published, err := db.PublishJamResults(now)
+	if err != nil {
+		t.Fatalf("Failed to publish results: %v", err)
+	}
+	assert.Equal(t, []int{jam.ID}, published)
+
+	results, err := db.GetJamEntries(jam.ID)
+	if err != nil {
+		t.Fatalf("Failed to fetch entries: %v", err)
+	}
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, entries[1].ID, results[0].ID)
+		assert.Equal(t, 1, *results[0].Rank)
+		assert.Equal(t, 4.5, *results[0].Score)
+		assert.Len(t, results[0].Scores, 2)
+	}
+
+	if err := db.LeaveJamTeam(first.ID, users[0].ID); err != nil {
+		t.Fatalf("Failed to leave team: %v", err)
+	}
+	_, err = db.GetJamTeam(first.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "Empty teams must be removed")
+}
+
+func TestPortfolio(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("portfolio@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	other, err := db.CreateUser("other@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	_, err = db.GetPortfolio(user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	saved, err := db.SavePortfolio(model.Portfolio{UserID: user.ID, Slug: "ana", DisplayName: "Ana", Public: true})
+	if err != nil {
+		t.Fatalf("Failed to save portfolio: %v", err)
+	}
+	assert.Equal(t, "ana", saved.Slug)
+
+	_, err = db.SavePortfolio(model.Portfolio{UserID: other.ID, Slug: "ana", DisplayName: "Other"})
+	assert.ErrorIs(t, err, ErrConflict, "Slugs are unique")
+
+	_, trainingIDs := insertCourseWithTrainings(t, db, 2)
+	approved, err := db.CreateSubmission(user.ID, trainingIDs[0], "https://example.com/game", "")
+	if err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	if _, err := db.ReviewSubmission(approved.ID, other.ID, model.SubmissionApproved, "Nice"); err != nil {
+		t.Fatalf("Failed to review submission: %v", err)
+	}
+	if _, err := db.CreateSubmission(user.ID, trainingIDs[1], "https://example.com/wip", ""); err != nil {
+		t.Fatalf("Failed to create submission: %v", err)
+	}
+	if err := db.AwardBadge(user.ID, "streak_7"); err != nil {
+		t.Fatalf("Failed to award badge: %v", err)
+	}
+
+	items, err := db.GetPortfolioItems(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get portfolio items: %v", err)
+	}
+	assert.Len(t, items, 2, "Pending submissions are left out")
+	for _, item := range items {
+		assert.True(t, item.Visible)
+	}
+
+	if err := db.SetPortfolioItemVisibility(user.ID, model.PortfolioBadge, "streak_7", false); err != nil {
+		t.Fatalf("Failed to hide item: %v", err)
+	}
+
+	bySlug, err := db.GetPortfolioBySlug("ana")
+	if err != nil {
+		t.Fatalf("Failed to get portfolio: %v", err)
+	}
+	items, err = db.GetPortfolioItems(bySlug.UserID)
+	if err != nil {
+		t.Fatalf("Failed to get portfolio items: %v", err)
+	}
+	for _, item := range items {
+		assert.Equal(t, item.Kind == model.PortfolioSubmission, item.Visible)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("profile@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	taken, err := db.CreateUser("taken@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	user.DisplayName = "Ana"
+	user.Bio = "Making games"
+	user.Country = "BR"
+	updated, err := db.UpdateUserProfile(user)
+	if err != nil {
+		t.Fatalf("Failed to update profile: %v", err)
+	}
+	assert.Equal(t, "Ana", updated.DisplayName)
+	assert.Equal(t, "pt-BR", updated.Locale)
+
+	if err := db.SetUserPassword(user.ID, "NewPassword"); err != nil {
+		t.Fatalf("Failed to set password: %v", err)
+	}
+	fetched, err := db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(fetched.Password), []byte("NewPassword")))
+
+	change := model.EmailChange{UserID: user.ID, NewEmail: taken.Email, ExpiresAt: time.Now().Add(time.Hour)}
+	assert.ErrorIs(t, db.CreateEmailChange(change, "taken"), ErrConflict)
+	change.NewEmail = strings.ToUpper(taken.Email)
+	assert.ErrorIs(t, db.CreateEmailChange(change, "taken"), ErrConflict, "Emails differing in case are the same address")
+
+	change.NewEmail = "new@test.com"
+	if err := db.CreateEmailChange(change, "expired"); err != nil {
+		t.Fatalf("Failed to create email change: %v", err)
+	}
+	change.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := db.CreateEmailChange(change, "expired"); err != nil {
+		t.Fatalf("Failed to replace email change: %v", err)
+	}
+	_, _, err = db.ConfirmEmailChange("expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	change.ExpiresAt = time.Now().Add(time.Hour)
+	if err := db.CreateEmailChange(change, "valid"); err != nil {
+		t.Fatalf("Failed to create email change: %v", err)
+	}
+	confirmed, oldEmail, err := db.ConfirmEmailChange("valid")
+	if err != nil {
+		t.Fatalf("Failed to confirm email change: %v", err)
+	}
+	assert.Equal(t, "new@test.com", confirmed.Email)
+	assert.Equal(t, "profile@test.com", oldEmail)
+
+	_, _, err = db.ConfirmEmailChange("valid")
+	assert.ErrorIs(t, err, ErrNotFound, "Tokens work once")
+}
+
+func TestAccountPrivacy(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("private@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	_, trainingIDs := insertCourseWithTrainings(t, db, 1)
+	if _, err := db.StartTraining(user.ID, trainingIDs[0]); err != nil {
+		t.Fatalf("Failed to start training: %v", err)
+	}
+	if _, err := db.CreateNote(model.Note{UserID: user.ID, TrainingID: trainingIDs[0], Body: "Remember this"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	data, err := db.GetPersonalData(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get personal data: %v", err)
+	}
+	assert.Equal(t, user.Email, data.Profile.Email)
+	assert.Len(t, data.Progress, 1)
+	assert.Len(t, data.Notes, 1)
+	assert.Empty(t, data.Payments)
+
+	export, err := db.CreateDataExport(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to create data export: %v", err)
+	}
+	_, err = db.CreateDataExport(user.ID)
+	assert.ErrorIs(t, err, ErrConflict, "Only one export waits at a time")
+
+	if err := db.CompleteDataExport(export.ID, "exports/1/data.zip", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Failed to complete data export: %v", err)
+	}
+	expired, err := db.GetExpiredDataExports(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get expired exports: %v", err)
+	}
+	assert.Len(t, expired, 1)
+
+	deletionID, err := db.LogAccountDeletion(model.AccountDeletion{UserID: user.ID, IPAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Failed to log account deletion: %v", err)
+	}
+	if err := db.AnonymizeUser(user.ID, deletionID); err != nil {
+		t.Fatalf("Failed to anonymize user: %v", err)
+	}
+	assert.ErrorIs(t, db.AnonymizeUser(user.ID, deletionID), ErrNotFound, "Accounts are deleted once")
+
+	anonymized, err := db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	assert.NotEqual(t, user.Email, anonymized.Email)
+	assert.Empty(t, anonymized.Password)
+	assert.True(t, anonymized.Deleted)
+
+	users, err := db.GetUsers()
+	if err != nil {
+		t.Fatalf("Failed to get users: %v", err)
+	}
+	for _, other := range users {
+		assert.NotEqual(t, user.ID, other.ID, "Deleted users are not mailed")
+	}
+
+	exports, err := db.GetDataExports(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get data exports: %v", err)
+	}
+	assert.Empty(t, exports)
+
+	var completed bool
+	err = db.(*client).db.QueryRow(`SELECT completed_at IS NOT NULL FROM account_deletions WHERE id = $1`, deletionID).Scan(&completed)
+	if err != nil {
+		t.Fatalf("Failed to read account deletion: %v", err)
+	}
+	assert.True(t, completed)
+}
+
+func TestTwoFactor(t *testing.T) {
+	db := setupDatabase(t)
+	defer db.(*client).db.Exec("DELETE FROM two_factor_roles")
+
+	user, err := db.CreateUser("2fa@test.com", "TestPassword", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	_, err = db.GetTwoFactor(user.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "Users start without 2FA")
+
+	if err := db.StartTwoFactorEnrollment(user.ID, "JBSWY3DPEHPK3PXP", []string{"code-a", "code-b"}); err != nil {
+		t.Fatalf("Failed to start enrollment: %v", err)
+	}
+	twoFactor, err := db.GetTwoFactor(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get two-factor state: %v", err)
+	}
+	assert.Nil(t, twoFactor.EnabledAt)
+
+	if err := db.EnableTwoFactor(user.ID, 100); err != nil {
+		t.Fatalf("Failed to enable 2FA: %v", err)
+	}
+	assert.ErrorIs(t, db.StartTwoFactorEnrollment(user.ID, "OTHER", nil), ErrConflict)
+
+	fetched, err := db.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	assert.True(t, fetched.TwoFactorEnabled)
+
+	used, err := db.UseTOTPStep(user.ID, 100)
+	if err != nil {
+		t.Fatalf("Failed to use step: %v", err)
+	}
+	assert.False(t, used, "The step that enabled 2FA is spent")
+	used, _ = db.UseTOTPStep(user.ID, 101)
+	assert.True(t, used)
+
+	used, _ = db.UseRecoveryCode(user.ID, "code-a")
+	assert.True(t, used)
+	used, _ = db.UseRecoveryCode(user.ID, "code-a")
+	assert.False(t, used, "Recovery codes work once")
+
+	if err := db.CreateTwoFactorChallenge(user.ID, "challenge", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		userID, err := db.AttemptTwoFactorChallenge("challenge", 2)
+		if err != nil {
+			t.Fatalf("Failed to attempt challenge: %v", err)
+		}
+		assert.Equal(t, user.ID, userID)
+	}
+	_, err = db.AttemptTwoFactorChallenge("challenge", 2)
+	assert.ErrorIs(t, err, ErrNotFound, "Challenges run out of attempts")
+
+	if err := db.SetTwoFactorRoles([]string{model.RoleAdmin, model.RoleAdmin}); err != nil {
+		t.Fatalf("Failed to set two-factor roles: %v", err)
+	}
+	roles, err := db.GetTwoFactorRoles()
+	if err != nil {
+		t.Fatalf("Failed to get two-factor roles: %v", err)
+	}
+	assert.Equal(t, []string{model.RoleAdmin}, roles)
+
+	if err := db.DisableTwoFactor(user.ID); err != nil {
+		t.Fatalf("Failed to disable 2FA: %v", err)
+	}
+	_, err = db.GetTwoFactor(user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSigninThrottles(t *testing.T) {
+	db := setupDatabase(t)
+	defer db.(*client).db.Exec("DELETE FROM signin_throttles")
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		before, err := db.RecordSigninAttempt("account:a@test.com", now, time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to record attempt: %v", err)
+		}
+		assert.Equal(t, i, before.Failures, "Each attempt sees the ones before it")
+	}
+
+	before, err := db.RecordSigninAttempt("account:a@test.com", now.Add(2*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to record attempt: %v", err)
+	}
+	assert.Equal(t, 0, before.Failures, "Old failures stop counting")
+
+	until := now.Add(15 * time.Minute)
+	if err := db.LockSignin("account:a@test.com", until); err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+	before, err = db.RecordSigninAttempt("account:a@test.com", now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to record attempt: %v", err)
+	}
+	assert.NotNil(t, before.LockedUntil)
+	var failures int
+	if err := db.(*client).db.QueryRow(`SELECT failures FROM signin_throttles WHERE key = 'account:a@test.com'`).Scan(&failures); err != nil {
+		t.Fatalf("Failed to read throttle: %v", err)
+	}
+	assert.Equal(t, 0, failures, "Attempts while locked are not counted")
+
+	if _, err := db.RecordSigninAttempt("ip:127.0.0.1", now, time.Hour); err != nil {
+		t.Fatalf("Failed to record attempt: %v", err)
+	}
+	if err := db.UncountSigninAttempt("ip:127.0.0.1"); err != nil {
+		t.Fatalf("Failed to uncount attempt: %v", err)
+	}
+	before, err = db.RecordSigninAttempt("ip:127.0.0.1", now, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to record attempt: %v", err)
+	}
+	assert.Equal(t, 0, before.Failures, "Uncounted attempts no longer count")
+
+	throttles := func() int {
+		var count int
+		if err := db.(*client).db.QueryRow(`SELECT count(*) FROM signin_throttles`).Scan(&count); err != nil {
+			t.Fatalf("Failed to count throttles: %v", err)
+		}
+		return count
+	}
+
+	if err := db.DeleteStaleSigninThrottles(now.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to delete stale throttles: %v", err)
+	}
+	assert.Equal(t, 1, throttles(), "Running locks are kept")
+
+	if err := db.ClearSigninFailures("account:a@test.com"); err != nil {
+		t.Fatalf("Failed to clear failures: %v", err)
+	}
+	assert.Equal(t, 0, throttles())
+}
+
+func TestUserIdentities(t *testing.T) {
+	db := setupDatabase(t)
+	defer db.(*client).db.Exec("DELETE FROM oauth_states")
+
+	user, err := db.CreateUser("oauth@test.com", "password", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	state := model.OAuthState{StateHash: "hash", Provider: "google", CodeVerifier: "verifier", ExpiresAt: time.Now().Add(time.Minute)}
+	if err := db.CreateOAuthState(state); err != nil {
+		t.Fatalf("Failed to create state: %v", err)
+	}
+	_, err = db.ConsumeOAuthState("hash", "github")
+	assert.ErrorIs(t, err, ErrNotFound, "States only work with the provider they were made for")
+
+	consumed, err := db.ConsumeOAuthState("hash", "google")
+	if err != nil {
+		t.Fatalf("Failed to consume state: %v", err)
+	}
+	assert.Equal(t, "verifier", consumed.CodeVerifier)
+	_, err = db.ConsumeOAuthState("hash", "google")
+	assert.ErrorIs(t, err, ErrNotFound, "States are used once")
+
+	state.StateHash, state.ExpiresAt = "expired", time.Now().Add(-time.Minute)
+	if err := db.CreateOAuthState(state); err != nil {
+		t.Fatalf("Failed to create state: %v", err)
+	}
+	_, err = db.ConsumeOAuthState("expired", "google")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = db.GetUserByIdentity("google", "sub-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	identity := model.UserIdentity{Provider: "google", Subject: "sub-1", UserID: user.ID, Email: user.Email}
+	if err := db.LinkUserIdentity(identity); err != nil {
+		t.Fatalf("Failed to link identity: %v", err)
+	}
+	linked, err := db.GetUserByIdentity("google", "sub-1")
+	if err != nil {
+		t.Fatalf("Failed to get user by identity: %v", err)
+	}
+	assert.Equal(t, user.ID, linked.ID)
+
+	identity.Subject = "sub-2"
+	assert.ErrorIs(t, db.LinkUserIdentity(identity), ErrConflict, "One account per provider")
+
+	identities, err := db.GetUserIdentities(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get identities: %v", err)
+	}
+	assert.Len(t, identities, 1)
+}
+
+func TestOAuthSignins(t *testing.T) {
+	db := setupDatabase(t)
+
+	user, err := db.CreateUser("Link@Test.com", "password", "")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	found, err := db.GetUserByEmail("link@test.com")
+	if err != nil {
+		t.Fatalf("Failed to get user by email: %v", err)
+	}
+	assert.Equal(t, user.ID, found.ID, "Emails match whatever their case")
+
+	_, err = db.CreateUser("LINK@test.com", "password", "")
+	assert.ErrorIs(t, err, ErrConflict, "Emails differing in case are the same address")
+
+	link := &model.UserIdentity{Provider: "github", Subject: "42", UserID: user.ID, Email: "link@test.com"}
+	signins := []model.OAuthSignin{
+		{CodeHash: "plain", UserID: user.ID, ExpiresAt: time.Now().Add(time.Minute)},
+		{CodeHash: "link", UserID: user.ID, Link: link, ExpiresAt: time.Now().Add(time.Minute)},
+		{CodeHash: "expired", UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)},
+	}
+	for _, signin := range signins {
+		if err := db.CreateOAuthSignin(signin); err != nil {
+			t.Fatalf("Failed to create oauth signin: %v", err)
+		}
+	}
+
+	plain, err := db.GetOAuthSignin("plain")
+	if err != nil {
+		t.Fatalf("Failed to get oauth signin: %v", err)
+	}
+	assert.Nil(t, plain.Link)
+
+	linking, err := db.GetOAuthSignin("link")
+	if err != nil {
+		t.Fatalf("Failed to get oauth signin: %v", err)
+	}
+	assert.Equal(t, link, linking.Link)
+
+	_, err = db.GetOAuthSignin("expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	if err := db.DeleteOAuthSignin("plain"); err != nil {
+		t.Fatalf("Failed to delete oauth signin: %v", err)
+	}
+	assert.ErrorIs(t, db.DeleteOAuthSignin("plain"), ErrNotFound, "Codes are used once")
+
+	if err := db.DeleteExpiredOAuthStates(time.Now()); err != nil {
+		t.Fatalf("Failed to delete expired states: %v", err)
+	}
+	assert.ErrorIs(t, db.DeleteOAuthSignin("expired"), ErrNotFound)
+}
@@ -0,0 +1,317 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/listing"
+	"game-student-go/internal/model"
+	"strings"
+)
+
+const questionColumns = `questions.id, questions.training_id, questions.user_id, questions.title, questions.body, questions.upvotes,
+	(SELECT COUNT(*) FROM answers a WHERE a.question_id = questions.id AND a.hidden_at IS NULL),
+	questions.accepted_answer_id, questions.locked_at IS NOT NULL, questions.hidden_at IS NOT NULL,
+	questions.created_at, questions.updated_at`
+
+func scanQuestion(row rowScanner) (*model.Question, error) {
+	var question model.Question
+	var acceptedAnswerID sql.NullInt64
+	err := row.Scan(&question.ID, &question.TrainingID, &question.UserID, &question.Title, &question.Body, &question.Upvotes,
+		&question.AnswerCount, &acceptedAnswerID, &question.Locked, &question.Hidden, &question.CreatedAt, &question.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if acceptedAnswerID.Valid {
+		id := int(acceptedAnswerID.Int64)
+		question.AcceptedAnswerID = &id
+	}
+
+	return &question, nil
+}
+
+const answerColumns = `id, question_id, parent_id, user_id, body, upvotes, hidden_at IS NOT NULL, created_at, updated_at`
+
+func scanAnswer(row rowScanner) (*model.Answer, error) {
+	var answer model.Answer
+	var parentID sql.NullInt64
+	err := row.Scan(&answer.ID, &answer.QuestionID, &parentID, &answer.UserID, &answer.Body, &answer.Upvotes, &answer.Hidden,
+		&answer.CreatedAt, &answer.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		answer.ParentID = &id
+	}
+
+	return &answer, nil
+}
+
+func (c *client) CreateQuestion(question model.Question) (*model.Question, error) {
+	var id int
+	err := c.db.QueryRow(`INSERT INTO questions (training_id, user_id, title, body) VALUES ($1, $2, $3, $4) RETURNING id`,
+		question.TrainingID, question.UserID, question.Title, question.Body).Scan(&id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no training found with id %d: %w", question.TrainingID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("creating question: %w", err)
+	}
+
+	return c.GetQuestion(id)
+}
+
+func (c *client) GetQuestion(id int) (*model.Question, error) {
+	question, err := scanQuestion(c.db.QueryRow(`SELECT `+questionColumns+` FROM questions WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no question found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying question: %w", err)
+	}
+
+	return question, nil
+}
+
+// questionSorts maps the sort fields accepted by GetTrainingQuestions to
+// their columns.
+var questionSorts = map[string]string{
+	"created_at": "questions.created_at",
+	"upvotes":    "questions.upvotes",
+}
+
+// QuestionSorts lists the fields questions can be sorted by, the default
+// first.
+var QuestionSorts = []string{"created_at", "upvotes"}
+
+// GetTrainingQuestions returns one page of the questions about a training,
+// fetching one row past the limit like GetCourses. Hidden questions are
+// left out unless includeHidden is set.
+func (c *client) GetTrainingQuestions(trainingID int, page listing.Params, includeHidden bool) ([]model.Question, error) {
+	column, ok := questionSorts[page.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown question sort: %s", page.Sort)
+	}
+
+	conditions := []string{"questions.training_id = $1"}
+	if !includeHidden {
+		conditions = append(conditions, "questions.hidden_at IS NULL")
+	}
+
+	keyset, orderBy, args := page.Keyset(column, "questions.id", []interface{}{trainingID})
+	if keyset != "" {
+		conditions = append(conditions, keyset)
+	}
+	args = append(args, page.Limit+1)
+
+	query := fmt.Sprintf(`SELECT %s FROM questions WHERE %s ORDER BY %s LIMIT $%d`,
+		questionColumns, strings.Join(conditions, " AND "), orderBy, len(args))
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying questions: %w", err)
+	}
+	defer rows.Close()
+
+	var questions []model.Question
+	for rows.Next() {
+		question, err := scanQuestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning question: %w", err)
+		}
+		questions = append(questions, *question)
+	}
+
+	return questions, rows.Err()
+}
+
+func (c *client) UpdateQuestion(id int, title, body string) (*model.Question, error) {
+	return c.updateQuestion(id, `title = $2, body = $3, updated_at = CURRENT_TIMESTAMP`, title, body)
+}
+
+func (c *client) SetQuestionHidden(id int, hidden bool) (*model.Question, error) {
+	return c.updateQuestion(id, `hidden_at = CASE WHEN $2 THEN COALESCE(hidden_at, CURRENT_TIMESTAMP) END`, hidden)
+}
+
+// SetQuestionLocked closes a question to new answers and edits, or opens it
+// again.
+func (c *client) SetQuestionLocked(id int, locked bool) (*model.Question, error) {
+	return c.updateQuestion(id, `locked_at = CASE WHEN $2 THEN COALESCE(locked_at, CURRENT_TIMESTAMP) END`, locked)
+}
+
+// AcceptAnswer marks the accepted answer of a question, or clears it when
+// answerID is nil. The caller checks the answer belongs to the question.
+func (c *client) AcceptAnswer(questionID int, answerID *int) (*model.Question, error) {
+	return c.updateQuestion(questionID, `accepted_answer_id = $2`, answerID)
+}
+
+// updateQuestion applies the SET clause to a question, whose placeholders
+// start at $2, and returns the updated question.
+func (c *client) updateQuestion(id int, set string, args ...interface{}) (*model.Question, error) {
+	query := `UPDATE questions SET ` + set + ` WHERE id = $1 RETURNING ` + questionColumns
+	question, err := scanQuestion(c.db.QueryRow(query, append([]interface{}{id}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no question found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("updating question: %w", err)
+	}
+
+	return question, nil
+}
+
+func (c *client) DeleteQuestion(id int) error {
+	result, err := c.db.Exec(`DELETE FROM questions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting question: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("no question found with id %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (c *client) CreateAnswer(answer model.Answer) (*model.Answer, error) {
+	query := `
+		INSERT INTO answers (question_id, parent_id, user_id, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + answerColumns
+
+	created, err := scanAnswer(c.db.QueryRow(query, answer.QuestionID, answer.ParentID, answer.UserID, answer.Body))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no question %d or parent answer: %w", answer.QuestionID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("creating answer: %w", err)
+	}
+
+	return created, nil
+}
+
+func (c *client) GetAnswer(id int) (*model.Answer, error) {
+	answer, err := scanAnswer(c.db.QueryRow(`SELECT `+answerColumns+` FROM answers WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no answer found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying answer: %w", err)
+	}
+
+	return answer, nil
+}
+
+// GetAnswers lists the answers of a question oldest first. Hidden answers
+// are left out unless includeHidden is set.
+func (c *client) GetAnswers(questionID int, includeHidden bool) ([]model.Answer, error) {
+	query := `SELECT ` + answerColumns + ` FROM answers WHERE question_id = $1 AND ($2 OR hidden_at IS NULL) ORDER BY created_at, id`
+	rows, err := c.db.Query(query, questionID, includeHidden)
+	if err != nil {
+		return nil, fmt.Errorf("querying answers: %w", err)
+	}
+	defer rows.Close()
+
+	answers := []model.Answer{}
+	for rows.Next() {
+		answer, err := scanAnswer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning answer: %w", err)
+		}
+		answers = append(answers, *answer)
+	}
+
+	return answers, rows.Err()
+}
+
+func (c *client) UpdateAnswer(id int, body string) (*model.Answer, error) {
+	return c.updateAnswer(id, `body = $2, updated_at = CURRENT_TIMESTAMP`, body)
+}
+
+func (c *client) SetAnswerHidden(id int, hidden bool) (*model.Answer, error) {
+	return c.updateAnswer(id, `hidden_at = CASE WHEN $2 THEN COALESCE(hidden_at, CURRENT_TIMESTAMP) END`, hidden)
+}
+
+func (c *client) updateAnswer(id int, set string, args ...interface{}) (*model.Answer, error) {
+	query := `UPDATE answers SET ` + set + ` WHERE id = $1 RETURNING ` + answerColumns
+	answer, err := scanAnswer(c.db.QueryRow(query, append([]interface{}{id}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no answer found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("updating answer: %w", err)
+	}
+
+	return answer, nil
+}
+
+// DeleteAnswer removes an answer along with the replies to it.
+func (c *client) DeleteAnswer(id int) error {
+	result, err := c.db.Exec(`DELETE FROM answers WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting answer: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("no answer found with id %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetQuestionVote records or withdraws a user's upvote on a question.
+func (c *client) SetQuestionVote(questionID, userID int, up bool) (*model.Question, error) {
+	if err := c.vote("question_votes", "question_id", "questions", questionID, userID, up); err != nil {
+		return nil, err
+	}
+
+	return c.GetQuestion(questionID)
+}
+
+// SetAnswerVote records or withdraws a user's upvote on an answer.
+func (c *client) SetAnswerVote(answerID, userID int, up bool) (*model.Answer, error) {
+	if err := c.vote("answer_votes", "answer_id", "answers", answerID, userID, up); err != nil {
+		return nil, err
+	}
+
+	return c.GetAnswer(answerID)
+}
+
+// vote adds or removes a row of the votes table and keeps the upvotes
+// column of the voted table in step. Repeated votes count once.
+func (c *client) vote(votesTable, idColumn, votedTable string, id, userID int, up bool) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO ` + votesTable + ` (` + idColumn + `, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	delta := 1
+	if !up {
+		query = `DELETE FROM ` + votesTable + ` WHERE ` + idColumn + ` = $1 AND user_id = $2`
+		delta = -1
+	}
+
+	result, err := tx.Exec(query, id, userID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("no %s found with id %d: %w", strings.TrimSuffix(votedTable, "s"), id, ErrNotFound)
+		}
+		return fmt.Errorf("voting: %w", err)
+	}
+
+	if changed, _ := result.RowsAffected(); changed > 0 {
+		if _, err := tx.Exec(`UPDATE `+votedTable+` SET upvotes = upvotes + $2 WHERE id = $1`, id, delta); err != nil {
+			return fmt.Errorf("counting votes: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing vote: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"time"
+)
+
+func (c *client) CreateOAuthState(state model.OAuthState) error {
+	_, err := c.db.Exec(`INSERT INTO oauth_states (state_hash, provider, code_verifier, expires_at) VALUES ($1, $2, $3, $4)`,
+		state.StateHash, state.Provider, state.CodeVerifier, state.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("inserting oauth state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeOAuthState removes the state of a sign in started with provider
+// and returns it, so it can't be used twice. Unknown and expired states are
+// ErrNotFound.
+func (c *client) ConsumeOAuthState(stateHash, provider string) (*model.OAuthState, error) {
+	var state model.OAuthState
+	err := c.db.QueryRow(`
+		DELETE FROM oauth_states
+		WHERE state_hash = $1 AND provider = $2
+		RETURNING state_hash, provider, code_verifier, expires_at`,
+		stateHash, provider).
+		Scan(&state.StateHash, &state.Provider, &state.CodeVerifier, &state.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no %s sign in with this state: %w", provider, ErrNotFound)
+		}
+		return nil, fmt.Errorf("consuming oauth state: %w", err)
+	}
+
+	if time.Now().After(state.ExpiresAt) {
+		return nil, fmt.Errorf("%s sign in expired at %s: %w", provider, state.ExpiresAt.Format(time.RFC3339), ErrNotFound)
+	}
+
+	return &state, nil
+}
+
+// DeleteExpiredOAuthStates forgets the sign ins nobody came back from, and
+// the finished ones nobody collected.
+func (c *client) DeleteExpiredOAuthStates(now time.Time) error {
+	if _, err := c.db.Exec(`DELETE FROM oauth_states WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("deleting expired oauth states: %w", err)
+	}
+	if _, err := c.db.Exec(`DELETE FROM oauth_signins WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("deleting expired oauth signins: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) CreateOAuthSignin(signin model.OAuthSignin) error {
+	var provider, subject, email sql.NullString
+	if signin.Link != nil {
+		provider, subject, email = nullString(signin.Link.Provider), nullString(signin.Link.Subject), nullString(signin.Link.Email)
+	}
+
+	_, err := c.db.Exec(`
+		INSERT INTO oauth_signins (code_hash, user_id, link_provider, link_subject, link_email, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		signin.CodeHash, signin.UserID, provider, subject, email, signin.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("inserting oauth signin: %w", err)
+	}
+
+	return nil
+}
+
+// GetOAuthSignin returns the finished sign in with the code. Unknown and
+// expired codes are ErrNotFound.
+func (c *client) GetOAuthSignin(codeHash string) (*model.OAuthSignin, error) {
+	signin := model.OAuthSignin{CodeHash: codeHash}
+	var provider, subject, email sql.NullString
+	err := c.db.QueryRow(`
+		SELECT user_id, link_provider, link_subject, link_email, expires_at FROM oauth_signins
+		WHERE code_hash = $1`,
+		codeHash).
+		Scan(&signin.UserID, &provider, &subject, &email, &signin.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no oauth sign in with this code: %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying oauth signin: %w", err)
+	}
+
+	if time.Now().After(signin.ExpiresAt) {
+		return nil, fmt.Errorf("oauth sign in expired at %s: %w", signin.ExpiresAt.Format(time.RFC3339), ErrNotFound)
+	}
+
+	if provider.Valid {
+		signin.Link = &model.UserIdentity{Provider: provider.String, Subject: subject.String, UserID: signin.UserID, Email: email.String}
+	}
+
+	return &signin, nil
+}
+
+// DeleteOAuthSignin uses up the code of a finished sign in. It is
+// ErrNotFound when the code was used already.
+func (c *client) DeleteOAuthSignin(codeHash string) error {
+	result, err := c.db.Exec(`DELETE FROM oauth_signins WHERE code_hash = $1`, codeHash)
+	if err != nil {
+		return fmt.Errorf("deleting oauth signin: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no oauth sign in with this code: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetUserByIdentity returns the user linked to an account at provider.
+func (c *client) GetUserByIdentity(provider, subject string) (model.User, error) {
+	query := `
+		SELECT ` + userColumns + ` FROM users
+		WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2) AND deleted_at IS NULL`
+
+	user, err := scanUser(c.db.QueryRow(query, provider, subject))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.User{}, fmt.Errorf("no user linked to %s account %s: %w", provider, subject, ErrNotFound)
+		}
+		return model.User{}, fmt.Errorf("querying user by identity: %w", err)
+	}
+
+	return user, nil
+}
+
+// LinkUserIdentity links a user to an account at a provider. It fails with
+// ErrConflict when the account is linked already, or the user already has
+// another account at that provider.
+func (c *client) LinkUserIdentity(identity model.UserIdentity) error {
+	_, err := c.db.Exec(`INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4)`,
+		identity.Provider, identity.Subject, identity.UserID, identity.Email)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("linking user %d to %s account %s: %w", identity.UserID, identity.Provider, identity.Subject, ErrConflict)
+		}
+		return fmt.Errorf("inserting user identity: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) GetUserIdentities(userID int) ([]model.UserIdentity, error) {
+	rows, err := c.db.Query(`
+		SELECT provider, subject, user_id, email, created_at FROM user_identities
+		WHERE user_id = $1 ORDER BY provider`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying user identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []model.UserIdentity
+	for rows.Next() {
+		var identity model.UserIdentity
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning user identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
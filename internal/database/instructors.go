@@ -0,0 +1,135 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+const instructorColumns = `user_id, display_name, bio, avatar_url, social_links, payout_account`
+
+// defaultInstructorName is shown for instructors who have no display name
+// yet. Instructor profiles are public, so emails are never used.
+const defaultInstructorName = "Instrutor da Escola do Jogo"
+
+func scanInstructor(row rowScanner) (*model.Instructor, error) {
+	var instructor model.Instructor
+	var socialLinks []byte
+	err := row.Scan(&instructor.UserID, &instructor.DisplayName, &instructor.Bio, &instructor.AvatarURL, &socialLinks, &instructor.PayoutAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(socialLinks, &instructor.SocialLinks); err != nil {
+		return nil, fmt.Errorf("decoding social links: %w", err)
+	}
+
+	return &instructor, nil
+}
+
+// CreateInstructor opens an instructor profile for a user, promoting
+// students to the instructor role. Admins keep their role. The profile starts
+// with the user's display name.
+func (c *client) CreateInstructor(userID int) (*model.Instructor, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var displayName string
+	err = tx.QueryRow(`
+		UPDATE users SET role = CASE WHEN role = 'admin' THEN role ELSE 'instructor' END
+		WHERE id = $1
+		RETURNING display_name`, userID).Scan(&displayName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("promoting user %d to instructor: %w", userID, err)
+	}
+
+	query := `
+		INSERT INTO instructors (user_id, display_name) VALUES ($1, COALESCE(NULLIF(TRIM($2), ''), $3))
+		RETURNING ` + instructorColumns
+
+	instructor, err := scanInstructor(tx.QueryRow(query, userID, displayName, defaultInstructorName))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("instructor %d: %w", userID, ErrConflict)
+		}
+		return nil, fmt.Errorf("creating instructor: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing instructor: %w", err)
+	}
+
+	return instructor, nil
+}
+
+func (c *client) GetInstructor(userID int) (*model.Instructor, error) {
+	instructor, err := scanInstructor(c.db.QueryRow(`SELECT `+instructorColumns+` FROM instructors WHERE user_id = $1`, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no instructor found with id %d: %w", userID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying instructor: %w", err)
+	}
+
+	return instructor, nil
+}
+
+func (c *client) UpdateInstructor(instructor model.Instructor) (*model.Instructor, error) {
+	socialLinks, err := json.Marshal(instructor.SocialLinks)
+	if err != nil {
+		return nil, fmt.Errorf("encoding social links: %w", err)
+	}
+
+	query := `
+		UPDATE instructors
+		SET display_name = $2, bio = $3, avatar_url = $4, social_links = $5, payout_account = $6
+		WHERE user_id = $1
+		RETURNING ` + instructorColumns
+
+	updated, err := scanInstructor(c.db.QueryRow(query, instructor.UserID, instructor.DisplayName, instructor.Bio,
+		instructor.AvatarURL, string(socialLinks), instructor.PayoutAccount))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no instructor found with id %d: %w", instructor.UserID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("updating instructor: %w", err)
+	}
+
+	return updated, nil
+}
+
+// SetCourseInstructors replaces the instructors of a course.
+func (c *client) SetCourseInstructors(courseID int, instructorIDs []int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM course_instructors WHERE course_id = $1`, courseID); err != nil {
+		return fmt.Errorf("removing course instructors: %w", err)
+	}
+
+	for _, instructorID := range instructorIDs {
+		_, err := tx.Exec(`INSERT INTO course_instructors (course_id, instructor_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, courseID, instructorID)
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return fmt.Errorf("no course %d or instructor %d: %w", courseID, instructorID, ErrNotFound)
+			}
+			return fmt.Errorf("adding instructor %d: %w", instructorID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing course instructors: %w", err)
+	}
+
+	return nil
+}
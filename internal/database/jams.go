@@ -0,0 +1,571 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/model"
+	"github.com/lib/pq"
+	"time"
+)
+
+const jamColumns = `id, title, description, theme, theme_reveal_at, submission_deadline, voting_ends_at, max_team_size,
+	results_published_at, created_at,
+	COALESCE((SELECT json_agg(json_build_object('id', c.id, 'name', c.name) ORDER BY c.position)
+	          FROM jam_criteria c WHERE c.jam_id = game_jams.id), '[]')`
+
+func scanJam(row rowScanner) (*model.GameJam, error) {
+	var jam model.GameJam
+	var publishedAt sql.NullTime
+	var criteria []byte
+	err := row.Scan(&jam.ID, &jam.Title, &jam.Description, &jam.Theme, &jam.ThemeRevealAt, &jam.SubmissionDeadline,
+		&jam.VotingEndsAt, &jam.MaxTeamSize, &publishedAt, &jam.CreatedAt, &criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	if publishedAt.Valid {
+		jam.ResultsPublishedAt = &publishedAt.Time
+	}
+
+	if err := json.Unmarshal(criteria, &jam.Criteria); err != nil {
+		return nil, fmt.Errorf("decoding jam criteria: %w", err)
+	}
+
+	return &jam, nil
+}
+
+// CreateJam creates a jam together with the criteria entries are voted on.
+func (c *client) CreateJam(jam model.GameJam) (*model.GameJam, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO game_jams (title, description, theme, theme_reveal_at, submission_deadline, voting_ends_at, max_team_size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		jam.Title, jam.Description, jam.Theme, jam.ThemeRevealAt, jam.SubmissionDeadline, jam.VotingEndsAt, jam.MaxTeamSize,
+	).Scan(&jam.ID)
+	if err != nil {
+		return nil, fmt.Errorf("creating jam: %w", err)
+	}
+
+	if err := saveJamCriteria(tx, jam.ID, jam.Criteria); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing jam: %w", err)
+	}
+
+	return c.GetJam(jam.ID)
+}
+
+// UpdateJam changes a jam's schedule and criteria. Criteria are matched by
+// name, so renaming one drops the votes it already got.
+func (c *client) UpdateJam(jam model.GameJam) (*model.GameJam, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE game_jams
+		SET title = $2, description = $3, theme = $4, theme_reveal_at = $5, submission_deadline = $6,
+		    voting_ends_at = $7, max_team_size = $8
+		WHERE id = $1`,
+		jam.ID, jam.Title, jam.Description, jam.Theme, jam.ThemeRevealAt, jam.SubmissionDeadline, jam.VotingEndsAt,
+		jam.MaxTeamSize)
+	if err != nil {
+		return nil, fmt.Errorf("updating jam: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return nil, fmt.Errorf("no jam found with id %d: %w", jam.ID, ErrNotFound)
+	}
+
+	if err := saveJamCriteria(tx, jam.ID, jam.Criteria); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing jam: %w", err)
+	}
+
+	return c.GetJam(jam.ID)
+}
+
+func saveJamCriteria(tx *sql.Tx, jamID int, criteria []model.JamCriterion) error {
+	names := make([]string, len(criteria))
+	for i, criterion := range criteria {
+		names[i] = criterion.Name
+	}
+
+	_, err := tx.Exec(`DELETE FROM jam_criteria WHERE jam_id = $1 AND name <> ALL($2)`, jamID, pq.Array(names))
+	if err != nil {
+		return fmt.Errorf("removing jam criteria: %w", err)
+	}
+
+	for position, name := range names {
+		_, err := tx.Exec(`
+			INSERT INTO jam_criteria (jam_id, name, position) VALUES ($1, $2, $3)
+			ON CONFLICT (jam_id, name) DO UPDATE SET position = EXCLUDED.position`,
+			jamID, name, position)
+		if err != nil {
+			return fmt.Errorf("saving jam criterion: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *client) GetJam(id int) (*model.GameJam, error) {
+	jam, err := scanJam(c.db.QueryRow(`SELECT `+jamColumns+` FROM game_jams WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no jam found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying jam: %w", err)
+	}
+
+	return jam, nil
+}
+
+// GetJams returns every jam, the latest first.
+func (c *client) GetJams() ([]model.GameJam, error) {
+	rows, err := c.db.Query(`SELECT ` + jamColumns + ` FROM game_jams ORDER BY theme_reveal_at DESC, id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("querying jams: %w", err)
+	}
+	defer rows.Close()
+
+	jams := []model.GameJam{}
+	for rows.Next() {
+		jam, err := scanJam(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning jam: %w", err)
+		}
+		jams = append(jams, *jam)
+	}
+
+	return jams, rows.Err()
+}
+
+const jamTeamColumns = `id, jam_id, name, invite_code, created_at,
+	COALESCE((SELECT array_agg(m.user_id ORDER BY m.joined_at, m.user_id)
+	          FROM jam_team_members m WHERE m.team_id = jam_teams.id), '{}')`
+
+func scanJamTeam(row rowScanner) (*model.JamTeam, error) {
+	var team model.JamTeam
+	var members []int64
+	err := row.Scan(&team.ID, &team.JamID, &team.Name, &team.InviteCode, &team.CreatedAt, pq.Array(&members))
+	if err != nil {
+		return nil, err
+	}
+
+	team.Members = make([]int, len(members))
+	for i, member := range members {
+		team.Members[i] = int(member)
+	}
+
+	return &team, nil
+}
+
+// CreateJamTeam creates a team with the user as its first member. Users can
+// only be in one team per jam.
+func (c *client) CreateJamTeam(team model.JamTeam, userID int) (*model.JamTeam, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO jam_teams (jam_id, name, invite_code) VALUES ($1, $2, $3) RETURNING id`,
+		team.JamID, team.Name, team.InviteCode).Scan(&team.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("team %q in jam %d: %w", team.Name, team.JamID, ErrConflict)
+		}
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no jam found with id %d: %w", team.JamID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("creating team: %w", err)
+	}
+
+	if err := addJamTeamMember(tx, team.ID, team.JamID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing team: %w", err)
+	}
+
+	return c.GetJamTeam(team.ID)
+}
+
+func addJamTeamMember(tx *sql.Tx, teamID, jamID, userID int) error {
+	_, err := tx.Exec(`INSERT INTO jam_team_members (team_id, jam_id, user_id) VALUES ($1, $2, $3)`,
+		teamID, jamID, userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user %d is already in a team of jam %d: %w", userID, jamID, ErrConflict)
+		}
+		return fmt.Errorf("adding team member: %w", err)
+	}
+
+	return nil
+}
+
+// JoinJamTeam adds the user to the team of the jam with the invite code,
+// failing with ErrConflict when the team is full.
+func (c *client) JoinJamTeam(jamID int, inviteCode string, userID int) (*model.JamTeam, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the team so concurrent joins cannot go past the size limit.
+	var teamID, maxSize, members int
+	err = tx.QueryRow(`
+		SELECT t.id, j.max_team_size
+		FROM jam_teams t JOIN game_jams j ON j.id = t.jam_id
+		WHERE t.jam_id = $1 AND t.invite_code = $2
+		FOR UPDATE OF t`,
+		jamID, inviteCode).Scan(&teamID, &maxSize)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no team of jam %d with that invite code: %w", jamID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("locking team: %w", err)
+	}
+
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM jam_team_members WHERE team_id = $1`, teamID).Scan(&members); err != nil {
+		return nil, fmt.Errorf("counting team members: %w", err)
+	}
+	if members >= maxSize {
+		return nil, fmt.Errorf("team %d is full: %w", teamID, ErrConflict)
+	}
+
+	if err := addJamTeamMember(tx, teamID, jamID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing team member: %w", err)
+	}
+
+	return c.GetJamTeam(teamID)
+}
+
+// LeaveJamTeam removes the user from the team. The last member to leave
+// takes the team and its entry with them.
+func (c *client) LeaveJamTeam(teamID, userID int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM jam_team_members WHERE team_id = $1 AND user_id = $2`, teamID, userID)
+	if err != nil {
+		return fmt.Errorf("leaving team: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("user %d is not in team %d: %w", userID, teamID, ErrNotFound)
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM jam_teams
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM jam_team_members WHERE team_id = $1)`,
+		teamID)
+	if err != nil {
+		return fmt.Errorf("removing empty team: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing team change: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) GetJamTeam(id int) (*model.JamTeam, error) {
+	team, err := scanJamTeam(c.db.QueryRow(`SELECT `+jamTeamColumns+` FROM jam_teams WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no team found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying team: %w", err)
+	}
+
+	return team, nil
+}
+
+func (c *client) GetJamTeams(jamID int) ([]model.JamTeam, error) {
+	rows, err := c.db.Query(`SELECT `+jamTeamColumns+` FROM jam_teams WHERE jam_id = $1 ORDER BY created_at, id`, jamID)
+	if err != nil {
+		return nil, fmt.Errorf("querying teams: %w", err)
+	}
+	defer rows.Close()
+
+	teams := []model.JamTeam{}
+	for rows.Next() {
+		team, err := scanJamTeam(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning team: %w", err)
+		}
+		teams = append(teams, *team)
+	}
+
+	return teams, rows.Err()
+}
+
+const jamEntryColumns = `jam_entries.id, jam_entries.jam_id, jam_entries.team_id, jam_teams.name, jam_entries.title,
+	jam_entries.description, jam_entries.build_url, jam_entries.build_key, jam_entries.screenshot_keys,
+	jam_entries.score, jam_entries.rank, jam_entries.submitted_at, jam_entries.updated_at`
+
+const jamEntrySource = `jam_entries JOIN jam_teams ON jam_teams.id = jam_entries.team_id`
+
+func scanJamEntry(row rowScanner) (*model.JamEntry, error) {
+	var entry model.JamEntry
+	var buildURL, buildKey sql.NullString
+	var score sql.NullFloat64
+	var rank sql.NullInt64
+	err := row.Scan(&entry.ID, &entry.JamID, &entry.TeamID, &entry.TeamName, &entry.Title, &entry.Description,
+		&buildURL, &buildKey, pq.Array(&entry.ScreenshotKeys), &score, &rank, &entry.SubmittedAt, &entry.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.BuildURL = buildURL.String
+	entry.BuildKey = buildKey.String
+	entry.HasBuild = buildKey.Valid
+	if score.Valid {
+		entry.Score = &score.Float64
+	}
+	if rank.Valid {
+		r := int(rank.Int64)
+		entry.Rank = &r
+	}
+
+	return &entry, nil
+}
+
+// SaveJamEntry creates or replaces the entry of a team. An entry without a
+// build key or screenshot keys keeps the ones it had.
+func (c *client) SaveJamEntry(entry model.JamEntry) (*model.JamEntry, error) {
+	var screenshotKeys interface{}
+	if entry.ScreenshotKeys != nil {
+		screenshotKeys = pq.Array(entry.ScreenshotKeys)
+	}
+
+	var id int
+	err := c.db.QueryRow(`
+		INSERT INTO jam_entries (jam_id, team_id, title, description, build_url, build_key, screenshot_keys)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7::text[], '{}'))
+		ON CONFLICT (team_id) DO UPDATE
+		SET title = EXCLUDED.title, description = EXCLUDED.description, build_url = EXCLUDED.build_url,
+		    build_key = COALESCE($6, jam_entries.build_key),
+		    screenshot_keys = COALESCE($7::text[], jam_entries.screenshot_keys),
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING id`,
+		entry.JamID, entry.TeamID, entry.Title, entry.Description, nullString(entry.BuildURL),
+		nullString(entry.BuildKey), screenshotKeys,
+	).Scan(&id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no team found with id %d: %w", entry.TeamID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("saving jam entry: %w", err)
+	}
+
+	return c.GetJamEntry(id)
+}
+
+func (c *client) GetJamEntry(id int) (*model.JamEntry, error) {
+	query := `SELECT ` + jamEntryColumns + ` FROM ` + jamEntrySource + ` WHERE jam_entries.id = $1`
+	entry, err := scanJamEntry(c.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no jam entry found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying jam entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+func (c *client) GetTeamEntry(teamID int) (*model.JamEntry, error) {
+	query := `SELECT ` + jamEntryColumns + ` FROM ` + jamEntrySource + ` WHERE jam_entries.team_id = $1`
+	entry, err := scanJamEntry(c.db.QueryRow(query, teamID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("team %d has no entry: %w", teamID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying jam entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// GetJamEntries returns the entries of a jam, ranked ones first. Once the
+// results are published every entry carries its score per criterion.
+func (c *client) GetJamEntries(jamID int) ([]model.JamEntry, error) {
+	query := `SELECT ` + jamEntryColumns + ` FROM ` + jamEntrySource + `
+		WHERE jam_entries.jam_id = $1
+		ORDER BY jam_entries.rank NULLS LAST, jam_entries.submitted_at, jam_entries.id`
+
+	rows, err := c.db.Query(query, jamID)
+	if err != nil {
+		return nil, fmt.Errorf("querying jam entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []model.JamEntry{}
+	byID := map[int]int{}
+	for rows.Next() {
+		entry, err := scanJamEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning jam entry: %w", err)
+		}
+		byID[entry.ID] = len(entries)
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scores, err := c.db.Query(`
+		SELECT s.entry_id, s.criterion_id, s.average, s.votes
+		FROM jam_entry_scores s
+		JOIN jam_entries e ON e.id = s.entry_id
+		JOIN jam_criteria c ON c.id = s.criterion_id
+		WHERE e.jam_id = $1
+		ORDER BY s.entry_id, c.position`,
+		jamID)
+	if err != nil {
+		return nil, fmt.Errorf("querying jam scores: %w", err)
+	}
+	defer scores.Close()
+
+	for scores.Next() {
+		var entryID int
+		var score model.CriterionScore
+		if err := scores.Scan(&entryID, &score.CriterionID, &score.Average, &score.Votes); err != nil {
+			return nil, fmt.Errorf("scanning jam score: %w", err)
+		}
+		if i, ok := byID[entryID]; ok {
+			entries[i].Scores = append(entries[i].Scores, score)
+		}
+	}
+
+	return entries, scores.Err()
+}
+
+// SaveJamVotes records a user's scores for an entry by criterion, replacing
+// earlier votes on the same criteria.
+func (c *client) SaveJamVotes(entryID, userID int, scores map[int]int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for criterionID, score := range scores {
+		_, err := tx.Exec(`
+			INSERT INTO jam_votes (entry_id, criterion_id, user_id, score) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (entry_id, criterion_id, user_id) DO UPDATE SET score = EXCLUDED.score`,
+			entryID, criterionID, userID, score)
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return fmt.Errorf("no jam entry %d or criterion %d: %w", entryID, criterionID, ErrNotFound)
+			}
+			return fmt.Errorf("saving jam vote: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing jam votes: %w", err)
+	}
+
+	return nil
+}
+
+// PublishJamResults tallies every jam whose voting ended by now and has no
+// results yet. An entry scores the mean of its criterion averages; entries
+// nobody voted on are left unranked. It returns the jams it published.
+func (c *client) PublishJamResults(now time.Time) ([]int, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		UPDATE game_jams SET results_published_at = $1
+		WHERE results_published_at IS NULL AND voting_ends_at <= $1
+		RETURNING id`,
+		now)
+	if err != nil {
+		return nil, fmt.Errorf("closing jams: %w", err)
+	}
+
+	var jamIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning jam: %w", err)
+		}
+		jamIDs = append(jamIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(jamIDs) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO jam_entry_scores (entry_id, criterion_id, average, votes)
+		SELECT v.entry_id, v.criterion_id, AVG(v.score), COUNT(*)
+		FROM jam_votes v JOIN jam_entries e ON e.id = v.entry_id
+		WHERE e.jam_id = ANY($1)
+		GROUP BY v.entry_id, v.criterion_id`,
+		pq.Array(jamIDs))
+	if err != nil {
+		return nil, fmt.Errorf("tallying jam votes: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		WITH scored AS (
+			SELECT e.id, AVG(s.average) AS score
+			FROM jam_entries e JOIN jam_entry_scores s ON s.entry_id = e.id
+			WHERE e.jam_id = ANY($1)
+			GROUP BY e.id
+		), ranked AS (
+			SELECT scored.id, scored.score, RANK() OVER (PARTITION BY e.jam_id ORDER BY scored.score DESC) AS rank
+			FROM scored JOIN jam_entries e ON e.id = scored.id
+		)
+		UPDATE jam_entries SET score = ranked.score, rank = ranked.rank
+		FROM ranked
+		WHERE jam_entries.id = ranked.id`,
+		pq.Array(jamIDs))
+	if err != nil {
+		return nil, fmt.Errorf("ranking jam entries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing jam results: %w", err)
+	}
+
+	return jamIDs, nil
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"time"
+)
+
+// RefreshLeaderboards recomputes the rankings of one season from the XP
+// ledger. Weekly seasons only count XP earned since seasonStart; the all time
+// season counts everything. Cohort rankings only count the course XP active
+// members earned since their cohort started. Users who opted out are left out
+// of the ranking.
+// seasonDate is how a season start is passed to the season_start DATE
+// column. A time.Time would be cast through the session time zone and could
+// land on the day before.
+func seasonDate(seasonStart time.Time) string {
+	return seasonStart.UTC().Format("2006-01-02")
+}
+
+func (c *client) RefreshLeaderboards(period string, seasonStart time.Time) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM leaderboard_entries WHERE period = $1 AND season_start = $2::date`, period, seasonDate(seasonStart))
+	if err != nil {
+		return fmt.Errorf("clearing %s leaderboards: %w", period, err)
+	}
+
+	_, err = tx.Exec(`
+		WITH earned AS (
+			SELECT l.user_id, l.course_id, l.amount, l.created_at
+			FROM xp_ledger l
+			JOIN users u ON u.id = l.user_id
+			WHERE NOT u.leaderboard_opt_out AND l.created_at >= $3
+		)
+		INSERT INTO leaderboard_entries (scope, scope_id, period, season_start, user_id, xp, rank)
+		SELECT 'global', 0, $1, $2::date, user_id, SUM(amount),
+		       RANK() OVER (ORDER BY SUM(amount) DESC)
+		FROM earned
+		GROUP BY user_id
+		UNION ALL
+		SELECT 'course', course_id, $1, $2::date, user_id, SUM(amount),
+		       RANK() OVER (PARTITION BY course_id ORDER BY SUM(amount) DESC)
+		FROM earned
+		WHERE course_id IS NOT NULL
+		GROUP BY course_id, user_id
+		UNION ALL
+		SELECT 'cohort', co.id, $1, $2::date, earned.user_id, SUM(earned.amount),
+		       RANK() OVER (PARTITION BY co.id ORDER BY SUM(earned.amount) DESC)
+		FROM earned
+		JOIN cohorts co ON co.course_id = earned.course_id AND earned.created_at >= co.starts_at
+		JOIN cohort_enrollments e ON e.cohort_id = co.id AND e.user_id = earned.user_id AND e.status = 'active'
+		GROUP BY co.id, earned.user_id`,
+		period, seasonDate(seasonStart), seasonStart)
+	if err != nil {
+		return fmt.Errorf("ranking %s leaderboards: %w", period, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing %s leaderboards: %w", period, err)
+	}
+
+	return nil
+}
+
+func (c *client) GetLeaderboard(scope string, scopeID int, period string, seasonStart time.Time, limit int) ([]model.LeaderboardEntry, error) {
+	rows, err := c.db.Query(`
+		SELECT rank, user_id, xp
+		FROM leaderboard_entries
+		WHERE scope = $1 AND scope_id = $2 AND period = $3 AND season_start = $4::date
+		ORDER BY rank, user_id
+		LIMIT $5`,
+		scope, scopeID, period, seasonDate(seasonStart), limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []model.LeaderboardEntry{}
+	for rows.Next() {
+		var entry model.LeaderboardEntry
+		if err := rows.Scan(&entry.Rank, &entry.UserID, &entry.XP); err != nil {
+			return nil, fmt.Errorf("scanning leaderboard entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (c *client) GetLeaderboardEntry(scope string, scopeID int, period string, seasonStart time.Time, userID int) (*model.LeaderboardEntry, error) {
+	var entry model.LeaderboardEntry
+	err := c.db.QueryRow(`
+		SELECT rank, user_id, xp
+		FROM leaderboard_entries
+		WHERE scope = $1 AND scope_id = $2 AND period = $3 AND season_start = $4::date AND user_id = $5`,
+		scope, scopeID, period, seasonDate(seasonStart), userID,
+	).Scan(&entry.Rank, &entry.UserID, &entry.XP)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %d is not ranked: %w", userID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying leaderboard entry: %w", err)
+	}
+
+	return &entry, nil
+}
+
+func (c *client) SetLeaderboardOptOut(userID int, optOut bool) error {
+	_, err := c.db.Exec(`UPDATE users SET leaderboard_opt_out = $2 WHERE id = $1`, userID, optOut)
+	if err != nil {
+		return fmt.Errorf("updating leaderboard opt out: %w", err)
+	}
+
+	// Drop the user from the current rankings right away instead of waiting
+	// for the next refresh.
+	if optOut {
+		if _, err := c.db.Exec(`DELETE FROM leaderboard_entries WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("removing user from leaderboards: %w", err)
+		}
+	}
+
+	return nil
+}
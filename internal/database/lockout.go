@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"time"
+)
+
+func scanSigninThrottle(row rowScanner) (*model.SigninThrottle, error) {
+	var throttle model.SigninThrottle
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		throttle.LockedUntil = &lockedUntil.Time
+	}
+
+	return &throttle, nil
+}
+
+// RecordSigninAttempt counts a sign in attempt for key at now, before its
+// outcome is known, and returns the throttle as it stood before the attempt.
+// Failures older than window no longer count, and attempts made while the
+// key is locked are not counted so they do not extend the lock. Concurrent
+// attempts on a key are counted one after the other, each seeing the ones
+// before it.
+func (c *client) RecordSigninAttempt(key string, now time.Time, window time.Duration) (*model.SigninThrottle, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO signin_throttles (key, failures, last_failure_at) VALUES ($1, 0, $2) ON CONFLICT (key) DO NOTHING`,
+		key, now)
+	if err != nil {
+		return nil, fmt.Errorf("creating signin throttle: %w", err)
+	}
+
+	before, err := scanSigninThrottle(tx.QueryRow(`
+		SELECT key, CASE WHEN last_failure_at < $2 THEN 0 ELSE failures END, last_failure_at, locked_until
+		FROM signin_throttles
+		WHERE key = $1
+		FOR UPDATE`,
+		key, now.Add(-window)))
+	if err != nil {
+		return nil, fmt.Errorf("locking signin throttle: %w", err)
+	}
+
+	if before.LockedUntil == nil || !before.LockedUntil.After(now) {
+		_, err = tx.Exec(`UPDATE signin_throttles SET failures = $2, last_failure_at = $3 WHERE key = $1`,
+			key, before.Failures+1, now)
+		if err != nil {
+			return nil, fmt.Errorf("recording signin attempt: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing signin attempt: %w", err)
+	}
+
+	return before, nil
+}
+
+// LockSignin locks key out until the given time and starts its failure
+// count over, so the lock is not extended by attempts made while it holds.
+func (c *client) LockSignin(key string, until time.Time) error {
+	if _, err := c.db.Exec(`UPDATE signin_throttles SET locked_until = $2, failures = 0 WHERE key = $1`, key, until); err != nil {
+		return fmt.Errorf("locking signin: %w", err)
+	}
+
+	return nil
+}
+
+// UncountSigninAttempt takes back one attempt counted for key, for one whose
+// credentials turned out right.
+func (c *client) UncountSigninAttempt(key string) error {
+	if _, err := c.db.Exec(`UPDATE signin_throttles SET failures = failures - 1 WHERE key = $1 AND failures > 0`, key); err != nil {
+		return fmt.Errorf("uncounting signin attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) ClearSigninFailures(key string) error {
+	if _, err := c.db.Exec(`DELETE FROM signin_throttles WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("clearing signin failures: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStaleSigninThrottles removes the keys with no failure since before
+// and no lock still running.
+func (c *client) DeleteStaleSigninThrottles(before time.Time) error {
+	_, err := c.db.Exec(`
+		DELETE FROM signin_throttles
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)`,
+		before)
+	if err != nil {
+		return fmt.Errorf("deleting stale signin throttles: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+const noteColumns = `notes.id, notes.user_id, notes.training_id, trainings.name, notes.position_seconds, notes.body,
+	notes.created_at, notes.updated_at`
+
+// noteSource joins the training so notes can name it.
+const noteSource = `notes JOIN trainings ON trainings.id = notes.training_id`
+
+func scanNote(row rowScanner) (*model.Note, error) {
+	var note model.Note
+	err := row.Scan(&note.ID, &note.UserID, &note.TrainingID, &note.TrainingName, &note.PositionSeconds, &note.Body,
+		&note.CreatedAt, &note.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &note, nil
+}
+
+func (c *client) CreateNote(note model.Note) (*model.Note, error) {
+	query := `
+		INSERT INTO notes (user_id, training_id, position_seconds, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	var id int
+	err := c.db.QueryRow(query, note.UserID, note.TrainingID, note.PositionSeconds, note.Body).Scan(&id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no training found with id %d: %w", note.TrainingID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("creating note: %w", err)
+	}
+
+	return c.GetNote(id)
+}
+
+func (c *client) GetNote(id int) (*model.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM ` + noteSource + ` WHERE notes.id = $1`
+	note, err := scanNote(c.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no note found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying note: %w", err)
+	}
+
+	return note, nil
+}
+
+func (c *client) UpdateNote(id, positionSeconds int, body string) (*model.Note, error) {
+	query := `UPDATE notes SET position_seconds = $2, body = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	result, err := c.db.Exec(query, id, positionSeconds, body)
+	if err != nil {
+		return nil, fmt.Errorf("updating note: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return nil, fmt.Errorf("no note found with id %d: %w", id, ErrNotFound)
+	}
+
+	return c.GetNote(id)
+}
+
+func (c *client) DeleteNote(id int) error {
+	result, err := c.db.Exec(`DELETE FROM notes WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting note: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("no note found with id %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetTrainingNotes returns a user's notes on a training in video order.
+func (c *client) GetTrainingNotes(userID, trainingID int) ([]model.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM ` + noteSource + `
+		WHERE notes.user_id = $1 AND notes.training_id = $2
+		ORDER BY notes.position_seconds, notes.id`
+
+	return c.queryNotes(query, userID, trainingID)
+}
+
+// GetCourseNotes returns a user's notes across a course, ordered by
+// training sequence and then video position.
+func (c *client) GetCourseNotes(userID, courseID int) ([]model.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM ` + noteSource + `
+		WHERE notes.user_id = $1 AND trainings.course_id = $2
+		ORDER BY trainings.sequence, trainings.id, notes.position_seconds, notes.id`
+
+	return c.queryNotes(query, userID, courseID)
+}
+
+// SearchNotes matches the text within a user's own notes, most recently
+// edited first.
+func (c *client) SearchNotes(userID int, text string, limit int) ([]model.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM ` + noteSource + `
+		WHERE notes.user_id = $1 AND notes.body ILIKE $2
+		ORDER BY notes.updated_at DESC, notes.id DESC
+		LIMIT $3`
+
+	return c.queryNotes(query, userID, "%"+escapeLike(text)+"%", limit)
+}
+
+func (c *client) queryNotes(query string, args ...interface{}) ([]model.Note, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []model.Note{}
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning note: %w", err)
+		}
+		notes = append(notes, *note)
+	}
+
+	return notes, rows.Err()
+}
+
+// AddBookmark bookmarks a training for a user. Bookmarking twice is a no-op.
+func (c *client) AddBookmark(userID, trainingID int) error {
+	_, err := c.db.Exec(`INSERT INTO bookmarks (user_id, training_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		userID, trainingID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("no training found with id %d: %w", trainingID, ErrNotFound)
+		}
+		return fmt.Errorf("adding bookmark: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) RemoveBookmark(userID, trainingID int) error {
+	result, err := c.db.Exec(`DELETE FROM bookmarks WHERE user_id = $1 AND training_id = $2`, userID, trainingID)
+	if err != nil {
+		return fmt.Errorf("removing bookmark: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("no bookmark of training %d: %w", trainingID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetBookmarks returns a user's bookmarked trainings, newest first.
+func (c *client) GetBookmarks(userID int) ([]model.Bookmark, error) {
+	query := `
+		SELECT trainings.id, trainings.name, trainings.course_id, bookmarks.created_at
+		FROM bookmarks JOIN trainings ON trainings.id = bookmarks.training_id
+		WHERE bookmarks.user_id = $1
+		ORDER BY bookmarks.created_at DESC, trainings.id`
+
+	rows, err := c.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	bookmarks := []model.Bookmark{}
+	for rows.Next() {
+		var bookmark model.Bookmark
+		if err := rows.Scan(&bookmark.TrainingID, &bookmark.TrainingName, &bookmark.CourseID, &bookmark.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning bookmark: %w", err)
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	return bookmarks, rows.Err()
+}
@@ -0,0 +1,284 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+// AddPrerequisite makes prerequisiteID a prerequisite of courseID, or
+// updates whether it is required. Edges that would close a cycle are
+// rejected with ErrConflict.
+func (c *client) AddPrerequisite(courseID, prerequisiteID int, required bool) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serializes concurrent edits so two edges cannot close a cycle together
+	if _, err := tx.Exec(`LOCK TABLE course_prerequisites IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("locking prerequisites: %w", err)
+	}
+
+	var cycle bool
+	err = tx.QueryRow(`
+		WITH RECURSIVE ancestors AS (
+			SELECT prerequisite_id FROM course_prerequisites WHERE course_id = $1
+			UNION
+			SELECT p.prerequisite_id FROM course_prerequisites p JOIN ancestors a ON p.course_id = a.prerequisite_id
+		)
+		SELECT $1 = $2 OR EXISTS (SELECT 1 FROM ancestors WHERE prerequisite_id = $2)`,
+		prerequisiteID, courseID,
+	).Scan(&cycle)
+	if err != nil {
+		return fmt.Errorf("checking prerequisite cycle: %w", err)
+	}
+
+	if cycle {
+		return fmt.Errorf("course %d already requires course %d: %w", prerequisiteID, courseID, ErrConflict)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO course_prerequisites (course_id, prerequisite_id, required)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (course_id, prerequisite_id) DO UPDATE SET required = EXCLUDED.required`,
+		courseID, prerequisiteID, required)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("no course found with id %d: %w", prerequisiteID, ErrNotFound)
+		}
+		return fmt.Errorf("adding prerequisite: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing prerequisite: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) RemovePrerequisite(courseID, prerequisiteID int) error {
+	result, err := c.db.Exec(`DELETE FROM course_prerequisites WHERE course_id = $1 AND prerequisite_id = $2`, courseID, prerequisiteID)
+	if err != nil {
+		return fmt.Errorf("removing prerequisite: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("course %d is not a prerequisite of course %d: %w", prerequisiteID, courseID, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (c *client) GetPrerequisites(courseID int) ([]model.Prerequisite, error) {
+	query := `
+		SELECT p.prerequisite_id, c.name, p.required
+		FROM course_prerequisites p
+		JOIN courses c ON c.id = p.prerequisite_id
+		WHERE p.course_id = $1 AND c.status = 'published'
+		ORDER BY p.required DESC, c.name`
+
+	return c.queryPrerequisites(query, courseID)
+}
+
+// GetMissingPrerequisites lists the prerequisites of a course the user has
+// not finished, that is, with a published training left to complete.
+// Unpublished courses and courses with nothing published yet cannot be
+// finished, so they never hold a student back.
+func (c *client) GetMissingPrerequisites(userID, courseID int) ([]model.Prerequisite, error) {
+	query := `
+		SELECT p.prerequisite_id, c.name, p.required
+		FROM course_prerequisites p
+		JOIN courses c ON c.id = p.prerequisite_id
+		WHERE p.course_id = $2 AND c.status = 'published' AND EXISTS (
+			SELECT 1 FROM trainings t
+			LEFT JOIN training_progress tp ON tp.training_id = t.id AND tp.user_id = $1
+			WHERE t.course_id = c.id AND t.status = 'published' AND tp.completed_at IS NULL
+		)
+		ORDER BY p.required DESC, c.name`
+
+	return c.queryPrerequisites(query, userID, courseID)
+}
+
+func (c *client) queryPrerequisites(query string, args ...interface{}) ([]model.Prerequisite, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying prerequisites: %w", err)
+	}
+	defer rows.Close()
+
+	prerequisites := []model.Prerequisite{}
+	for rows.Next() {
+		var prerequisite model.Prerequisite
+		if err := rows.Scan(&prerequisite.CourseID, &prerequisite.Name, &prerequisite.Required); err != nil {
+			return nil, fmt.Errorf("scanning prerequisite: %w", err)
+		}
+		prerequisites = append(prerequisites, prerequisite)
+	}
+
+	return prerequisites, rows.Err()
+}
+
+func (c *client) HasStartedCourse(userID, courseID int) (bool, error) {
+	var started bool
+	err := c.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM training_progress p JOIN trainings t ON t.id = p.training_id
+			WHERE p.user_id = $1 AND t.course_id = $2
+		)`, userID, courseID).Scan(&started)
+	if err != nil {
+		return false, fmt.Errorf("checking whether user %d started course %d: %w", userID, courseID, err)
+	}
+
+	return started, nil
+}
+
+// SaveLearningPath creates a path when its id is zero and updates it
+// otherwise. Its courses are replaced in the given order.
+func (c *client) SaveLearningPath(path model.LearningPath) (*model.LearningPath, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if path.ID == 0 {
+		err = tx.QueryRow(`INSERT INTO learning_paths (slug, name, description) VALUES ($1, $2, $3) RETURNING id`,
+			path.Slug, path.Name, path.Description).Scan(&path.ID)
+	} else {
+		err = tx.QueryRow(`UPDATE learning_paths SET slug = $2, name = $3, description = $4 WHERE id = $1 RETURNING id`,
+			path.ID, path.Slug, path.Name, path.Description).Scan(&path.ID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no learning path found with id %d: %w", path.ID, ErrNotFound)
+		}
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("learning path %s: %w", path.Slug, ErrConflict)
+		}
+		return nil, fmt.Errorf("saving learning path: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM learning_path_courses WHERE path_id = $1`, path.ID); err != nil {
+		return nil, fmt.Errorf("removing path courses: %w", err)
+	}
+
+	for i := range path.Courses {
+		course := &path.Courses[i]
+		err := tx.QueryRow(`
+			INSERT INTO learning_path_courses (path_id, course_id, position)
+			VALUES ($1, $2, $3)
+			RETURNING (SELECT name FROM courses WHERE id = $2), (SELECT status FROM courses WHERE id = $2)`,
+			path.ID, course.CourseID, i,
+		).Scan(&course.Name, &course.Status)
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return nil, fmt.Errorf("no course found with id %d: %w", course.CourseID, ErrNotFound)
+			}
+			if isUniqueViolation(err) {
+				return nil, fmt.Errorf("course %d appears twice in the path: %w", course.CourseID, ErrConflict)
+			}
+			return nil, fmt.Errorf("saving path course %d: %w", course.CourseID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing learning path: %w", err)
+	}
+
+	return &path, nil
+}
+
+func (c *client) DeleteLearningPath(id int) error {
+	result, err := c.db.Exec(`DELETE FROM learning_paths WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting learning path: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("no learning path found with id %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (c *client) GetLearningPaths() ([]model.LearningPath, error) {
+	rows, err := c.db.Query(`SELECT id, slug, name, description FROM learning_paths ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying learning paths: %w", err)
+	}
+	defer rows.Close()
+
+	paths := []model.LearningPath{}
+	index := map[int]int{}
+	for rows.Next() {
+		var path model.LearningPath
+		if err := rows.Scan(&path.ID, &path.Slug, &path.Name, &path.Description); err != nil {
+			return nil, fmt.Errorf("scanning learning path: %w", err)
+		}
+		path.Courses = []model.PathCourse{}
+		index[path.ID] = len(paths)
+		paths = append(paths, path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	courses, err := c.db.Query(`
+		SELECT pc.path_id, c.id, c.name, c.status
+		FROM learning_path_courses pc
+		JOIN courses c ON c.id = pc.course_id
+		ORDER BY pc.path_id, pc.position`)
+	if err != nil {
+		return nil, fmt.Errorf("querying path courses: %w", err)
+	}
+	defer courses.Close()
+
+	for courses.Next() {
+		var pathID int
+		var course model.PathCourse
+		if err := courses.Scan(&pathID, &course.CourseID, &course.Name, &course.Status); err != nil {
+			return nil, fmt.Errorf("scanning path course: %w", err)
+		}
+		if i, ok := index[pathID]; ok {
+			paths[i].Courses = append(paths[i].Courses, course)
+		}
+	}
+
+	return paths, courses.Err()
+}
+
+func (c *client) GetLearningPath(id int) (*model.LearningPath, error) {
+	var path model.LearningPath
+	err := c.db.QueryRow(`SELECT id, slug, name, description FROM learning_paths WHERE id = $1`, id).
+		Scan(&path.ID, &path.Slug, &path.Name, &path.Description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no learning path found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying learning path: %w", err)
+	}
+
+	rows, err := c.db.Query(`
+		SELECT c.id, c.name, c.status
+		FROM learning_path_courses pc
+		JOIN courses c ON c.id = pc.course_id
+		WHERE pc.path_id = $1
+		ORDER BY pc.position`, id)
+	if err != nil {
+		return nil, fmt.Errorf("querying path courses: %w", err)
+	}
+	defer rows.Close()
+
+	path.Courses = []model.PathCourse{}
+	for rows.Next() {
+		var course model.PathCourse
+		if err := rows.Scan(&course.CourseID, &course.Name, &course.Status); err != nil {
+			return nil, fmt.Errorf("scanning path course: %w", err)
+		}
+		path.Courses = append(path.Courses, course)
+	}
+
+	return &path, rows.Err()
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+const portfolioColumns = `user_id, slug, display_name, headline, public, updated_at`
+
+func scanPortfolio(row rowScanner) (*model.Portfolio, error) {
+	var portfolio model.Portfolio
+	err := row.Scan(&portfolio.UserID, &portfolio.Slug, &portfolio.DisplayName, &portfolio.Headline, &portfolio.Public,
+		&portfolio.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &portfolio, nil
+}
+
+// SavePortfolio creates or updates the portfolio of a user. Slugs are unique
+// across users.
+func (c *client) SavePortfolio(portfolio model.Portfolio) (*model.Portfolio, error) {
+	query := `
+		INSERT INTO portfolios (user_id, slug, display_name, headline, public)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET slug = EXCLUDED.slug, display_name = EXCLUDED.display_name, headline = EXCLUDED.headline,
+		    public = EXCLUDED.public, updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + portfolioColumns
+
+	saved, err := scanPortfolio(c.db.QueryRow(query, portfolio.UserID, portfolio.Slug, portfolio.DisplayName,
+		portfolio.Headline, portfolio.Public))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("portfolio slug %q: %w", portfolio.Slug, ErrConflict)
+		}
+		return nil, fmt.Errorf("saving portfolio: %w", err)
+	}
+
+	return saved, nil
+}
+
+func (c *client) GetPortfolio(userID int) (*model.Portfolio, error) {
+	portfolio, err := scanPortfolio(c.db.QueryRow(`SELECT `+portfolioColumns+` FROM portfolios WHERE user_id = $1`, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %d has no portfolio: %w", userID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying portfolio: %w", err)
+	}
+
+	return portfolio, nil
+}
+
+func (c *client) GetPortfolioBySlug(slug string) (*model.Portfolio, error) {
+	portfolio, err := scanPortfolio(c.db.QueryRow(`SELECT `+portfolioColumns+` FROM portfolios WHERE slug = $1`, slug))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no portfolio found with slug %q: %w", slug, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying portfolio: %w", err)
+	}
+
+	return portfolio, nil
+}
+
+// GetPortfolioItems gathers a user's approved submissions, entries of jams
+// past their deadline, valid certificates and badges, newest first, flagging
+// the ones the user hid. Jam entries with an uploaded build link to its
+// download path, relative to the API; entries without a build have no link.
+func (c *client) GetPortfolioItems(userID int) ([]model.PortfolioItem, error) {
+	rows, err := c.db.Query(`
+		WITH items AS (
+			SELECT 'submission' AS kind, s.id::text AS key, t.name AS title, co.name AS subtitle, s.url,
+			       COALESCE(s.reviewed_at, s.submitted_at) AS date
+			FROM submissions s
+			JOIN trainings t ON t.id = s.training_id
+			JOIN courses co ON co.id = t.course_id
+			WHERE s.user_id = $1 AND s.status = 'approved'
+			UNION ALL
+			SELECT 'jam_entry', e.id::text, e.title, j.title,
+			       COALESCE(e.build_url, CASE WHEN e.build_key IS NOT NULL THEN '/entries/' || e.id || '/build' END, ''),
+			       e.submitted_at
+			FROM jam_entries e
+			JOIN jam_team_members m ON m.team_id = e.team_id
+			JOIN game_jams j ON j.id = e.jam_id
+			WHERE m.user_id = $1 AND j.submission_deadline <= CURRENT_TIMESTAMP
+			UNION ALL
+			SELECT 'certificate', code, course_name, '', '', issued_at
+			FROM certificates
+			WHERE user_id = $1 AND revoked_at IS NULL
+			UNION ALL
+			SELECT 'badge', badge, badge, '', '', awarded_at
+			FROM user_badges
+			WHERE user_id = $1
+		)
+		SELECT kind, key, title, subtitle, url, date,
+		       NOT EXISTS (SELECT 1 FROM portfolio_hidden_items h
+		                   WHERE h.user_id = $1 AND h.kind = items.kind AND h.item_key = items.key)
+		FROM items
+		ORDER BY date DESC, kind, key`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying portfolio items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []model.PortfolioItem{}
+	for rows.Next() {
+		var item model.PortfolioItem
+		if err := rows.Scan(&item.Kind, &item.Key, &item.Title, &item.Subtitle, &item.URL, &item.Date, &item.Visible); err != nil {
+			return nil, fmt.Errorf("scanning portfolio item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (c *client) SetPortfolioItemVisibility(userID int, kind, key string, visible bool) error {
+	query := `DELETE FROM portfolio_hidden_items WHERE user_id = $1 AND kind = $2 AND item_key = $3`
+	if !visible {
+		query = `INSERT INTO portfolio_hidden_items (user_id, kind, item_key) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	}
+
+	if _, err := c.db.Exec(query, userID, kind, key); err != nil {
+		return fmt.Errorf("updating portfolio item visibility: %w", err)
+	}
+
+	return nil
+}
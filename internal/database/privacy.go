@@ -0,0 +1,297 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"time"
+)
+
+// GetPersonalData gathers what the database holds about a user for a data
+// export. Card details live with the payment provider and are left for the
+// caller to fill in.
+func (c *client) GetPersonalData(userID int) (*model.PersonalData, error) {
+	profile, err := c.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	data := model.PersonalData{Profile: profile, Cards: []model.Card{}}
+
+	data.Payments, err = c.getPaymentsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Progress, err = c.getProgressByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Submissions, err = c.GetSubmissionsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Reviews, err = c.queryReviews(`SELECT `+reviewColumns+` FROM course_reviews WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Certificates, err = c.GetCertificatesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Notes, err = c.queryNotes(`SELECT `+noteColumns+` FROM `+noteSource+` WHERE notes.user_id = $1 ORDER BY notes.created_at`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Identities, err = c.GetUserIdentities(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exports show empty lists as [] rather than null
+	if data.Reviews == nil {
+		data.Reviews = []model.Review{}
+	}
+	if data.Notes == nil {
+		data.Notes = []model.Note{}
+	}
+	if data.Identities == nil {
+		data.Identities = []model.UserIdentity{}
+	}
+
+	return &data, nil
+}
+
+func (c *client) getPaymentsByUser(userID int) ([]model.Payment, error) {
+	rows, err := c.db.Query(`
+		SELECT id, stripe_payment_intent_id, stripe_pay_method_id, user_id, amount, currency, status, created_at, updated_at
+		FROM payments
+		WHERE user_id = $1
+		ORDER BY created_at`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying payments of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	payments := []model.Payment{}
+	for rows.Next() {
+		var payment model.Payment
+		err := rows.Scan(&payment.ID, &payment.StripePaymentIntentID, &payment.StripePayMethodID, &payment.UserID, &payment.Amount,
+			&payment.Currency, &payment.Status, &payment.CreatedAt, &payment.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scanning payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+func (c *client) getProgressByUser(userID int) ([]model.TrainingProgress, error) {
+	rows, err := c.db.Query(`SELECT `+progressColumns+` FROM training_progress WHERE user_id = $1 ORDER BY started_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying progress of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	progress := []model.TrainingProgress{}
+	for rows.Next() {
+		p, err := scanProgress(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning progress: %w", err)
+		}
+		progress = append(progress, *p)
+	}
+
+	return progress, rows.Err()
+}
+
+const dataExportColumns = `id, user_id, status, file_key, error, requested_at, completed_at, expires_at`
+
+func scanDataExport(row rowScanner) (*model.DataExport, error) {
+	var export model.DataExport
+	var completedAt, expiresAt sql.NullTime
+	err := row.Scan(&export.ID, &export.UserID, &export.Status, &export.FileKey, &export.Error, &export.RequestedAt,
+		&completedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+
+	return &export, nil
+}
+
+// CreateDataExport queues an export for the user. A user may only have one
+// export waiting at a time, otherwise ErrConflict is returned.
+func (c *client) CreateDataExport(userID int) (*model.DataExport, error) {
+	export, err := scanDataExport(c.db.QueryRow(`INSERT INTO data_exports (user_id) VALUES ($1) RETURNING `+dataExportColumns, userID))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("user %d already has an export in progress: %w", userID, ErrConflict)
+		}
+		return nil, fmt.Errorf("creating data export: %w", err)
+	}
+
+	return export, nil
+}
+
+func (c *client) GetDataExport(id int) (*model.DataExport, error) {
+	export, err := scanDataExport(c.db.QueryRow(`SELECT `+dataExportColumns+` FROM data_exports WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no data export found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying data export: %w", err)
+	}
+
+	return export, nil
+}
+
+func (c *client) GetDataExports(userID int) ([]model.DataExport, error) {
+	return c.queryDataExports(`SELECT `+dataExportColumns+` FROM data_exports WHERE user_id = $1 ORDER BY requested_at DESC, id DESC`,
+		userID)
+}
+
+// GetPendingDataExports lists the exports waiting to be built, oldest
+// first.
+func (c *client) GetPendingDataExports(limit int) ([]model.DataExport, error) {
+	return c.queryDataExports(`SELECT `+dataExportColumns+` FROM data_exports WHERE status = $1 ORDER BY requested_at, id LIMIT $2`,
+		model.ExportPending, limit)
+}
+
+// GetExpiredDataExports lists the ready exports whose download window ended
+// before now.
+func (c *client) GetExpiredDataExports(now time.Time) ([]model.DataExport, error) {
+	return c.queryDataExports(`SELECT `+dataExportColumns+` FROM data_exports WHERE status = $1 AND expires_at <= $2`,
+		model.ExportReady, now)
+}
+
+func (c *client) queryDataExports(query string, args ...interface{}) ([]model.DataExport, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying data exports: %w", err)
+	}
+	defer rows.Close()
+
+	exports := []model.DataExport{}
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning data export: %w", err)
+		}
+		exports = append(exports, *export)
+	}
+
+	return exports, rows.Err()
+}
+
+func (c *client) CompleteDataExport(id int, fileKey string, expiresAt time.Time) error {
+	return c.finishDataExport(`
+		UPDATE data_exports SET status = 'ready', file_key = $2, expires_at = $3, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		id, fileKey, expiresAt)
+}
+
+func (c *client) FailDataExport(id int, reason string) error {
+	return c.finishDataExport(`UPDATE data_exports SET status = 'failed', error = $2, completed_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		id, reason)
+}
+
+// ExpireDataExport marks an export whose file was removed.
+func (c *client) ExpireDataExport(id int) error {
+	return c.finishDataExport(`UPDATE data_exports SET status = 'expired', file_key = '' WHERE id = $1`, id)
+}
+
+func (c *client) finishDataExport(query string, args ...interface{}) error {
+	result, err := c.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("updating data export: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no data export found with id %v: %w", args[0], ErrNotFound)
+	}
+
+	return nil
+}
+
+// LogAccountDeletion records a deletion request before it is carried out.
+func (c *client) LogAccountDeletion(deletion model.AccountDeletion) (int, error) {
+	var id int
+	err := c.db.QueryRow(`INSERT INTO account_deletions (user_id, ip_address, user_agent) VALUES ($1, $2, $3) RETURNING id`,
+		deletion.UserID, deletion.IPAddress, deletion.UserAgent).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("logging account deletion: %w", err)
+	}
+
+	return id, nil
+}
+
+// AnonymizeUser strips a user's personal data and closes the deletion
+// request. The user row stays so payments, which must be kept for legal
+// retention, still point somewhere. Certificates lose the student's name and
+// are revoked, and private content such as notes is removed.
+func (c *client) AnonymizeUser(userID, deletionID int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users
+		SET email = 'deleted-' || id || '@deleted.invalid', password = '', stripe_id = NULL, display_name = '',
+		    avatar_url = '', bio = '', country = '', calendar_token = NULL, leaderboard_opt_out = TRUE,
+		    totp_secret = NULL, totp_enabled_at = NULL, deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL`,
+		userID)
+	if err != nil {
+		return fmt.Errorf("anonymizing user: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no active user found with id %d: %w", userID, ErrNotFound)
+	}
+
+	statements := []string{
+		`UPDATE certificates SET student_name = '', revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP),
+		 revoked_reason = COALESCE(revoked_reason, 'account deleted') WHERE user_id = $1`,
+		`UPDATE instructors SET display_name = '', bio = '', avatar_url = '', social_links = '{}', payout_account = ''
+		 WHERE user_id = $1`,
+		`DELETE FROM cards WHERE user_id = $1`,
+		`DELETE FROM notes WHERE user_id = $1`,
+		`DELETE FROM bookmarks WHERE user_id = $1`,
+		`DELETE FROM portfolio_hidden_items WHERE user_id = $1`,
+		`DELETE FROM portfolios WHERE user_id = $1`,
+		`DELETE FROM email_changes WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM two_factor_challenges WHERE user_id = $1`,
+		`DELETE FROM data_exports WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, userID); err != nil {
+			return fmt.Errorf("removing personal data: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE account_deletions SET completed_at = CURRENT_TIMESTAMP WHERE id = $1`, deletionID); err != nil {
+		return fmt.Errorf("closing account deletion: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing account deletion: %w", err)
+	}
+
+	return nil
+}
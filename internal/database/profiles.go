@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UpdateUserProfile saves the profile fields of a user. Email, password and
+// role have their own flows and are left untouched.
+func (c *client) UpdateUserProfile(user model.User) (model.User, error) {
+	query := `
+		UPDATE users
+		SET display_name = $2, avatar_url = $3, bio = $4, locale = $5, time_zone = $6, country = $7
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	updated, err := scanUser(c.db.QueryRow(query, user.ID, user.DisplayName, user.AvatarURL, user.Bio, user.Locale,
+		user.TimeZone, user.Country))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.User{}, fmt.Errorf("no user found with id %d: %w", user.ID, ErrNotFound)
+		}
+		return model.User{}, fmt.Errorf("updating profile: %w", err)
+	}
+
+	return updated, nil
+}
+
+func (c *client) SetUserPassword(userID int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+
+	result, err := c.db.Exec(`UPDATE users SET password = $2 WHERE id = $1`, userID, hashedPassword)
+	if err != nil {
+		return fmt.Errorf("updating password: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// CreateEmailChange records a pending email change, replacing any earlier
+// one of the user. Addresses already taken are rejected with ErrConflict,
+// though one taken meanwhile is only caught by ConfirmEmailChange.
+func (c *client) CreateEmailChange(change model.EmailChange, tokenHash string) error {
+	result, err := c.db.Exec(`
+		INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($2))
+		ON CONFLICT (user_id) DO UPDATE
+		SET new_email = EXCLUDED.new_email, token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at,
+		    created_at = CURRENT_TIMESTAMP`,
+		change.UserID, change.NewEmail, tokenHash, change.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("saving email change: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("email %s is already in use: %w", change.NewEmail, ErrConflict)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange applies the unexpired email change matching the token
+// hash, returning the updated user and the address it replaced.
+func (c *client) ConfirmEmailChange(tokenHash string) (model.User, string, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return model.User{}, "", fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var newEmail, oldEmail string
+	err = tx.QueryRow(`
+		DELETE FROM email_changes ec
+		USING users u
+		WHERE u.id = ec.user_id AND ec.token_hash = $1 AND ec.expires_at > CURRENT_TIMESTAMP
+		RETURNING ec.user_id, ec.new_email, u.email`,
+		tokenHash).Scan(&userID, &newEmail, &oldEmail)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.User{}, "", fmt.Errorf("no pending email change for token: %w", ErrNotFound)
+		}
+		return model.User{}, "", fmt.Errorf("loading email change: %w", err)
+	}
+
+	query := `UPDATE users SET email = $2 WHERE id = $1 RETURNING ` + userColumns
+	user, err := scanUser(tx.QueryRow(query, userID, newEmail))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.User{}, "", fmt.Errorf("email %s is already in use: %w", newEmail, ErrConflict)
+		}
+		return model.User{}, "", fmt.Errorf("updating email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.User{}, "", fmt.Errorf("committing email change: %w", err)
+	}
+
+	return user, oldEmail, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+const progressColumns = `user_id, training_id, started_at, completed_at, position_seconds, updated_at`
+
+func scanProgress(row rowScanner) (*model.TrainingProgress, error) {
+	var progress model.TrainingProgress
+	var completedAt sql.NullTime
+	err := row.Scan(&progress.UserID, &progress.TrainingID, &progress.StartedAt, &completedAt, &progress.PositionSeconds, &progress.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		progress.CompletedAt = &completedAt.Time
+	}
+
+	return &progress, nil
+}
+
+func (c *client) StartTraining(userID, trainingID int) (*model.TrainingProgress, error) {
+	query := `
+		INSERT INTO training_progress (user_id, training_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, training_id) DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + progressColumns
+
+	progress, err := scanProgress(c.db.QueryRow(query, userID, trainingID))
+	if err != nil {
+		return nil, fmt.Errorf("starting training %d: %w", trainingID, err)
+	}
+
+	return progress, nil
+}
+
+func (c *client) CompleteTraining(userID, trainingID int) (*model.TrainingProgress, error) {
+	// Completing a training that was never started also records the start,
+	// and completing it twice keeps the first completion date.
+	query := `
+		INSERT INTO training_progress (user_id, training_id, completed_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id, training_id) DO UPDATE
+		SET completed_at = COALESCE(training_progress.completed_at, EXCLUDED.completed_at),
+		    updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + progressColumns
+
+	progress, err := scanProgress(c.db.QueryRow(query, userID, trainingID))
+	if err != nil {
+		return nil, fmt.Errorf("completing training %d: %w", trainingID, err)
+	}
+
+	return progress, nil
+}
+
+func (c *client) UpdateTrainingPosition(userID, trainingID, positionSeconds int) (*model.TrainingProgress, error) {
+	query := `
+		INSERT INTO training_progress (user_id, training_id, position_seconds)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, training_id) DO UPDATE
+		SET position_seconds = EXCLUDED.position_seconds, updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + progressColumns
+
+	progress, err := scanProgress(c.db.QueryRow(query, userID, trainingID, positionSeconds))
+	if err != nil {
+		return nil, fmt.Errorf("updating position of training %d: %w", trainingID, err)
+	}
+
+	return progress, nil
+}
+
+func (c *client) GetCourseProgress(userID, courseID int) (model.CourseProgress, error) {
+	query := `
+		SELECT COUNT(t.id), COUNT(p.completed_at)
+		FROM trainings t
+		LEFT JOIN training_progress p ON p.training_id = t.id AND p.user_id = $1
+		WHERE t.course_id = $2 AND t.status = 'published'`
+
+	progress := model.CourseProgress{CourseID: courseID}
+	err := c.db.QueryRow(query, userID, courseID).Scan(&progress.TotalTrainings, &progress.CompletedTrainings)
+	if err != nil {
+		return model.CourseProgress{}, fmt.Errorf("querying progress for course %d: %w", courseID, err)
+	}
+
+	if progress.TotalTrainings > 0 {
+		progress.Percentage = float64(progress.CompletedTrainings) * 100 / float64(progress.TotalTrainings)
+	}
+
+	return progress, nil
+}
+
+func (c *client) GetNextTraining(userID, courseID int) (model.Training, error) {
+	query := `
+		SELECT ` + trainingColumns + `
+		FROM trainings
+		LEFT JOIN training_progress p ON p.training_id = trainings.id AND p.user_id = $1
+		WHERE trainings.course_id = $2 AND trainings.status = 'published' AND p.completed_at IS NULL
+		ORDER BY trainings.sequence
+		LIMIT 1`
+
+	training, err := scanTraining(c.db.QueryRow(query, userID, courseID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Training{}, fmt.Errorf("no pending training in course %d: %w", courseID, ErrNotFound)
+		}
+		return model.Training{}, fmt.Errorf("querying next training: %w", err)
+	}
+
+	return training, nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"time"
+)
+
+// SetCourseStatus moves a course through its publication lifecycle.
+// publishAt is only kept for scheduled courses. published_at records the
+// first time the course went live.
+func (c *client) SetCourseStatus(courseID int, status string, publishAt *time.Time) (model.Course, error) {
+	query := `
+		UPDATE courses
+		SET status = $2, publish_at = $3,
+		    published_at = CASE WHEN $4 THEN COALESCE(published_at, CURRENT_TIMESTAMP) ELSE published_at END
+		WHERE id = $1
+		RETURNING ` + courseColumns
+
+	course, err := scanCourse(c.db.QueryRow(query, courseID, status, publishAt, status == model.PublicationPublished))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Course{}, fmt.Errorf("no course found with id %d: %w", courseID, ErrNotFound)
+		}
+		return model.Course{}, fmt.Errorf("updating status of course %d: %w", courseID, err)
+	}
+
+	return course, nil
+}
+
+// SetTrainingStatus moves a training through its publication lifecycle the
+// same way SetCourseStatus does for courses.
+func (c *client) SetTrainingStatus(trainingID int, status string, publishAt *time.Time) (model.Training, error) {
+	query := `
+		UPDATE trainings
+		SET status = $2, publish_at = $3,
+		    published_at = CASE WHEN $4 THEN COALESCE(published_at, CURRENT_TIMESTAMP) ELSE published_at END
+		WHERE id = $1
+		RETURNING ` + trainingColumns
+
+	training, err := scanTraining(c.db.QueryRow(query, trainingID, status, publishAt, status == model.PublicationPublished))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.Training{}, fmt.Errorf("no training found with id %d: %w", trainingID, ErrNotFound)
+		}
+		return model.Training{}, fmt.Errorf("updating status of training %d: %w", trainingID, err)
+	}
+
+	return training, nil
+}
+
+// PublishScheduled puts live the courses and trainings whose publish time
+// has come, dating their publication to the time they were scheduled for.
+func (c *client) PublishScheduled(now time.Time) error {
+	for _, table := range []string{"courses", "trainings"} {
+		query := `
+			UPDATE ` + table + `
+			SET status = 'published', published_at = COALESCE(published_at, publish_at), publish_at = NULL
+			WHERE status = 'scheduled' AND publish_at <= $1`
+
+		if _, err := c.db.Exec(query, now); err != nil {
+			return fmt.Errorf("publishing scheduled %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// ClaimUnannouncedCourses marks the published courses students were not told
+// about yet as announced and returns them. Marking them in the same statement
+// keeps two runs from announcing a course twice.
+func (c *client) ClaimUnannouncedCourses() ([]model.Course, error) {
+	query := `
+		UPDATE courses SET announced_at = CURRENT_TIMESTAMP
+		WHERE status = 'published' AND announced_at IS NULL
+		RETURNING ` + courseColumns
+
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("claiming unannounced courses: %w", err)
+	}
+	defer rows.Close()
+
+	var courses []model.Course
+	for rows.Next() {
+		course, err := scanCourse(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning course: %w", err)
+		}
+		courses = append(courses, course)
+	}
+
+	return courses, rows.Err()
+}
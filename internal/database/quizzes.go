@@ -0,0 +1,216 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+// SaveQuiz creates or replaces the quiz of a training. Questions and options
+// are rewritten as a whole, so their ids change on every save.
+func (c *client) SaveQuiz(quiz model.Quiz) (*model.Quiz, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO quizzes (training_id, passing_score, max_attempts, required_for_completion)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (training_id) DO UPDATE
+		SET passing_score = EXCLUDED.passing_score,
+		    max_attempts = EXCLUDED.max_attempts,
+		    required_for_completion = EXCLUDED.required_for_completion
+		RETURNING id`,
+		quiz.TrainingID, quiz.PassingScore, quiz.MaxAttempts, quiz.RequiredForCompletion,
+	).Scan(&quiz.ID)
+	if err != nil {
+		return nil, fmt.Errorf("saving quiz: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM quiz_questions WHERE quiz_id = $1`, quiz.ID); err != nil {
+		return nil, fmt.Errorf("removing previous questions: %w", err)
+	}
+
+	for i := range quiz.Questions {
+		question := &quiz.Questions[i]
+		err := tx.QueryRow(`
+			INSERT INTO quiz_questions (quiz_id, position, kind, prompt, points, correct_number, tolerance)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+			quiz.ID, i, question.Kind, question.Prompt, question.Points, question.CorrectNumber, question.Tolerance,
+		).Scan(&question.ID)
+		if err != nil {
+			return nil, fmt.Errorf("saving question %d: %w", i+1, err)
+		}
+
+		for j := range question.Options {
+			option := &question.Options[j]
+			err := tx.QueryRow(`
+				INSERT INTO quiz_options (question_id, position, text, correct)
+				VALUES ($1, $2, $3, $4)
+				RETURNING id`,
+				question.ID, j, option.Text, option.Correct,
+			).Scan(&option.ID)
+			if err != nil {
+				return nil, fmt.Errorf("saving option %d of question %d: %w", j+1, i+1, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing quiz: %w", err)
+	}
+
+	return &quiz, nil
+}
+
+func (c *client) GetQuizByTraining(trainingID int) (*model.Quiz, error) {
+	var quiz model.Quiz
+	err := c.db.QueryRow(
+		`SELECT id, training_id, passing_score, max_attempts, required_for_completion FROM quizzes WHERE training_id = $1`,
+		trainingID,
+	).Scan(&quiz.ID, &quiz.TrainingID, &quiz.PassingScore, &quiz.MaxAttempts, &quiz.RequiredForCompletion)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no quiz found for training %d: %w", trainingID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying quiz: %w", err)
+	}
+
+	rows, err := c.db.Query(`
+		SELECT q.id, q.kind, q.prompt, q.points, q.correct_number, q.tolerance, o.id, o.text, o.correct
+		FROM quiz_questions q
+		LEFT JOIN quiz_options o ON o.question_id = q.id
+		WHERE q.quiz_id = $1
+		ORDER BY q.position, o.position`, quiz.ID)
+	if err != nil {
+		return nil, fmt.Errorf("querying quiz questions: %w", err)
+	}
+	defer rows.Close()
+
+	quiz.Questions = []model.QuizQuestion{}
+	for rows.Next() {
+		var question model.QuizQuestion
+		var correctNumber sql.NullFloat64
+		var optionID sql.NullInt64
+		var optionText sql.NullString
+		var optionCorrect sql.NullBool
+		err := rows.Scan(&question.ID, &question.Kind, &question.Prompt, &question.Points, &correctNumber, &question.Tolerance,
+			&optionID, &optionText, &optionCorrect)
+		if err != nil {
+			return nil, fmt.Errorf("scanning quiz question: %w", err)
+		}
+
+		last := len(quiz.Questions) - 1
+		if last < 0 || quiz.Questions[last].ID != question.ID {
+			if correctNumber.Valid {
+				question.CorrectNumber = &correctNumber.Float64
+			}
+			quiz.Questions = append(quiz.Questions, question)
+			last++
+		}
+
+		if optionID.Valid {
+			quiz.Questions[last].Options = append(quiz.Questions[last].Options, model.QuizOption{
+				ID:      int(optionID.Int64),
+				Text:    optionText.String,
+				Correct: optionCorrect.Bool,
+			})
+		}
+	}
+
+	return &quiz, rows.Err()
+}
+
+// AddQuizAttempt stores an attempt unless the user already made
+// maxAttempts of them, in which case it fails with ErrConflict. Zero allows
+// any number of attempts. Attempts of the same user at the same quiz are
+// serialized so concurrent ones can't go over the limit.
+func (c *client) AddQuizAttempt(attempt model.QuizAttempt, maxAttempts int) (*model.QuizAttempt, error) {
+	answers, err := json.Marshal(attempt.Answers)
+	if err != nil {
+		return nil, fmt.Errorf("encoding answers: %w", err)
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if maxAttempts > 0 {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, attempt.QuizID, attempt.UserID); err != nil {
+			return nil, fmt.Errorf("locking quiz attempts: %w", err)
+		}
+
+		var count int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM quiz_attempts WHERE quiz_id = $1 AND user_id = $2`,
+			attempt.QuizID, attempt.UserID).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("counting quiz attempts: %w", err)
+		}
+		if count >= maxAttempts {
+			return nil, fmt.Errorf("user %d has no attempts left at quiz %d: %w", attempt.UserID, attempt.QuizID, ErrConflict)
+		}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO quiz_attempts (quiz_id, user_id, score, passed, answers)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, submitted_at`,
+		attempt.QuizID, attempt.UserID, attempt.Score, attempt.Passed, string(answers),
+	).Scan(&attempt.ID, &attempt.SubmittedAt)
+	if err != nil {
+		return nil, fmt.Errorf("storing quiz attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing quiz attempt: %w", err)
+	}
+
+	return &attempt, nil
+}
+
+func (c *client) GetQuizAttempts(quizID, userID int) ([]model.QuizAttempt, error) {
+	rows, err := c.db.Query(`
+		SELECT id, quiz_id, user_id, score, passed, answers, submitted_at
+		FROM quiz_attempts
+		WHERE quiz_id = $1 AND user_id = $2
+		ORDER BY submitted_at`, quizID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying quiz attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []model.QuizAttempt{}
+	for rows.Next() {
+		var attempt model.QuizAttempt
+		var answers []byte
+		err := rows.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &attempt.Score, &attempt.Passed, &answers, &attempt.SubmittedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scanning quiz attempt: %w", err)
+		}
+		if err := json.Unmarshal(answers, &attempt.Answers); err != nil {
+			return nil, fmt.Errorf("decoding answers: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+func (c *client) HasPassedQuiz(quizID, userID int) (bool, error) {
+	var passed bool
+	err := c.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM quiz_attempts WHERE quiz_id = $1 AND user_id = $2 AND passed)`,
+		quizID, userID,
+	).Scan(&passed)
+	if err != nil {
+		return false, fmt.Errorf("checking passed quiz: %w", err)
+	}
+
+	return passed, nil
+}
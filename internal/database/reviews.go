@@ -0,0 +1,247 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/listing"
+	"game-student-go/internal/model"
+	"strings"
+)
+
+const reviewColumns = `id, course_id, user_id, rating, body, helpful_count, report_count, hidden_at IS NOT NULL,
+	reply, reply_by, replied_at, created_at, updated_at`
+
+func scanReview(row rowScanner) (*model.Review, error) {
+	var review model.Review
+	var reply sql.NullString
+	var replyBy sql.NullInt64
+	var repliedAt sql.NullTime
+	err := row.Scan(&review.ID, &review.CourseID, &review.UserID, &review.Rating, &review.Body, &review.HelpfulCount,
+		&review.ReportCount, &review.Hidden, &reply, &replyBy, &repliedAt, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Valid {
+		review.Reply = &model.ReviewReply{Body: reply.String, RepliedAt: repliedAt.Time}
+		if replyBy.Valid {
+			id := int(replyBy.Int64)
+			review.Reply.InstructorID = &id
+		}
+	}
+
+	return &review, nil
+}
+
+func (c *client) CreateReview(review model.Review) (*model.Review, error) {
+	query := `
+		INSERT INTO course_reviews (course_id, user_id, rating, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + reviewColumns
+
+	created, err := scanReview(c.db.QueryRow(query, review.CourseID, review.UserID, review.Rating, review.Body))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("review of course %d by user %d: %w", review.CourseID, review.UserID, ErrConflict)
+		}
+		return nil, fmt.Errorf("creating review: %w", err)
+	}
+
+	return created, nil
+}
+
+func (c *client) UpdateReview(id, rating int, body string) (*model.Review, error) {
+	query := `
+		UPDATE course_reviews SET rating = $2, body = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + reviewColumns
+
+	return c.returnReview(id, "updating review", query, id, rating, body)
+}
+
+func (c *client) GetReview(id int) (*model.Review, error) {
+	return c.returnReview(id, "querying review", `SELECT `+reviewColumns+` FROM course_reviews WHERE id = $1`, id)
+}
+
+// returnReview runs a query returning the review with the given id.
+func (c *client) returnReview(id int, action, query string, args ...interface{}) (*model.Review, error) {
+	review, err := scanReview(c.db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no review found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", action, err)
+	}
+
+	return review, nil
+}
+
+// reviewSorts maps the sort fields accepted by GetCourseReviews to their
+// columns.
+var reviewSorts = map[string]string{
+	"helpful":    "helpful_count",
+	"created_at": "created_at",
+}
+
+// ReviewSorts lists the fields reviews can be sorted by, the default first.
+var ReviewSorts = []string{"helpful", "created_at"}
+
+// GetCourseReviews returns one page of the visible reviews of a course,
+// fetching one row past the limit like GetCourses.
+func (c *client) GetCourseReviews(courseID int, page listing.Params) ([]model.Review, error) {
+	column, ok := reviewSorts[page.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown review sort: %s", page.Sort)
+	}
+
+	conditions := []string{"course_id = $1", "hidden_at IS NULL"}
+	keyset, orderBy, args := page.Keyset(column, "id", []interface{}{courseID})
+	if keyset != "" {
+		conditions = append(conditions, keyset)
+	}
+	args = append(args, page.Limit+1)
+
+	query := fmt.Sprintf(`SELECT %s FROM course_reviews WHERE %s ORDER BY %s LIMIT $%d`,
+		reviewColumns, strings.Join(conditions, " AND "), orderBy, len(args))
+
+	return c.queryReviews(query, args...)
+}
+
+func (c *client) queryReviews(query string, args ...interface{}) ([]model.Review, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var reviews []model.Review
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning review: %w", err)
+		}
+		reviews = append(reviews, *review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// SetReviewHelpful records or withdraws a user's helpful vote, keeping the
+// review's helpful_count in step. Repeated votes count once.
+func (c *client) SetReviewHelpful(reviewID, userID int, helpful bool) (*model.Review, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	vote := `INSERT INTO review_votes (review_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	delta := 1
+	if !helpful {
+		vote = `DELETE FROM review_votes WHERE review_id = $1 AND user_id = $2`
+		delta = -1
+	}
+
+	result, err := tx.Exec(vote, reviewID, userID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("no review found with id %d: %w", reviewID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("voting on review: %w", err)
+	}
+
+	if changed, _ := result.RowsAffected(); changed == 0 {
+		delta = 0
+	}
+
+	query := `UPDATE course_reviews SET helpful_count = helpful_count + $2 WHERE id = $1 RETURNING ` + reviewColumns
+	review, err := scanReview(tx.QueryRow(query, reviewID, delta))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no review found with id %d: %w", reviewID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("counting helpful votes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing vote: %w", err)
+	}
+
+	return review, nil
+}
+
+// ReportReview flags a review for moderation. Each user reports a review
+// once.
+func (c *client) ReportReview(reviewID, userID int, reason string) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO review_reports (review_id, user_id, reason) VALUES ($1, $2, $3)`, reviewID, userID, reason)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("report of review %d by user %d: %w", reviewID, userID, ErrConflict)
+		}
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("no review found with id %d: %w", reviewID, ErrNotFound)
+		}
+		return fmt.Errorf("reporting review: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE course_reviews SET report_count = report_count + 1 WHERE id = $1`, reviewID); err != nil {
+		return fmt.Errorf("counting reports: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing report: %w", err)
+	}
+
+	return nil
+}
+
+// GetReportedReviews lists the visible reviews with reports, most reported
+// first.
+func (c *client) GetReportedReviews() ([]model.Review, error) {
+	query := `SELECT ` + reviewColumns + ` FROM course_reviews WHERE report_count > 0 AND hidden_at IS NULL ORDER BY report_count DESC, id`
+	reviews, err := c.queryReviews(query)
+	if reviews == nil && err == nil {
+		reviews = []model.Review{}
+	}
+
+	return reviews, err
+}
+
+// SetReviewHidden hides a review from the course page and its rating, or
+// shows it again.
+func (c *client) SetReviewHidden(id int, hidden bool) (*model.Review, error) {
+	query := `
+		UPDATE course_reviews SET hidden_at = CASE WHEN $2 THEN COALESCE(hidden_at, CURRENT_TIMESTAMP) END
+		WHERE id = $1
+		RETURNING ` + reviewColumns
+
+	return c.returnReview(id, "hiding review", query, id, hidden)
+}
+
+// ReplyToReview sets the instructor reply of a review. A review takes one
+// reply: only the instructor who wrote it may change it afterwards.
+func (c *client) ReplyToReview(reviewID, instructorID int, body string) (*model.Review, error) {
+	query := `
+		UPDATE course_reviews SET reply = $3, reply_by = $2, replied_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (reply IS NULL OR reply_by = $2)
+		RETURNING ` + reviewColumns
+
+	review, err := scanReview(c.db.QueryRow(query, reviewID, instructorID, body))
+	if err == sql.ErrNoRows {
+		if _, err := c.GetReview(reviewID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("review %d was already replied to: %w", reviewID, ErrConflict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("replying to review: %w", err)
+	}
+
+	return review, nil
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+	"game-student-go/internal/model"
+	"html"
+	"strings"
+)
+
+// ts_headline marks matches with these private use characters rather than
+// <mark> tags, so the text around them can be escaped first.
+const (
+	startSel = "\ue000"
+	stopSel  = "\ue001"
+)
+
+const headlineOptions = `StartSel=` + startSel + `, StopSel=` + stopSel + `, MaxFragments=2, MaxWords=25, MinWords=5`
+
+var highlighter = strings.NewReplacer(startSel, "<mark>", stopSel, "</mark>")
+
+// highlight turns text from the catalog into HTML, with the matches marked
+// by ts_headline in <mark> tags.
+func highlight(text string) string {
+	return highlighter.Replace(html.EscapeString(text))
+}
+
+// SearchCatalog runs a full text search over the names and descriptions of
+// published courses and the names and topics of their published trainings,
+// best matches first. The query accepts web search syntax ("quoted phrases",
+// -excluded, or).
+func (c *client) SearchCatalog(query string, limit int) ([]model.SearchResult, error) {
+	sql := `
+		WITH q AS (
+			SELECT websearch_to_tsquery('portuguese', $1) || websearch_to_tsquery('english', $1) AS query
+		)
+		SELECT 'course', c.id, c.id,
+		       ts_headline('portuguese', coalesce(c.name, ''), q.query, 'HighlightAll=true, ` + headlineOptions + `'),
+		       ts_headline('portuguese', coalesce(c.description, ''), q.query, '` + headlineOptions + `'),
+		       ts_rank(c.search_vector, q.query) AS rank
+		FROM courses c, q
+		WHERE c.search_vector @@ q.query AND c.status = 'published'
+		UNION ALL
+		SELECT 'training', t.id, t.course_id,
+		       ts_headline('portuguese', t.name, q.query, 'HighlightAll=true, ` + headlineOptions + `'),
+		       ts_headline('portuguese', t.topic, q.query, '` + headlineOptions + `'),
+		       ts_rank(t.search_vector, q.query) AS rank
+		FROM trainings t JOIN courses tc ON tc.id = t.course_id, q
+		WHERE t.search_vector @@ q.query AND t.status = 'published' AND tc.status = 'published'
+		ORDER BY rank DESC
+		LIMIT $2`
+
+	return c.querySearchResults(sql, query, limit)
+}
+
+// FuzzySearchCatalog matches names by trigram similarity. It catches typos
+// that full text search misses, so it serves as its fallback.
+func (c *client) FuzzySearchCatalog(query string, limit int) ([]model.SearchResult, error) {
+	sql := `
+		SELECT 'course', id, id, coalesce(name, ''), coalesce(description, ''), similarity(name, $1) AS rank
+		FROM courses
+		WHERE name % $1 AND status = 'published'
+		UNION ALL
+		SELECT 'training', t.id, t.course_id, t.name, t.topic, similarity(t.name, $1) AS rank
+		FROM trainings t JOIN courses tc ON tc.id = t.course_id
+		WHERE t.name % $1 AND t.status = 'published' AND tc.status = 'published'
+		ORDER BY rank DESC
+		LIMIT $2`
+
+	return c.querySearchResults(sql, query, limit)
+}
+
+func (c *client) querySearchResults(sql, query string, limit int) ([]model.SearchResult, error) {
+	rows, err := c.db.Query(sql, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("searching catalog: %w", err)
+	}
+	defer rows.Close()
+
+	results := []model.SearchResult{}
+	for rows.Next() {
+		var result model.SearchResult
+		if err := rows.Scan(&result.Type, &result.ID, &result.CourseID, &result.Title, &result.Snippet, &result.Rank); err != nil {
+			return nil, fmt.Errorf("scanning search result: %w", err)
+		}
+		result.Title, result.Snippet = highlight(result.Title), highlight(result.Snippet)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+const submissionColumns = `id, user_id, training_id, url, attachment_key, status, feedback, reviewer_id, submitted_at, reviewed_at`
+
+func scanSubmission(row rowScanner) (*model.Submission, error) {
+	var submission model.Submission
+	var attachmentKey, feedback sql.NullString
+	var reviewerID sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&submission.ID, &submission.UserID, &submission.TrainingID, &submission.URL, &attachmentKey,
+		&submission.Status, &feedback, &reviewerID, &submission.SubmittedAt, &reviewedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	submission.AttachmentKey = attachmentKey.String
+	submission.HasAttachment = attachmentKey.Valid
+	submission.Feedback = feedback.String
+	if reviewerID.Valid {
+		id := int(reviewerID.Int64)
+		submission.ReviewerID = &id
+	}
+	if reviewedAt.Valid {
+		submission.ReviewedAt = &reviewedAt.Time
+	}
+
+	return &submission, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// CreateSubmission stores the work a student submits for a training, with
+// the key of its uploaded attachment if there is one.
+func (c *client) CreateSubmission(userID, trainingID int, url, attachmentKey string) (*model.Submission, error) {
+	query := `
+		INSERT INTO submissions (user_id, training_id, url, attachment_key)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + submissionColumns
+
+	submission, err := scanSubmission(c.db.QueryRow(query, userID, trainingID, url, nullString(attachmentKey)))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("training %d was already submitted: %w", trainingID, ErrConflict)
+		}
+		return nil, fmt.Errorf("creating submission: %w", err)
+	}
+
+	return submission, nil
+}
+
+// ResubmitSubmission replaces the submitted work and sends it back to the
+// review queue. The previous feedback is kept so the student can still read it,
+// and so is the previous attachment unless a new one is given.
+func (c *client) ResubmitSubmission(id int, url, attachmentKey string) (*model.Submission, error) {
+	query := `
+		UPDATE submissions
+		SET url = $2, attachment_key = COALESCE($3, attachment_key), status = 'pending',
+		    submitted_at = CURRENT_TIMESTAMP, reviewed_at = NULL
+		WHERE id = $1
+		RETURNING ` + submissionColumns
+
+	submission, err := scanSubmission(c.db.QueryRow(query, id, url, nullString(attachmentKey)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no submission found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("resubmitting submission: %w", err)
+	}
+
+	return submission, nil
+}
+
+func (c *client) GetSubmission(id int) (*model.Submission, error) {
+	query := `SELECT ` + submissionColumns + ` FROM submissions WHERE id = $1`
+
+	submission, err := scanSubmission(c.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no submission found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying submission by id: %w", err)
+	}
+
+	return submission, nil
+}
+
+func (c *client) GetSubmissionsByUser(userID int) ([]model.Submission, error) {
+	query := `SELECT ` + submissionColumns + ` FROM submissions WHERE user_id = $1 ORDER BY submitted_at DESC`
+	return c.querySubmissions(query, userID)
+}
+
+// GetSubmissionsByStatus lists submissions oldest first, which is the order
+// instructors work through the review queue.
+func (c *client) GetSubmissionsByStatus(status string) ([]model.Submission, error) {
+	query := `SELECT ` + submissionColumns + ` FROM submissions WHERE status = $1 ORDER BY submitted_at`
+	return c.querySubmissions(query, status)
+}
+
+func (c *client) querySubmissions(query string, args ...interface{}) ([]model.Submission, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying submissions: %w", err)
+	}
+	defer rows.Close()
+
+	submissions := []model.Submission{}
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning submission: %w", err)
+		}
+		submissions = append(submissions, *submission)
+	}
+
+	return submissions, rows.Err()
+}
+
+func (c *client) ReviewSubmission(id, reviewerID int, status, feedback string) (*model.Submission, error) {
+	query := `
+		UPDATE submissions
+		SET status = $2, feedback = $3, reviewer_id = $4, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + submissionColumns
+
+	submission, err := scanSubmission(c.db.QueryRow(query, id, status, nullString(feedback), reviewerID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no submission found with id %d: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("reviewing submission: %w", err)
+	}
+
+	return submission, nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"strings"
+)
+
+func (c *client) GetCategories() ([]model.Category, error) {
+	rows, err := c.db.Query(`SELECT id, slug, name FROM categories ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []model.Category{}
+	for rows.Next() {
+		var category model.Category
+		if err := rows.Scan(&category.ID, &category.Slug, &category.Name); err != nil {
+			return nil, fmt.Errorf("scanning category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+func (c *client) CreateCategory(category model.Category) (*model.Category, error) {
+	err := c.db.QueryRow(`INSERT INTO categories (slug, name) VALUES ($1, $2) RETURNING id`, category.Slug, category.Name).Scan(&category.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("category %s: %w", category.Slug, ErrConflict)
+		}
+		return nil, fmt.Errorf("creating category: %w", err)
+	}
+
+	return &category, nil
+}
+
+func (c *client) UpdateCategory(category model.Category) (*model.Category, error) {
+	result, err := c.db.Exec(`UPDATE categories SET slug = $2, name = $3 WHERE id = $1`, category.ID, category.Slug, category.Name)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("category %s: %w", category.Slug, ErrConflict)
+		}
+		return nil, fmt.Errorf("updating category: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return nil, fmt.Errorf("no category found with id %d: %w", category.ID, ErrNotFound)
+	}
+
+	return &category, nil
+}
+
+// DeleteCategory removes a category. Its courses stay, uncategorized.
+func (c *client) DeleteCategory(id int) error {
+	result, err := c.db.Exec(`DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting category: %w", err)
+	}
+
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("no category found with id %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (c *client) GetTags() ([]string, error) {
+	rows, err := c.db.Query(`SELECT name FROM tags ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("querying tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("scanning tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// SetCourseTaxonomy replaces the category, difficulty and tags of a course.
+// Tags are free form and created the first time they are used.
+func (c *client) SetCourseTaxonomy(courseID int, categoryID *int, difficulty string, tags []string) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE courses SET category_id = $2, difficulty = $3 WHERE id = $1`, courseID, categoryID, nullString(difficulty))
+	if isForeignKeyViolation(err) {
+		return fmt.Errorf("no category found with id %d: %w", *categoryID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("updating course taxonomy: %w", err)
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return fmt.Errorf("no course found with id %d: %w", courseID, ErrNotFound)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM course_tags WHERE course_id = $1`, courseID); err != nil {
+		return fmt.Errorf("removing course tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		var tagID int
+		err := tx.QueryRow(`
+			INSERT INTO tags (name) VALUES ($1)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id`, tag).Scan(&tagID)
+		if err != nil {
+			return fmt.Errorf("saving tag %s: %w", tag, err)
+		}
+
+		if _, err := tx.Exec(`INSERT INTO course_tags (course_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, courseID, tagID); err != nil {
+			return fmt.Errorf("tagging course: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing course taxonomy: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) GetCourseFacets(filter model.CourseFilter) (model.CourseFacets, error) {
+	var facets model.CourseFacets
+	var err error
+
+	withoutCategory := filter
+	withoutCategory.Category = ""
+	facets.Categories, err = c.countFacet(`SELECT cat.slug, COUNT(*) FROM courses JOIN categories cat ON cat.id = courses.category_id`,
+		"cat.slug", withoutCategory)
+	if err != nil {
+		return model.CourseFacets{}, err
+	}
+
+	withoutTags := filter
+	withoutTags.Tags = nil
+	facets.Tags, err = c.countFacet(`SELECT tg.name, COUNT(*) FROM courses JOIN course_tags ct ON ct.course_id = courses.id JOIN tags tg ON tg.id = ct.tag_id`,
+		"tg.name", withoutTags)
+	if err != nil {
+		return model.CourseFacets{}, err
+	}
+
+	withoutDifficulty := filter
+	withoutDifficulty.Difficulty = ""
+	facets.Difficulties, err = c.countFacet(`SELECT courses.difficulty, COUNT(*) FROM courses`,
+		"courses.difficulty", withoutDifficulty, "courses.difficulty IS NOT NULL")
+	if err != nil {
+		return model.CourseFacets{}, err
+	}
+
+	return facets, nil
+}
+
+func (c *client) countFacet(selectFrom, groupBy string, filter model.CourseFilter, extra ...string) ([]model.FacetCount, error) {
+	conditions, args := courseConditions(filter, nil)
+	conditions = append(conditions, extra...)
+
+	query := selectFrom
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " GROUP BY " + groupBy + " ORDER BY COUNT(*) DESC, " + groupBy
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("counting %s facet: %w", groupBy, err)
+	}
+	defer rows.Close()
+
+	counts := []model.FacetCount{}
+	for rows.Next() {
+		var count model.FacetCount
+		var value sql.NullString
+		if err := rows.Scan(&value, &count.Count); err != nil {
+			return nil, fmt.Errorf("scanning %s facet: %w", groupBy, err)
+		}
+		count.Value = value.String
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
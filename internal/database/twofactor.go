@@ -0,0 +1,239 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+	"github.com/lib/pq"
+	"time"
+)
+
+// StartTwoFactorEnrollment stores a new TOTP secret and recovery codes for
+// a user whose 2FA is off, replacing any unfinished enrollment. Users with
+// 2FA on get ErrConflict.
+func (c *client) StartTwoFactorEnrollment(userID int, secret string, recoveryCodeHashes []string) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var enabled bool
+	err = tx.QueryRow(`SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&enabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)
+		}
+		return fmt.Errorf("querying two-factor state: %w", err)
+	}
+	if enabled {
+		return fmt.Errorf("user %d already has two-factor authentication on: %w", userID, ErrConflict)
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET totp_secret = $2, totp_last_step = 0 WHERE id = $1`, userID, secret); err != nil {
+		return fmt.Errorf("saving totp secret: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("removing recovery codes: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`,
+		userID, pq.Array(recoveryCodeHashes))
+	if err != nil {
+		return fmt.Errorf("saving recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing two-factor enrollment: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) GetTwoFactor(userID int) (*model.TwoFactor, error) {
+	twoFactor := model.TwoFactor{UserID: userID}
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	err := c.db.QueryRow(`SELECT totp_secret, totp_enabled_at, totp_last_step FROM users WHERE id = $1`, userID).
+		Scan(&secret, &enabledAt, &twoFactor.LastStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("querying two-factor state: %w", err)
+	}
+
+	if !secret.Valid {
+		return nil, fmt.Errorf("user %d has not enrolled in two-factor authentication: %w", userID, ErrNotFound)
+	}
+	twoFactor.Secret = secret.String
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+
+	return &twoFactor, nil
+}
+
+// EnableTwoFactor turns 2FA on once the first code of an enrollment was
+// checked, recording the step of that code as used.
+func (c *client) EnableTwoFactor(userID int, step int64) error {
+	result, err := c.db.Exec(`
+		UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_step = $2
+		WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`,
+		userID, step)
+	if err != nil {
+		return fmt.Errorf("enabling two-factor authentication: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("user %d has no pending two-factor enrollment: %w", userID, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (c *client) DisableTwoFactor(userID int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("disabling two-factor authentication: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("removing recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing two-factor removal: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the step of an accepted code. It reports false when
+// that step or a later one was already used, so each code works once even
+// under concurrent requests.
+func (c *client) UseTOTPStep(userID int, step int64) (bool, error) {
+	result, err := c.db.Exec(`UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("recording totp step: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("recording totp step: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+// UseRecoveryCode spends a recovery code, reporting false when it does not
+// exist or was already used.
+func (c *client) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	result, err := c.db.Exec(`
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("using recovery code: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("using recovery code: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (c *client) CreateTwoFactorChallenge(userID int, tokenHash string, expiresAt time.Time) error {
+	// Expired challenges are only ever looked up by their owner, so this is
+	// a good time to drop them
+	if _, err := c.db.Exec(`DELETE FROM two_factor_challenges WHERE user_id = $1 AND expires_at <= CURRENT_TIMESTAMP`, userID); err != nil {
+		return fmt.Errorf("removing expired challenges: %w", err)
+	}
+
+	_, err := c.db.Exec(`INSERT INTO two_factor_challenges (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`,
+		tokenHash, userID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("creating two-factor challenge: %w", err)
+	}
+
+	return nil
+}
+
+// AttemptTwoFactorChallenge counts an attempt at answering a challenge and
+// returns the user it belongs to. Expired challenges and those out of
+// attempts are reported as ErrNotFound.
+func (c *client) AttemptTwoFactorChallenge(tokenHash string, maxAttempts int) (int, error) {
+	var userID int
+	err := c.db.QueryRow(`
+		UPDATE two_factor_challenges SET attempts = attempts + 1
+		WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP AND attempts < $2
+		RETURNING user_id`,
+		tokenHash, maxAttempts).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("no valid two-factor challenge: %w", ErrNotFound)
+		}
+		return 0, fmt.Errorf("attempting two-factor challenge: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (c *client) DeleteTwoFactorChallenge(tokenHash string) error {
+	if _, err := c.db.Exec(`DELETE FROM two_factor_challenges WHERE token_hash = $1`, tokenHash); err != nil {
+		return fmt.Errorf("deleting two-factor challenge: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) GetTwoFactorRoles() ([]string, error) {
+	rows, err := c.db.Query(`SELECT role FROM two_factor_roles ORDER BY role`)
+	if err != nil {
+		return nil, fmt.Errorf("querying two-factor roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("scanning two-factor role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// SetTwoFactorRoles replaces the set of roles that need 2FA.
+func (c *client) SetTwoFactorRoles(roles []string) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM two_factor_roles`); err != nil {
+		return fmt.Errorf("clearing two-factor roles: %w", err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO two_factor_roles (role) SELECT DISTINCT unnest($1::text[])`, pq.Array(roles)); err != nil {
+		return fmt.Errorf("saving two-factor roles: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing two-factor roles: %w", err)
+	}
+
+	return nil
+}
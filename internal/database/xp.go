@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"game-student-go/internal/model"
+)
+
+// GrantXP records a grant in the ledger and adds it to the user's total. A
+// source is only rewarded once per reason, so repeated grants report false
+// and change nothing.
+func (c *client) GrantXP(grant model.XPGrant) (bool, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO xp_ledger (user_id, amount, reason, source_id, course_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+		ON CONFLICT (user_id, reason, source_id) DO NOTHING`,
+		grant.UserID, grant.Amount, grant.Reason, grant.SourceID, grant.CourseID)
+	if err != nil {
+		return false, fmt.Errorf("recording xp grant: %w", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("recording xp grant: %w", err)
+	}
+	if inserted == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_xp (user_id, total_xp)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET total_xp = user_xp.total_xp + EXCLUDED.total_xp, updated_at = CURRENT_TIMESTAMP`,
+		grant.UserID, grant.Amount)
+	if err != nil {
+		return false, fmt.Errorf("updating xp total: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("committing xp grant: %w", err)
+	}
+
+	return true, nil
+}
+
+func (c *client) GetUserXP(userID int) (int, error) {
+	var total int
+	err := c.db.QueryRow(`SELECT total_xp FROM user_xp WHERE user_id = $1`, userID).Scan(&total)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("querying xp total: %w", err)
+	}
+
+	return total, nil
+}
+
+func (c *client) GetXPLedger(userID int) ([]model.XPGrant, error) {
+	rows, err := c.db.Query(`
+		SELECT id, user_id, amount, reason, source_id, COALESCE(course_id, 0), created_at
+		FROM xp_ledger
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying xp ledger: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []model.XPGrant{}
+	for rows.Next() {
+		var grant model.XPGrant
+		if err := rows.Scan(&grant.ID, &grant.UserID, &grant.Amount, &grant.Reason, &grant.SourceID, &grant.CourseID, &grant.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning xp grant: %w", err)
+		}
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// RecomputeXP rebuilds every user's total from the ledger. Users left
+// without entries go back to zero.
+func (c *client) RecomputeXP() error {
+	_, err := c.db.Exec(`
+		INSERT INTO user_xp (user_id, total_xp)
+		SELECT users.id, COALESCE(SUM(xp_ledger.amount), 0)
+		FROM users LEFT JOIN xp_ledger ON xp_ledger.user_id = users.id
+		GROUP BY users.id
+		ON CONFLICT (user_id) DO UPDATE
+		SET total_xp = EXCLUDED.total_xp, updated_at = CURRENT_TIMESTAMP`)
+	if err != nil {
+		return fmt.Errorf("recomputing xp totals: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) CountCertificatesByUser(userID int) (int, error) {
+	var count int
+	err := c.db.QueryRow(`SELECT COUNT(*) FROM certificates WHERE user_id = $1 AND revoked_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting certificates: %w", err)
+	}
+
+	return count, nil
+}
+
+func (c *client) AwardBadge(userID int, badge string) error {
+	_, err := c.db.Exec(`INSERT INTO user_badges (user_id, badge) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, badge)
+	if err != nil {
+		return fmt.Errorf("awarding badge %s: %w", badge, err)
+	}
+
+	return nil
+}
+
+func (c *client) GetUserBadges(userID int) ([]model.UserBadge, error) {
+	rows, err := c.db.Query(`SELECT user_id, badge, awarded_at FROM user_badges WHERE user_id = $1 ORDER BY awarded_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying badges: %w", err)
+	}
+	defer rows.Close()
+
+	badges := []model.UserBadge{}
+	for rows.Next() {
+		var badge model.UserBadge
+		if err := rows.Scan(&badge.UserID, &badge.Badge, &badge.AwardedAt); err != nil {
+			return nil, fmt.Errorf("scanning badge: %w", err)
+		}
+		badges = append(badges, badge)
+	}
+
+	return badges, rows.Err()
+}
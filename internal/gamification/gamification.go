@@ -0,0 +1,181 @@
+package gamification
+
+import (
+	"game-student-go/internal/model"
+	"sort"
+	"time"
+)
+
+// Reasons recorded in the XP ledger together with the XP they grant.
+const (
+	ReasonTrainingCompleted  = "training_completed"
+	ReasonQuizPassed         = "quiz_passed"
+	ReasonSubmissionApproved = "submission_approved"
+)
+
+var xpByReason = map[string]int{
+	ReasonTrainingCompleted:  10,
+	ReasonQuizPassed:         20,
+	ReasonSubmissionApproved: 50,
+}
+
+// XPFor returns the XP granted for a reason, or 0 for unknown reasons.
+func XPFor(reason string) int {
+	return xpByReason[reason]
+}
+
+type Level struct {
+	Level        int `json:"level"`
+	XP           int `json:"xp"`
+	LevelStartXP int `json:"level_start_xp"`
+	NextLevelXP  int `json:"next_level_xp"`
+}
+
+// LevelFor computes the level reached with the given XP. Every level costs
+// 100 XP more than the previous one: level 2 starts at 100 XP, level 3 at
+// 300, level 4 at 600 and so on.
+func LevelFor(xp int) Level {
+	level := Level{Level: 1, XP: xp, NextLevelXP: 100}
+	for xp >= level.NextLevelXP {
+		level.Level++
+		level.LevelStartXP = level.NextLevelXP
+		level.NextLevelXP += 100 * level.Level
+	}
+
+	return level
+}
+
+type Badge struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Stats are the facts about a student that badge rules look at.
+type Stats struct {
+	CoursesCompleted int
+	ActiveDays       []time.Time
+}
+
+type rule struct {
+	Badge
+	earned func(Stats) bool
+}
+
+var rules = []rule{
+	{
+		Badge:  Badge{Code: "first_course", Name: "Primeiro curso", Description: "Concluiu o primeiro curso"},
+		earned: func(s Stats) bool { return s.CoursesCompleted >= 1 },
+	},
+	{
+		Badge:  Badge{Code: "streak_7", Name: "Uma semana seguida", Description: "Estudou 7 dias seguidos"},
+		earned: func(s Stats) bool { return LongestStreak(s.ActiveDays) >= 7 },
+	},
+}
+
+// Badges lists every badge that can be earned.
+func Badges() []Badge {
+	badges := make([]Badge, len(rules))
+	for i, r := range rules {
+		badges[i] = r.Badge
+	}
+
+	return badges
+}
+
+// BadgeByCode looks up a badge definition.
+func BadgeByCode(code string) (Badge, bool) {
+	for _, r := range rules {
+		if r.Code == code {
+			return r.Badge, true
+		}
+	}
+
+	return Badge{}, false
+}
+
+// EarnedBadges returns the codes of every badge the stats qualify for.
+func EarnedBadges(stats Stats) []string {
+	var codes []string
+	for _, r := range rules {
+		if r.earned(stats) {
+			codes = append(codes, r.Code)
+		}
+	}
+
+	return codes
+}
+
+// LongestStreak returns the longest run of consecutive calendar days. Days
+// are compared by their date in their own location, in any order, and
+// duplicates are ignored.
+func LongestStreak(days []time.Time) int {
+	dates := distinctDates(days)
+
+	longest, current := 0, 0
+	for i, date := range dates {
+		if i > 0 && date.Sub(dates[i-1]) == 24*time.Hour {
+			current++
+		} else {
+			current = 1
+		}
+		if current > longest {
+			longest = current
+		}
+	}
+
+	return longest
+}
+
+func distinctDates(days []time.Time) []time.Time {
+	seen := make(map[time.Time]bool, len(days))
+	dates := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if !seen[date] {
+			seen[date] = true
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	return dates
+}
+
+// AllTimeSeason is the season start shared by all time rankings.
+var AllTimeSeason = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// SeasonStart returns the start of the season that is running at now for a
+// leaderboard period. Weekly seasons start on monday at midnight UTC.
+func SeasonStart(period string, now time.Time) time.Time {
+	if period != model.PeriodWeekly {
+		return AllTimeSeason
+	}
+
+	now = now.UTC()
+	daysSinceMonday := (int(now.Weekday()) + 6) % 7
+	return time.Date(now.Year(), now.Month(), now.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+}
+
+// CurrentStreak returns the run of consecutive days that ends today. A streak
+// that ended yesterday is still current, the student has until the end of
+// today to keep it going.
+func CurrentStreak(days []time.Time, today time.Time) int {
+	active := make(map[time.Time]bool, len(days))
+	for _, date := range distinctDates(days) {
+		active[date] = true
+	}
+
+	date := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if !active[date] {
+		date = date.AddDate(0, 0, -1)
+	}
+
+	streak := 0
+	for active[date] {
+		streak++
+		date = date.AddDate(0, 0, -1)
+	}
+
+	return streak
+}
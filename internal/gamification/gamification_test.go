@@ -0,0 +1,50 @@
+package gamification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelFor(t *testing.T) {
+	assert.Equal(t, Level{Level: 1, XP: 0, LevelStartXP: 0, NextLevelXP: 100}, LevelFor(0))
+	assert.Equal(t, Level{Level: 2, XP: 100, LevelStartXP: 100, NextLevelXP: 300}, LevelFor(100))
+	assert.Equal(t, Level{Level: 3, XP: 599, LevelStartXP: 300, NextLevelXP: 600}, LevelFor(599))
+	assert.Equal(t, 4, LevelFor(600).Level)
+}
+
+func day(d int) time.Time {
+	return time.Date(2023, 7, d, 15, 0, 0, 0, time.UTC)
+}
+
+func TestLongestStreak(t *testing.T) {
+	assert.Equal(t, 0, LongestStreak(nil))
+	assert.Equal(t, 3, LongestStreak([]time.Time{day(5), day(1), day(2), day(3), day(2), day(6)}))
+}
+
+func TestEarnedBadges(t *testing.T) {
+	assert.Empty(t, EarnedBadges(Stats{}))
+	assert.Equal(t, []string{"first_course"}, EarnedBadges(Stats{CoursesCompleted: 1}))
+
+	week := []time.Time{day(1), day(2), day(3), day(4), day(5), day(6), day(7)}
+	assert.Equal(t, []string{"streak_7"}, EarnedBadges(Stats{ActiveDays: week}))
+}
+
+func TestSeasonStart(t *testing.T) {
+	monday := time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, monday, SeasonStart("weekly", time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, monday, SeasonStart("weekly", time.Date(2023, 7, 9, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, monday.AddDate(0, 0, 7), SeasonStart("weekly", time.Date(2023, 7, 10, 8, 0, 0, 0, time.UTC)))
+	assert.Equal(t, AllTimeSeason, SeasonStart("all_time", time.Now()))
+}
+
+func TestCurrentStreak(t *testing.T) {
+	days := []time.Time{day(1), day(3), day(4), day(5)}
+
+	assert.Equal(t, 3, CurrentStreak(days, day(5)))
+	assert.Equal(t, 3, CurrentStreak(days, day(6)), "a streak is kept until the day is over")
+	assert.Equal(t, 0, CurrentStreak(days, day(7)))
+	assert.Equal(t, 0, CurrentStreak(nil, day(7)))
+}
@@ -0,0 +1,52 @@
+// Package jams holds the rules of game jam events.
+package jams
+
+import (
+	"game-student-go/internal/model"
+	"time"
+)
+
+// Phases a jam goes through, in order.
+const (
+	PhaseUpcoming = "upcoming"
+	PhaseRunning  = "running"
+	PhaseVoting   = "voting"
+	PhaseTallying = "tallying"
+	PhaseFinished = "finished"
+)
+
+const (
+	MinVoteScore   = 1
+	MaxVoteScore   = 5
+	MaxScreenshots = 5
+)
+
+// Phase tells where the jam stands at now. Teams form while the jam is
+// upcoming or running, entries are accepted while running and votes while
+// voting. Once voting ends the jam is tallying until its results are
+// published.
+func Phase(jam model.GameJam, now time.Time) string {
+	switch {
+	case jam.ResultsPublishedAt != nil:
+		return PhaseFinished
+	case now.Before(jam.ThemeRevealAt):
+		return PhaseUpcoming
+	case now.Before(jam.SubmissionDeadline):
+		return PhaseRunning
+	case now.Before(jam.VotingEndsAt):
+		return PhaseVoting
+	}
+
+	return PhaseTallying
+}
+
+// TeamsOpen tells whether teams can still be formed and changed.
+func TeamsOpen(phase string) bool {
+	return phase == PhaseUpcoming || phase == PhaseRunning
+}
+
+// EntriesPublic tells whether entries are shown to everyone, which happens
+// once submissions close.
+func EntriesPublic(phase string) bool {
+	return phase != PhaseUpcoming && phase != PhaseRunning
+}
@@ -0,0 +1,37 @@
+package jams
+
+import (
+	"game-student-go/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhase(t *testing.T) {
+	reveal := time.Date(2024, 5, 10, 18, 0, 0, 0, time.UTC)
+	jam := model.GameJam{
+		ThemeRevealAt:      reveal,
+		SubmissionDeadline: reveal.Add(48 * time.Hour),
+		VotingEndsAt:       reveal.Add(7 * 24 * time.Hour),
+	}
+
+	assert.Equal(t, PhaseUpcoming, Phase(jam, reveal.Add(-time.Minute)))
+	assert.Equal(t, PhaseRunning, Phase(jam, reveal))
+	assert.Equal(t, PhaseVoting, Phase(jam, reveal.Add(48*time.Hour)))
+	assert.Equal(t, PhaseTallying, Phase(jam, reveal.Add(7*24*time.Hour)))
+
+	published := reveal.Add(7*24*time.Hour + time.Minute)
+	jam.ResultsPublishedAt = &published
+	assert.Equal(t, PhaseFinished, Phase(jam, published))
+}
+
+func TestPhaseRules(t *testing.T) {
+	assert.True(t, TeamsOpen(PhaseUpcoming))
+	assert.True(t, TeamsOpen(PhaseRunning))
+	assert.False(t, TeamsOpen(PhaseVoting))
+
+	assert.False(t, EntriesPublic(PhaseRunning))
+	assert.True(t, EntriesPublic(PhaseVoting))
+	assert.True(t, EntriesPublic(PhaseFinished))
+}
@@ -0,0 +1,262 @@
+// Package jwtkeys holds the keys our tokens are signed with. Tokens carry
+// the ID of their key in the kid header, so several keys can verify at
+// once while only one signs, and the public halves are published as a JWKS
+// for other services.
+//
+// Keys are rotated in three steps: add the new key, so it is published and
+// trusted; once verifiers have refreshed their copy of the JWKS, make it the
+// signing key; once the tokens signed with the old key have expired, remove
+// the old key.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// minRSABits is the smallest RSA key accepted.
+const minRSABits = 2048
+
+// Key is one signing key. Its ID is what tokens name in their kid header.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	private crypto.Signer
+}
+
+// NewKey wraps an RSA key, used with RS256, or an Ed25519 key, used with
+// EdDSA.
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	if id == "" {
+		return nil, fmt.Errorf("key has no ID")
+	}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("key %s: RSA keys must have at least %d bits, got %d", id, minRSABits, k.N.BitLen())
+		}
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, private: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: SigningMethodEdDSA, private: k}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", id, private)
+	}
+}
+
+// Public is the half of the key that verifies tokens.
+func (k *Key) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// KeySet is the keys tokens are verified with, one of which signs new ones.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	ids     []string
+}
+
+// NewKeySet builds a set that signs with the key named signingID. It can
+// be left empty when there is a single key.
+func NewKeySet(signingID string, keys ...*Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys")
+	}
+
+	set := &KeySet{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %s", key.ID)
+		}
+		set.keys[key.ID] = key
+		set.ids = append(set.ids, key.ID)
+	}
+	sort.Strings(set.ids)
+
+	if signingID == "" {
+		if len(keys) > 1 {
+			return nil, fmt.Errorf("the signing key must be chosen among %s", strings.Join(set.ids, ", "))
+		}
+		signingID = keys[0].ID
+	}
+
+	set.signing = set.keys[signingID]
+	if set.signing == nil {
+		return nil, fmt.Errorf("signing key %s is not among %s", signingID, strings.Join(set.ids, ", "))
+	}
+
+	return set, nil
+}
+
+// LoadDir reads the private keys in dir, one PEM file per key named after
+// its ID, such as 2024-05.pem. Both PKCS #8 and PKCS #1 files work, as
+// made by `openssl genpkey -algorithm ed25519` or
+// `openssl genpkey -algorithm rsa -pkeyopt rsa_keygen_bits:2048`.
+func LoadDir(dir, signingID string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading key directory: %w", err)
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading key: %w", err)
+		}
+
+		id := strings.TrimSuffix(entry.Name(), ".pem")
+		private, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %s: %w", id, err)
+		}
+
+		key, err := NewKey(id, private)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no .pem keys in %s", dir)
+	}
+
+	return NewKeySet(signingID, keys...)
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+}
+
+// Ephemeral makes a set with a fresh Ed25519 key that only lives as long as
+// the process, for development.
+func Ephemeral() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating key ID: %w", err)
+	}
+
+	key, err := NewKey("ephemeral-"+hex.EncodeToString(id), private)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySet("", key)
+}
+
+// SigningKeyID is the ID of the key new tokens are signed with.
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+// Sign makes a token of claims with the signing key.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+
+	return token.SignedString(s.signing.private)
+}
+
+// Keyfunc picks the key to verify a token with, by its kid. Tokens must
+// use the algorithm of their key, so an RSA public key can't be passed off
+// as an HMAC secret.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %s is for %s, not %s", id, key.Method.Alg(), token.Method.Alg())
+	}
+
+	return key.Public(), nil
+}
+
+// JWK is the public half of a key as described in RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	// Curve and X are set for Ed25519 keys (RFC 8037)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	// N and E are set for RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKS is the document other services fetch our public keys from.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public halves of all the keys tokens are verified with.
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, id := range s.ids {
+		key := s.keys[id]
+		jwk := JWK{Use: "sig", Algorithm: key.Method.Alg(), KeyID: key.ID}
+
+		switch public := key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
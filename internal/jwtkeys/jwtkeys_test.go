@@ -0,0 +1,159 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/dgrijalva/jwt-go"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKey(t *testing.T, dir, id string, private crypto.Signer) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, id+".pem"), data, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+func newEd25519(t *testing.T) ed25519.PrivateKey {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return private
+}
+
+func newRSA(t *testing.T, bits int) *rsa.PrivateKey {
+	private, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return private
+}
+
+func claims() jwt.Claims {
+	return jwt.StandardClaims{Subject: "7", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+}
+
+func verify(set *KeySet, token string) error {
+	_, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, set.Keyfunc)
+	return err
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for name, private := range map[string]crypto.Signer{"EdDSA": newEd25519(t), "RS256": newRSA(t, 2048)} {
+		key, err := NewKey("k1", private)
+		if err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+		set, err := NewKeySet("", key)
+		if err != nil {
+			t.Fatalf("Failed to create key set: %v", err)
+		}
+
+		token, err := set.Sign(claims())
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+
+		parsed, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, set.Keyfunc)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, name, parsed.Header["alg"])
+			assert.Equal(t, "k1", parsed.Header["kid"])
+		}
+
+		other, _ := Ephemeral()
+		assert.Error(t, verify(other, token), "%s tokens only verify with our keys", name)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2024-01", newRSA(t, 2048))
+
+	old, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	oldToken, _ := old.Sign(claims())
+
+	writeKey(t, dir, "2024-02", newEd25519(t))
+	_, err = LoadDir(dir, "")
+	assert.Error(t, err, "The signing key must be chosen once there are several")
+
+	published, err := LoadDir(dir, "2024-01")
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	assert.Len(t, published.JWKS().Keys, 2, "New keys are published before they sign")
+
+	rotated, err := LoadDir(dir, "2024-02")
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	newToken, _ := rotated.Sign(claims())
+	assert.NoError(t, verify(rotated, oldToken), "Old keys keep verifying")
+	assert.NoError(t, verify(published, newToken), "Instances that haven't rotated yet verify new tokens")
+
+	if err := os.Remove(filepath.Join(dir, "2024-01.pem")); err != nil {
+		t.Fatalf("Failed to remove key: %v", err)
+	}
+	retired, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	assert.Error(t, verify(retired, oldToken), "Retired keys stop verifying")
+	assert.NoError(t, verify(retired, newToken))
+}
+
+func TestKeyfuncRejectsOtherAlgorithms(t *testing.T) {
+	key, _ := NewKey("k1", newRSA(t, 2048))
+	set, _ := NewKeySet("", key)
+
+	// The classic confusion: an HMAC token keyed with the RSA public key
+	der, _ := x509.MarshalPKIXPublicKey(key.Public())
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	forged.Header["kid"] = "k1"
+	token, _ := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.Error(t, verify(set, token))
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+	token, _ = unsigned.SignedString(key.private)
+	assert.Error(t, verify(set, token), "Tokens without a kid are rejected")
+}
+
+func TestNewKeyRejectsWeakRSA(t *testing.T) {
+	_, err := NewKey("k1", newRSA(t, 1024))
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	private := newEd25519(t)
+	edKey, _ := NewKey("b", private)
+	rsaPrivate := newRSA(t, 2048)
+	rsaKey, _ := NewKey("a", rsaPrivate)
+	set, _ := NewKeySet("b", edKey, rsaKey)
+
+	keys := set.JWKS().Keys
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, JWK{KeyType: "RSA", Use: "sig", Algorithm: "RS256", KeyID: "a", N: keys[0].N, E: "AQAB"}, keys[0])
+		assert.NotEmpty(t, keys[0].N)
+		assert.Equal(t, "OKP", keys[1].KeyType)
+		assert.Equal(t, "Ed25519", keys[1].Curve)
+		assert.Equal(t, "EdDSA", keys[1].Algorithm)
+		assert.Len(t, keys[1].X, 43, "32 bytes in unpadded base64url")
+	}
+}
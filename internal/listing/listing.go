@@ -0,0 +1,146 @@
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor marks the last row of a page: the value of the sort column and the
+// id that breaks ties between rows sharing that value.
+type Cursor struct {
+	Value string `json:"v,omitempty"`
+	ID    int    `json:"id"`
+}
+
+// Encode turns the cursor into the opaque string handed to clients.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &cursor, nil
+}
+
+// Params are the paging and sorting options of a list request.
+type Params struct {
+	Limit int
+	Sort  string
+	Desc  bool
+	After *Cursor
+}
+
+// Parse reads ?limit=, ?cursor= and ?sort= from a query. Sort takes one of
+// the given fields, prefixed with "-" for descending order, and defaults to
+// the first one.
+func Parse(query url.Values, sorts ...string) (Params, error) {
+	params := Params{Limit: DefaultLimit, Sort: sorts[0]}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > MaxLimit {
+			return Params{}, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		params.Limit = parsed
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		params.Desc = strings.HasPrefix(sort, "-")
+		params.Sort = strings.TrimPrefix(sort, "-")
+		if !contains(sorts, params.Sort) {
+			return Params{}, fmt.Errorf("sort must be one of %s", strings.Join(sorts, ", "))
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return Params{}, err
+		}
+		params.After = after
+	}
+
+	return params, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keyset builds the condition and ordering for keyset pagination over
+// column, using idColumn to break ties. The cursor values are appended to
+// args and referenced by position, so the condition can be combined with
+// filters that already use earlier placeholders. The query must fetch
+// Limit+1 rows so NewPage can tell whether another page exists.
+func (p Params) Keyset(column, idColumn string, args []interface{}) (where, orderBy string, _ []interface{}) {
+	direction, comparison := "ASC", ">"
+	if p.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if column == idColumn {
+		orderBy = fmt.Sprintf("%s %s", idColumn, direction)
+		if p.After != nil {
+			args = append(args, p.After.ID)
+			where = fmt.Sprintf("%s %s $%d", idColumn, comparison, len(args))
+		}
+		return where, orderBy, args
+	}
+
+	orderBy = fmt.Sprintf("%s %s, %s %s", column, direction, idColumn, direction)
+	if p.After != nil {
+		args = append(args, p.After.Value, p.After.ID)
+		where = fmt.Sprintf("(%s, %s) %s ($%d, $%d)", column, idColumn, comparison, len(args)-1, len(args))
+	}
+
+	return where, orderBy, args
+}
+
+// Page is the envelope of every paginated response. NextCursor is null on
+// the last page.
+type Page[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// NewPage trims the extra row fetched beyond the limit and, when there was
+// one, points the next cursor at the last row kept.
+func NewPage[T any](items []T, limit int, cursorOf func(T) Cursor) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	if len(items) <= limit {
+		return Page[T]{Data: items}
+	}
+
+	items = items[:limit]
+	next := cursorOf(items[len(items)-1]).Encode()
+	return Page[T]{Data: items, NextCursor: &next}
+}
@@ -0,0 +1,68 @@
+package listing
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	params, err := Parse(url.Values{}, "name", "id")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	assert.Equal(t, Params{Limit: DefaultLimit, Sort: "name"}, params)
+
+	cursor := Cursor{Value: "Godot", ID: 7}
+	params, err = Parse(url.Values{"limit": {"5"}, "sort": {"-id"}, "cursor": {cursor.Encode()}}, "name", "id")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	assert.Equal(t, Params{Limit: 5, Sort: "id", Desc: true, After: &cursor}, params)
+
+	_, err = Parse(url.Values{"sort": {"price"}}, "name", "id")
+	assert.Error(t, err)
+
+	_, err = Parse(url.Values{"limit": {"1000"}}, "name")
+	assert.Error(t, err)
+
+	_, err = Parse(url.Values{"cursor": {"not a cursor"}}, "name")
+	assert.Error(t, err)
+}
+
+func TestKeyset(t *testing.T) {
+	params := Params{Sort: "name", After: &Cursor{Value: "Godot", ID: 7}}
+	where, orderBy, args := params.Keyset("name", "id", []interface{}{"filter"})
+	assert.Equal(t, "(name, id) > ($2, $3)", where)
+	assert.Equal(t, "name ASC, id ASC", orderBy)
+	assert.Equal(t, []interface{}{"filter", "Godot", 7}, args)
+
+	params = Params{Sort: "id", Desc: true, After: &Cursor{ID: 7}}
+	where, orderBy, args = params.Keyset("id", "id", nil)
+	assert.Equal(t, "id < $1", where)
+	assert.Equal(t, "id DESC", orderBy)
+	assert.Equal(t, []interface{}{7}, args)
+
+	where, _, args = Params{Sort: "id"}.Keyset("id", "id", nil)
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+}
+
+func TestNewPage(t *testing.T) {
+	cursorOf := func(i int) Cursor { return Cursor{ID: i} }
+
+	page := NewPage([]int{1, 2}, 2, cursorOf)
+	assert.Equal(t, []int{1, 2}, page.Data)
+	assert.Nil(t, page.NextCursor)
+
+	page = NewPage([]int{1, 2, 3}, 2, cursorOf)
+	assert.Equal(t, []int{1, 2}, page.Data)
+	if assert.NotNil(t, page.NextCursor) {
+		next, err := DecodeCursor(*page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, next.ID)
+	}
+
+	assert.Equal(t, []int{}, NewPage[int](nil, 2, cursorOf).Data)
+}
@@ -0,0 +1,90 @@
+// Package lockout decides how failed sign ins slow down further attempts
+// and when they lock an account or address out for a while.
+package lockout
+
+import (
+	"game-student-go/internal/model"
+	"time"
+)
+
+const (
+	// FailureWindow is how long a failure counts; a key whose last failure
+	// is older starts over.
+	FailureWindow = time.Hour
+
+	baseDelay = time.Second
+	maxDelay  = time.Minute
+)
+
+// Policy is the set of limits applied to one kind of key.
+type Policy struct {
+	// FreeAttempts is how many failures are allowed before delays start.
+	FreeAttempts int
+	// MaxFailures is how many failures lock the key.
+	MaxFailures int
+	// Duration is how long a lock lasts.
+	Duration time.Duration
+}
+
+// Delay is the wait imposed after the given number of failures. It doubles
+// with every failure past the free ones, up to a minute.
+func (p Policy) Delay(failures int) time.Duration {
+	extra := failures - p.FreeAttempts
+	if extra <= 0 {
+		return 0
+	}
+
+	delay := baseDelay
+	for i := 1; i < extra && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay
+}
+
+// RetryAt is the earliest time the key may try again, which is before now
+// when it is not held back at all.
+func (p Policy) RetryAt(throttle model.SigninThrottle, now time.Time) time.Time {
+	retryAt := time.Time{}
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+		retryAt = *throttle.LockedUntil
+	}
+
+	if now.Sub(throttle.LastFailureAt) < FailureWindow {
+		if delayed := throttle.LastFailureAt.Add(p.Delay(throttle.Failures)); delayed.After(retryAt) {
+			retryAt = delayed
+		}
+	}
+
+	return retryAt
+}
+
+// Admit decides on an attempt from the key's throttle as it stood before the
+// attempt was counted. It returns when the caller may try again, which is
+// not after now when the attempt may go ahead, and whether the attempt found
+// the key at its limit and must lock it until then.
+func (p Policy) Admit(before model.SigninThrottle, now time.Time) (time.Time, bool) {
+	if before.LockedUntil != nil && before.LockedUntil.After(now) {
+		return *before.LockedUntil, false
+	}
+
+	if p.Locks(before.Failures) {
+		return now.Add(p.Duration), true
+	}
+
+	if !p.RetryAt(before, now).After(now) {
+		return time.Time{}, false
+	}
+
+	// The attempt was counted all the same, which pushes the next one back
+	counted := model.SigninThrottle{Failures: before.Failures + 1, LastFailureAt: now}
+	return p.RetryAt(counted, now), false
+}
+
+// Locks tells whether reaching the given number of failures locks the key.
+func (p Policy) Locks(failures int) bool {
+	return p.MaxFailures > 0 && failures >= p.MaxFailures
+}
@@ -0,0 +1,72 @@
+package lockout
+
+import (
+	"game-student-go/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, MaxFailures: 10, Duration: 15 * time.Minute}
+
+	assert.Equal(t, time.Duration(0), policy.Delay(0))
+	assert.Equal(t, time.Duration(0), policy.Delay(3))
+	assert.Equal(t, time.Second, policy.Delay(4))
+	assert.Equal(t, 2*time.Second, policy.Delay(5))
+	assert.Equal(t, 8*time.Second, policy.Delay(7))
+	assert.Equal(t, time.Minute, policy.Delay(50), "Delays are capped")
+}
+
+func TestRetryAt(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, MaxFailures: 10, Duration: 15 * time.Minute}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	fresh := model.SigninThrottle{Failures: 2, LastFailureAt: now.Add(-time.Second)}
+	assert.False(t, policy.RetryAt(fresh, now).After(now), "Free attempts are not held back")
+
+	delayed := model.SigninThrottle{Failures: 5, LastFailureAt: now.Add(-time.Second)}
+	assert.Equal(t, now.Add(time.Second), policy.RetryAt(delayed, now))
+
+	stale := model.SigninThrottle{Failures: 9, LastFailureAt: now.Add(-2 * FailureWindow)}
+	assert.False(t, policy.RetryAt(stale, now).After(now), "Old failures are forgotten")
+
+	lockedUntil := now.Add(10 * time.Minute)
+	locked := model.SigninThrottle{Failures: 0, LastFailureAt: now.Add(-5 * time.Minute), LockedUntil: &lockedUntil}
+	assert.Equal(t, lockedUntil, policy.RetryAt(locked, now))
+
+	expired := now.Add(-time.Minute)
+	unlocked := model.SigninThrottle{LastFailureAt: now.Add(-20 * time.Minute), LockedUntil: &expired}
+	assert.False(t, policy.RetryAt(unlocked, now).After(now))
+}
+
+func TestAdmit(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, MaxFailures: 10, Duration: 15 * time.Minute}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	retryAt, locks := policy.Admit(model.SigninThrottle{Failures: 3, LastFailureAt: now}, now)
+	assert.False(t, retryAt.After(now), "The last free attempt goes ahead")
+	assert.False(t, locks)
+
+	retryAt, locks = policy.Admit(model.SigninThrottle{Failures: 4, LastFailureAt: now}, now)
+	assert.Equal(t, now.Add(2*time.Second), retryAt, "Attempts held back count too")
+	assert.False(t, locks)
+
+	retryAt, locks = policy.Admit(model.SigninThrottle{Failures: 10, LastFailureAt: now.Add(-time.Hour / 2)}, now)
+	assert.Equal(t, now.Add(15*time.Minute), retryAt)
+	assert.True(t, locks, "An attempt past the limit locks the key")
+
+	lockedUntil := now.Add(time.Minute)
+	retryAt, locks = policy.Admit(model.SigninThrottle{LastFailureAt: now, LockedUntil: &lockedUntil}, now)
+	assert.Equal(t, lockedUntil, retryAt)
+	assert.False(t, locks, "Locks are not extended")
+}
+
+func TestLocks(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, MaxFailures: 10}
+
+	assert.False(t, policy.Locks(9))
+	assert.True(t, policy.Locks(10))
+	assert.False(t, Policy{}.Locks(1000), "A zero policy never locks")
+}
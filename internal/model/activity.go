@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+const (
+	ActivityTrainingCompleted = "training_completed"
+	ActivityQuizPassed        = "quiz_passed"
+)
+
+type ActivityDay struct {
+	Date               time.Time `json:"date"`
+	TrainingsCompleted int       `json:"trainings_completed"`
+	QuizzesPassed      int       `json:"quizzes_passed"`
+	Count              int       `json:"count"`
+}
+
+type Streak struct {
+	Current     int  `json:"current"`
+	Longest     int  `json:"longest"`
+	ActiveToday bool `json:"active_today"`
+	AtRisk      bool `json:"at_risk"`
+}
+
+// StreakReminder is a student that kept a streak until yesterday and has not
+// studied yet today in their own time zone.
+type StreakReminder struct {
+	UserID   int
+	Email    string
+	TimeZone string
+	Day      time.Time
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type Certificate struct {
+	ID            int        `json:"id"`
+	Code          string     `json:"code"`
+	UserID        int        `json:"user_id"`
+	CourseID      int        `json:"course_id"`
+	StudentName   string     `json:"student_name"`
+	CourseName    string     `json:"course_name"`
+	IssuedAt      time.Time  `json:"issued_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+}
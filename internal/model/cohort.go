@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+const (
+	EnrollmentPending   = "pending"
+	EnrollmentActive    = "active"
+	EnrollmentCancelled = "cancelled"
+)
+
+// Cohort is an instructor-led class running a course between two dates.
+// Enrolled counts the seats taken, pending payments included.
+type Cohort struct {
+	ID        int       `json:"id"`
+	CourseID  int       `json:"course_id"`
+	Name      string    `json:"name"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Capacity  int       `json:"capacity"`
+	Enrolled  int       `json:"enrolled"`
+	Price     int64     `json:"price"`
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Enrollment struct {
+	CohortID  int       `json:"cohort_id"`
+	UserID    int       `json:"user_id"`
+	Status    string    `json:"status"`
+	PaymentID *int      `json:"payment_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PendingEnrollment is a seat held for a payment that has not gone through.
+// PaymentIntentID is empty when the payment was never started.
+type PendingEnrollment struct {
+	CohortID        int
+	UserID          int
+	PaymentIntentID string
+}
+
+// LiveSession is a scheduled meeting of a cohort. Feeds across cohorts also
+// fill in the cohort name.
+type LiveSession struct {
+	ID              int       `json:"id"`
+	CohortID        int       `json:"cohort_id"`
+	CohortName      string    `json:"cohort_name,omitempty"`
+	Title           string    `json:"title"`
+	StartsAt        time.Time `json:"starts_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	MeetingURL      string    `json:"meeting_url"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (s LiveSession) EndsAt() time.Time {
+	return s.StartsAt.Add(time.Duration(s.DurationMinutes) * time.Minute)
+}
+
+// SessionReminder is a student to remind of an upcoming live session.
+type SessionReminder struct {
+	SessionID  int
+	UserID     int
+	Email      string
+	TimeZone   string
+	Title      string
+	StartsAt   time.Time
+	MeetingURL string
+}
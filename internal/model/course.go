@@ -1,8 +1,57 @@
 package model
 
+import "time"
+
+const (
+	DifficultyBeginner     = "beginner"
+	DifficultyIntermediate = "intermediate"
+	DifficultyAdvanced     = "advanced"
+)
+
 type Course struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	LogoURL     string `json:"logo_url"`
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	LogoURL       string     `json:"logo_url"`
+	CategoryID    *int       `json:"category_id"`
+	Difficulty    string     `json:"difficulty,omitempty"`
+	Tags          []string   `json:"tags"`
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+	PublishedAt   *time.Time `json:"published_at,omitempty"`
+	InstructorIDs []int      `json:"instructor_ids"`
+	Rating        float64    `json:"rating"`
+	RatingCount   int        `json:"rating_count"`
+}
+
+// CourseFilter narrows course listings. Zero values do not filter, except
+// Preview whose zero value leaves out unpublished courses.
+type CourseFilter struct {
+	Search           string
+	HasFreeTrainings *bool
+	Category         string
+	Tags             []string
+	Difficulty       string
+	InstructorID     int
+	Preview          Preview
+}
+
+type Category struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// CourseFacets counts the courses behind each filter value. Every facet is
+// counted with the other filters applied but not its own, so the sidebar
+// shows what selecting another value would return.
+type CourseFacets struct {
+	Categories   []FacetCount `json:"categories"`
+	Tags         []FacetCount `json:"tags"`
+	Difficulties []FacetCount `json:"difficulties"`
 }
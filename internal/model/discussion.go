@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+type Question struct {
+	ID               int       `json:"id"`
+	TrainingID       int       `json:"training_id"`
+	UserID           int       `json:"user_id"`
+	Title            string    `json:"title"`
+	Body             string    `json:"body"`
+	Upvotes          int       `json:"upvotes"`
+	AnswerCount      int       `json:"answer_count"`
+	AcceptedAnswerID *int      `json:"accepted_answer_id"`
+	Locked           bool      `json:"locked"`
+	Hidden           bool      `json:"hidden,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type Answer struct {
+	ID         int       `json:"id"`
+	QuestionID int       `json:"question_id"`
+	ParentID   *int      `json:"parent_id"`
+	UserID     int       `json:"user_id"`
+	Body       string    `json:"body"`
+	Upvotes    int       `json:"upvotes"`
+	Hidden     bool      `json:"hidden,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Thread is a question with its answers, oldest first. Replies point to the
+// answer they reply to through ParentID.
+type Thread struct {
+	Question
+	Answers []Answer `json:"answers"`
+}
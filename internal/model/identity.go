@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// UserIdentity links a user to their account at an identity provider.
+type UserIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OAuthState is a sign in started with a provider, kept until it comes
+// back to the callback.
+type OAuthState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// OAuthSignin is a sign in finished at a provider, waiting for the app to
+// collect it with its one-time code. Link is the provider account to link
+// to the user once they confirm with their password, when it matched them
+// by email and is not linked yet.
+type OAuthSignin struct {
+	CodeHash  string
+	UserID    int
+	Link      *UserIdentity
+	ExpiresAt time.Time
+}
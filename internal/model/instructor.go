@@ -0,0 +1,16 @@
+package model
+
+type Instructor struct {
+	UserID        int               `json:"id"`
+	DisplayName   string            `json:"display_name"`
+	Bio           string            `json:"bio"`
+	AvatarURL     string            `json:"avatar_url"`
+	SocialLinks   map[string]string `json:"social_links"`
+	PayoutAccount string            `json:"payout_account,omitempty"`
+}
+
+// Public hides what only the instructor and admins may see.
+func (i Instructor) Public() Instructor {
+	i.PayoutAccount = ""
+	return i
+}
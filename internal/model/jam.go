@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+type GameJam struct {
+	ID                 int            `json:"id"`
+	Title              string         `json:"title"`
+	Description        string         `json:"description"`
+	Theme              string         `json:"theme,omitempty"`
+	ThemeRevealAt      time.Time      `json:"theme_reveal_at"`
+	SubmissionDeadline time.Time      `json:"submission_deadline"`
+	VotingEndsAt       time.Time      `json:"voting_ends_at"`
+	MaxTeamSize        int            `json:"max_team_size"`
+	ResultsPublishedAt *time.Time     `json:"results_published_at"`
+	Criteria           []JamCriterion `json:"criteria"`
+	Phase              string         `json:"phase"`
+	CreatedAt          time.Time      `json:"created_at"`
+}
+
+type JamCriterion struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// JamTeam lists its members by user id. The invite code is only shown to
+// them.
+type JamTeam struct {
+	ID         int       `json:"id"`
+	JamID      int       `json:"jam_id"`
+	Name       string    `json:"name"`
+	Members    []int     `json:"members"`
+	InviteCode string    `json:"invite_code,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (t JamTeam) HasMember(userID int) bool {
+	for _, member := range t.Members {
+		if member == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JamEntry is a team's game. Score, Rank and Scores are set once the jam's
+// results are published.
+type JamEntry struct {
+	ID             int              `json:"id"`
+	JamID          int              `json:"jam_id"`
+	TeamID         int              `json:"team_id"`
+	TeamName       string           `json:"team_name"`
+	Title          string           `json:"title"`
+	Description    string           `json:"description"`
+	BuildURL       string           `json:"build_url,omitempty"`
+	HasBuild       bool             `json:"has_build"`
+	BuildKey       string           `json:"-"`
+	ScreenshotKeys []string         `json:"-"`
+	Screenshots    []string         `json:"screenshots"`
+	Score          *float64         `json:"score,omitempty"`
+	Rank           *int             `json:"rank,omitempty"`
+	Scores         []CriterionScore `json:"scores,omitempty"`
+	SubmittedAt    time.Time        `json:"submitted_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+type CriterionScore struct {
+	CriterionID int     `json:"criterion_id"`
+	Average     float64 `json:"average"`
+	Votes       int     `json:"votes"`
+}
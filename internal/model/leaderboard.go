@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+const (
+	LeaderboardGlobal = "global"
+	LeaderboardCourse = "course"
+	LeaderboardCohort = "cohort"
+
+	PeriodAllTime = "all_time"
+	PeriodWeekly  = "weekly"
+)
+
+type LeaderboardEntry struct {
+	Rank   int `json:"rank"`
+	UserID int `json:"user_id"`
+	XP     int `json:"xp"`
+}
+
+type Leaderboard struct {
+	Scope       string             `json:"scope"`
+	ScopeID     int                `json:"scope_id,omitempty"`
+	Period      string             `json:"period"`
+	SeasonStart time.Time          `json:"season_start"`
+	Entries     []LeaderboardEntry `json:"entries"`
+	Me          *LeaderboardEntry  `json:"me"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// SigninThrottle counts the recent failed sign ins of an account, or of an
+// account from one IP address. Attempts count as failed until they succeed.
+type SigninThrottle struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Note is a private note a student takes at a point of a training video.
+// Listings across trainings also fill in the training it belongs to.
+type Note struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"user_id"`
+	TrainingID      int       `json:"training_id"`
+	TrainingName    string    `json:"training_name,omitempty"`
+	PositionSeconds int       `json:"position_seconds"`
+	Body            string    `json:"body"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type Bookmark struct {
+	TrainingID   int       `json:"training_id"`
+	TrainingName string    `json:"training_name"`
+	CourseID     int       `json:"course_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
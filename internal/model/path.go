@@ -0,0 +1,46 @@
+package model
+
+type Prerequisite struct {
+	CourseID int    `json:"course_id"`
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+}
+
+// CourseEligibility tells a student whether they may start a course.
+// Blocking lists the required prerequisites they have not completed and
+// Warnings the recommended ones.
+type CourseEligibility struct {
+	CourseID  int            `json:"course_id"`
+	CanEnroll bool           `json:"can_enroll"`
+	Blocking  []Prerequisite `json:"blocking"`
+	Warnings  []Prerequisite `json:"warnings"`
+}
+
+type LearningPath struct {
+	ID          int          `json:"id"`
+	Slug        string       `json:"slug"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Courses     []PathCourse `json:"courses"`
+}
+
+// PathCourse is a course of a learning path, in the order it should be taken.
+type PathCourse struct {
+	CourseID int    `json:"course_id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+}
+
+type PathCourseProgress struct {
+	PathCourse
+	Progress CourseProgress `json:"progress"`
+}
+
+type PathProgress struct {
+	PathID           int                  `json:"path_id"`
+	Courses          []PathCourseProgress `json:"courses"`
+	CompletedCourses int                  `json:"completed_courses"`
+	TotalCourses     int                  `json:"total_courses"`
+	Percentage       float64              `json:"percentage"`
+	NextCourseID     *int                 `json:"next_course_id"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Kinds of work shown in a portfolio.
+const (
+	PortfolioSubmission  = "submission"
+	PortfolioJamEntry    = "jam_entry"
+	PortfolioCertificate = "certificate"
+	PortfolioBadge       = "badge"
+)
+
+type Portfolio struct {
+	UserID      int             `json:"-"`
+	Slug        string          `json:"slug"`
+	DisplayName string          `json:"display_name"`
+	Headline    string          `json:"headline"`
+	Public      bool            `json:"public"`
+	Items       []PortfolioItem `json:"items"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// PortfolioItem is an approved submission, jam entry, certificate or badge.
+// Key is the item id, or its code for certificates and badges.
+type PortfolioItem struct {
+	Kind     string    `json:"kind"`
+	Key      string    `json:"key"`
+	Title    string    `json:"title"`
+	Subtitle string    `json:"subtitle,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Date     time.Time `json:"date"`
+	Visible  bool      `json:"visible"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+// DataExport is a copy of a user's personal data, built in the background
+// and downloadable until it expires.
+type DataExport struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"-"`
+	Status      string     `json:"status"`
+	FileKey     string     `json:"-"`
+	Error       string     `json:"error,omitempty"`
+	RequestedAt time.Time  `json:"requested_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// PersonalData is everything kept about a user, as handed out in exports.
+type PersonalData struct {
+	Profile      User               `json:"profile"`
+	Payments     []Payment          `json:"payments"`
+	Cards        []Card             `json:"cards"`
+	Progress     []TrainingProgress `json:"progress"`
+	Submissions  []Submission       `json:"submissions"`
+	Reviews      []Review           `json:"reviews"`
+	Certificates []Certificate      `json:"certificates"`
+	Notes        []Note             `json:"notes"`
+	Identities   []UserIdentity     `json:"linked_accounts"`
+}
+
+// AccountDeletion records who asked for an account to be deleted and when
+// it was carried out.
+type AccountDeletion struct {
+	ID          int
+	UserID      int
+	IPAddress   string
+	UserAgent   string
+	RequestedAt time.Time
+	CompletedAt *time.Time
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type TrainingProgress struct {
+	UserID          int        `json:"user_id"`
+	TrainingID      int        `json:"training_id"`
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	PositionSeconds int        `json:"position_seconds"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type CourseProgress struct {
+	CourseID           int     `json:"course_id"`
+	TotalTrainings     int     `json:"total_trainings"`
+	CompletedTrainings int     `json:"completed_trainings"`
+	Percentage         float64 `json:"percentage"`
+}
+
+// Finished tells whether every training of the course was completed.
+func (p CourseProgress) Finished() bool {
+	return p.TotalTrainings > 0 && p.CompletedTrainings >= p.TotalTrainings
+}
@@ -0,0 +1,17 @@
+package model
+
+// Courses and trainings share a publication lifecycle. Only published
+// content shows up on the public endpoints.
+const (
+	PublicationDraft     = "draft"
+	PublicationScheduled = "scheduled"
+	PublicationPublished = "published"
+	PublicationArchived  = "archived"
+)
+
+// Preview says which unpublished courses a reader may see besides the
+// published catalog. The zero value sees published courses only.
+type Preview struct {
+	All          bool
+	InstructorID int
+}
@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+const (
+	QuestionSingleChoice   = "single"
+	QuestionMultipleChoice = "multiple"
+	QuestionNumeric        = "numeric"
+)
+
+type Quiz struct {
+	ID                    int            `json:"id"`
+	TrainingID            int            `json:"training_id"`
+	PassingScore          int            `json:"passing_score"`
+	MaxAttempts           int            `json:"max_attempts"`
+	RequiredForCompletion bool           `json:"required_for_completion"`
+	Questions             []QuizQuestion `json:"questions"`
+}
+
+type QuizQuestion struct {
+	ID            int          `json:"id"`
+	Kind          string       `json:"kind"`
+	Prompt        string       `json:"prompt"`
+	Points        int          `json:"points"`
+	Options       []QuizOption `json:"options,omitempty"`
+	CorrectNumber *float64     `json:"correct_number,omitempty"`
+	Tolerance     float64      `json:"tolerance,omitempty"`
+}
+
+type QuizOption struct {
+	ID      int    `json:"id"`
+	Text    string `json:"text"`
+	Correct bool   `json:"correct,omitempty"`
+}
+
+type QuizAnswer struct {
+	QuestionID int      `json:"question_id"`
+	OptionIDs  []int    `json:"option_ids,omitempty"`
+	Number     *float64 `json:"number,omitempty"`
+}
+
+type QuizAttempt struct {
+	ID          int          `json:"id"`
+	QuizID      int          `json:"quiz_id"`
+	UserID      int          `json:"user_id"`
+	Score       int          `json:"score"`
+	Passed      bool         `json:"passed"`
+	Answers     []QuizAnswer `json:"answers"`
+	SubmittedAt time.Time    `json:"submitted_at"`
+}
+
+// WithoutAnswers returns a copy of the quiz that is safe to show to students.
+func (q Quiz) WithoutAnswers() Quiz {
+	questions := make([]QuizQuestion, len(q.Questions))
+	for i, question := range q.Questions {
+		options := make([]QuizOption, len(question.Options))
+		for j, option := range question.Options {
+			options[j] = QuizOption{ID: option.ID, Text: option.Text}
+		}
+		question.Options = options
+		question.CorrectNumber = nil
+		question.Tolerance = 0
+		questions[i] = question
+	}
+	q.Questions = questions
+
+	return q
+}
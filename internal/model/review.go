@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type Review struct {
+	ID           int          `json:"id"`
+	CourseID     int          `json:"course_id"`
+	UserID       int          `json:"user_id"`
+	Rating       int          `json:"rating"`
+	Body         string       `json:"body"`
+	HelpfulCount int          `json:"helpful_count"`
+	ReportCount  int          `json:"report_count,omitempty"`
+	Hidden       bool         `json:"hidden,omitempty"`
+	Reply        *ReviewReply `json:"reply,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// ReviewReply is the one public answer the course instructors may give to
+// a review.
+type ReviewReply struct {
+	InstructorID *int      `json:"instructor_id"`
+	Body         string    `json:"body"`
+	RepliedAt    time.Time `json:"replied_at"`
+}
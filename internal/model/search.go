@@ -0,0 +1,17 @@
+package model
+
+const (
+	SearchResultCourse   = "course"
+	SearchResultTraining = "training"
+)
+
+// SearchResult is a course or training matching a catalog search. Title and
+// Snippet are escaped HTML with the matched words wrapped in <mark> tags.
+type SearchResult struct {
+	Type     string  `json:"type"`
+	ID       int     `json:"id"`
+	CourseID int     `json:"course_id"`
+	Title    string  `json:"title"`
+	Snippet  string  `json:"snippet"`
+	Rank     float64 `json:"rank"`
+}
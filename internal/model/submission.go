@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	SubmissionPending          = "pending"
+	SubmissionApproved         = "approved"
+	SubmissionChangesRequested = "changes_requested"
+)
+
+type Submission struct {
+	ID            int        `json:"id"`
+	UserID        int        `json:"user_id"`
+	TrainingID    int        `json:"training_id"`
+	URL           string     `json:"url"`
+	AttachmentKey string     `json:"-"`
+	HasAttachment bool       `json:"has_attachment"`
+	Status        string     `json:"status"`
+	Feedback      string     `json:"feedback,omitempty"`
+	ReviewerID    *int       `json:"reviewer_id,omitempty"`
+	SubmittedAt   time.Time  `json:"submitted_at"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+}
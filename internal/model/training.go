@@ -1,6 +1,9 @@
 package model
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type Training struct {
 	ID         int            `json:"id"`
@@ -11,4 +14,6 @@ type Training struct {
 	IsFree     bool           `json:"is_free"`
 	ProjectURL sql.NullString `json:"project_url"`
 	CourseID   int            `json:"course_id"`
+	Status     string         `json:"status"`
+	PublishAt  *time.Time     `json:"publish_at,omitempty"`
 }
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// TwoFactor is the TOTP state of an account. EnabledAt is nil while the
+// enrollment waits for its first code.
+type TwoFactor struct {
+	UserID    int
+	Secret    string
+	EnabledAt *time.Time
+	LastStep  int64
+}
@@ -1,8 +1,34 @@
 package model
 
+import "time"
+
+const (
+	RoleStudent    = "student"
+	RoleInstructor = "instructor"
+	RoleAdmin      = "admin"
+)
+
 type User struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email"`
-	Password string `json:"-"`
-	StripeId string `json:"stripeId"`
+	ID          int    `json:"id"`
+	Email       string `json:"email"`
+	Password    string `json:"-"`
+	StripeId    string `json:"stripeId"`
+	Role        string `json:"role"`
+	TimeZone    string `json:"time_zone"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Bio         string `json:"bio"`
+	Locale      string `json:"locale"`
+	Country     string `json:"country"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	Deleted          bool `json:"-"`
+}
+
+// EmailChange is a request to move an account to a new address, waiting for
+// the token sent there to come back.
+type EmailChange struct {
+	UserID    int       `json:"-"`
+	NewEmail  string    `json:"new_email"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type XPGrant struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Amount    int       `json:"amount"`
+	Reason    string    `json:"reason"`
+	SourceID  int       `json:"source_id"`
+	CourseID  int       `json:"course_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserBadge struct {
+	UserID    int       `json:"user_id"`
+	Badge     string    `json:"badge"`
+	AwardedAt time.Time `json:"awarded_at"`
+}
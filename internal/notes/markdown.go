@@ -0,0 +1,42 @@
+// Package notes renders student notes for export.
+package notes
+
+import (
+	"fmt"
+	"game-student-go/internal/model"
+	"strings"
+)
+
+// Timestamp formats a video position as m:ss, or h:mm:ss past the hour.
+func Timestamp(seconds int) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// Markdown renders the notes of a course as a Markdown document with one
+// section per training. Notes must come ordered by training and position.
+func Markdown(courseName string, notes []model.Note) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", courseName)
+
+	if len(notes) == 0 {
+		b.WriteString("\nNenhuma anotação.\n")
+		return b.String()
+	}
+
+	trainingID := 0
+	for _, note := range notes {
+		if note.TrainingID != trainingID {
+			trainingID = note.TrainingID
+			fmt.Fprintf(&b, "\n## %s\n\n", note.TrainingName)
+		}
+
+		body := strings.ReplaceAll(strings.TrimSpace(note.Body), "\n", "\n  ")
+		fmt.Fprintf(&b, "- **%s** %s\n", Timestamp(note.PositionSeconds), body)
+	}
+
+	return b.String()
+}
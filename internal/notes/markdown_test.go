@@ -0,0 +1,35 @@
+package notes
+
+import (
+	"game-student-go/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestamp(t *testing.T) {
+	assert.Equal(t, "0:05", Timestamp(5))
+	assert.Equal(t, "12:34", Timestamp(754))
+	assert.Equal(t, "1:02:03", Timestamp(3723))
+}
+
+func TestMarkdown(t *testing.T) {
+	notes := []model.Note{
+		{TrainingID: 1, TrainingName: "Setup", PositionSeconds: 30, Body: "Install Godot"},
+		{TrainingID: 1, TrainingName: "Setup", PositionSeconds: 95, Body: "Pick the\nstable version"},
+		{TrainingID: 2, TrainingName: "Scenes", PositionSeconds: 0, Body: "Nodes form a tree"},
+	}
+
+	expected := "# Godot Basics\n" +
+		"\n## Setup\n\n" +
+		"- **0:30** Install Godot\n" +
+		"- **1:35** Pick the\n  stable version\n" +
+		"\n## Scenes\n\n" +
+		"- **0:00** Nodes form a tree\n"
+
+	assert.Equal(t, expected, Markdown("Godot Basics", notes))
+}
+
+func TestMarkdownWithoutNotes(t *testing.T) {
+	assert.Contains(t, Markdown("Godot Basics", nil), "Nenhuma anotação.")
+}
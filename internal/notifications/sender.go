@@ -1,9 +1,11 @@
 package notifications
 
 import (
+	"fmt"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	log "github.com/sirupsen/logrus"
+	"html"
 )
 
 type Sender struct {
@@ -16,12 +18,9 @@ func NewSender(client *sendgrid.Client) *Sender {
 	}
 }
 
-func (s *Sender) SendRegistrationEmail(destinationEmail string) error {
+func (s *Sender) send(destinationEmail, subject, plainTextContent, htmlContent string) error {
 	from := mail.NewEmail("Escola do Jogo", "no-reply@companyemail.com")
-	subject := "Bem vindo a Escola do Jogo!"
 	to := mail.NewEmail("Estudante", destinationEmail)
-	plainTextContent := "Bem vindo a Escola do Jogo."
-	htmlContent := "<strong>Obrigado!</strong>"
 	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
 	response, err := s.client.Send(message)
 	if err != nil {
@@ -29,8 +28,111 @@ func (s *Sender) SendRegistrationEmail(destinationEmail string) error {
 	}
 
 	if response.StatusCode != 202 {
-		log.Errorf("failure sending registration email with sendgrid: %v", response.Body)
+		log.Errorf("failure sending %q email with sendgrid: %v", subject, response.Body)
 	}
 
 	return nil
 }
+
+func (s *Sender) SendRegistrationEmail(destinationEmail string) error {
+	return s.send(destinationEmail, "Bem vindo a Escola do Jogo!", "Bem vindo a Escola do Jogo.", "<strong>Obrigado!</strong>")
+}
+
+func (s *Sender) SendSubmissionReviewedEmail(destinationEmail, trainingName string, approved bool, feedback string) error {
+	subject := fmt.Sprintf("Seu projeto de %s foi revisado", trainingName)
+	verdict := "Seu projeto precisa de ajustes."
+	if approved {
+		verdict = "Parabéns, seu projeto foi aprovado!"
+	}
+
+	plainTextContent := verdict
+	htmlContent := "<strong>" + verdict + "</strong>"
+	if feedback != "" {
+		plainTextContent += "\n\nComentários do instrutor:\n" + feedback
+		htmlContent += "<p>Comentários do instrutor:</p><p>" + html.EscapeString(feedback) + "</p>"
+	}
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendStreakReminderEmail(destinationEmail string, streak int) error {
+	subject := fmt.Sprintf("Não perca sua sequência de %d dias!", streak)
+	plainTextContent := fmt.Sprintf("Você estudou %d dias seguidos. Assista uma aula hoje para manter sua sequência.", streak)
+	htmlContent := fmt.Sprintf("<strong>Você estudou %d dias seguidos!</strong><p>Assista uma aula hoje para manter sua sequência.</p>", streak)
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendNewCourseEmail(destinationEmail, courseName, courseURL string) error {
+	subject := fmt.Sprintf("Novo curso: %s", courseName)
+	plainTextContent := fmt.Sprintf("O curso %s acabou de ser lançado na Escola do Jogo. Confira: %s", courseName, courseURL)
+	htmlContent := fmt.Sprintf("<strong>O curso %s acabou de ser lançado!</strong><p><a href=\"%s\">Confira na Escola do Jogo</a></p>",
+		html.EscapeString(courseName), html.EscapeString(courseURL))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendQuestionReplyEmail(destinationEmail, questionTitle, threadURL string) error {
+	subject := fmt.Sprintf("Nova resposta em \"%s\"", questionTitle)
+	plainTextContent := fmt.Sprintf("Alguém respondeu na discussão \"%s\". Veja a resposta: %s", questionTitle, threadURL)
+	htmlContent := fmt.Sprintf("<strong>Alguém respondeu na discussão \"%s\".</strong><p><a href=\"%s\">Ver resposta</a></p>",
+		html.EscapeString(questionTitle), html.EscapeString(threadURL))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendLiveSessionReminderEmail(destinationEmail, title, startsAt, meetingURL string) error {
+	subject := fmt.Sprintf("Aula ao vivo em breve: %s", title)
+	plainTextContent := fmt.Sprintf("A aula ao vivo \"%s\" começa em %s. Entre pelo link: %s", title, startsAt, meetingURL)
+	htmlContent := fmt.Sprintf("<strong>A aula ao vivo \"%s\" começa em %s.</strong><p><a href=\"%s\">Entrar na aula</a></p>",
+		html.EscapeString(title), html.EscapeString(startsAt), html.EscapeString(meetingURL))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendJamInviteEmail(destinationEmail, jamTitle, teamName, inviteCode, jamURL string) error {
+	subject := fmt.Sprintf("Convite para a equipe %s na %s", teamName, jamTitle)
+	plainTextContent := fmt.Sprintf("Você foi convidado para a equipe %s na game jam %s. Use o código %s para entrar: %s",
+		teamName, jamTitle, inviteCode, jamURL)
+	htmlContent := fmt.Sprintf("<strong>Você foi convidado para a equipe %s na game jam %s!</strong><p>Use o código <code>%s</code> para entrar.</p><p><a href=\"%s\">Ver a game jam</a></p>",
+		html.EscapeString(teamName), html.EscapeString(jamTitle), html.EscapeString(inviteCode), html.EscapeString(jamURL))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendEmailVerificationEmail(destinationEmail, verifyURL string) error {
+	subject := "Confirme seu novo email"
+	plainTextContent := fmt.Sprintf("Para usar este endereço na sua conta da Escola do Jogo, confirme pelo link: %s", verifyURL)
+	htmlContent := fmt.Sprintf("<strong>Confirme seu novo email.</strong><p><a href=\"%s\">Usar este endereço na Escola do Jogo</a></p><p>Se você não pediu esta troca, ignore esta mensagem.</p>",
+		html.EscapeString(verifyURL))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendEmailChangedEmail(destinationEmail, newEmail string) error {
+	subject := "O email da sua conta foi alterado"
+	plainTextContent := fmt.Sprintf("O email da sua conta da Escola do Jogo foi alterado para %s. Se não foi você, responda esta mensagem.", newEmail)
+	htmlContent := fmt.Sprintf("<strong>O email da sua conta foi alterado para %s.</strong><p>Se não foi você, responda esta mensagem.</p>",
+		html.EscapeString(newEmail))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendDataExportReadyEmail(destinationEmail, downloadURL, expiresAt string) error {
+	subject := "Seus dados estão prontos para download"
+	plainTextContent := fmt.Sprintf("A cópia dos seus dados na Escola do Jogo está pronta e pode ser baixada até %s: %s", expiresAt, downloadURL)
+	htmlContent := fmt.Sprintf("<strong>A cópia dos seus dados está pronta.</strong><p><a href=\"%s\">Baixar meus dados</a></p><p>O link fica disponível até %s.</p>",
+		html.EscapeString(downloadURL), html.EscapeString(expiresAt))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
+
+func (s *Sender) SendAccountLockedEmail(destinationEmail, lockedUntil, ipAddress string) error {
+	subject := "Sua conta foi bloqueada temporariamente"
+	plainTextContent := fmt.Sprintf("Houve várias tentativas de entrar na sua conta da Escola do Jogo com a senha errada, a última a partir do endereço %s. Por segurança, a conta ficará bloqueada até %s. Se não foi você, troque sua senha assim que puder.",
+		ipAddress, lockedUntil)
+	htmlContent := fmt.Sprintf("<strong>Sua conta foi bloqueada temporariamente.</strong><p>Houve várias tentativas de entrar com a senha errada, a última a partir do endereço %s. Por segurança, a conta ficará bloqueada até %s.</p><p>Se não foi você, troque sua senha assim que puder.</p>",
+		html.EscapeString(ipAddress), html.EscapeString(lockedUntil))
+
+	return s.send(destinationEmail, subject, plainTextContent, htmlContent)
+}
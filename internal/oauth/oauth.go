@@ -0,0 +1,289 @@
+// Package oauth signs users in through external identity providers with
+// the OAuth 2.0 authorization code flow and PKCE. OpenID Connect providers
+// are configured from their issuer's discovery document; GitHub, which is
+// plain OAuth, has its endpoints and user lookup built in.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	KindOIDC   = "oidc"
+	KindGitHub = "github"
+)
+
+// ErrEmailNotVerified is returned when the provider can't vouch for the
+// user's email, which is what accounts are linked by.
+var ErrEmailNotVerified = errors.New("the provider has no verified email for this account")
+
+// Identity is who the provider says signed in.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is one configured identity provider.
+type Provider struct {
+	Name         string
+	Kind         string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// Issuer is where OIDC providers publish their discovery document.
+	Issuer string
+
+	// The endpoints are discovered for OIDC providers and must be set for
+	// the others.
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	// APIURL is the base of the GitHub API.
+	APIURL string
+
+	mu         sync.Mutex
+	discovered bool
+}
+
+// NewGoogle configures Google as an OIDC provider.
+func NewGoogle(clientID, clientSecret, issuer string) *Provider {
+	return &Provider{
+		Name:         "google",
+		Kind:         KindOIDC,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+		Issuer:       issuer,
+	}
+}
+
+// NewGitHub configures a GitHub OAuth app. webURL is github.com, or the
+// address of an enterprise server or a stub in tests.
+func NewGitHub(clientID, clientSecret, webURL, apiURL string) *Provider {
+	webURL = strings.TrimRight(webURL, "/")
+	return &Provider{
+		Name:         "github",
+		Kind:         KindGitHub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"read:user", "user:email"},
+		AuthURL:      webURL + "/login/oauth/authorize",
+		TokenURL:     webURL + "/login/oauth/access_token",
+		APIURL:       strings.TrimRight(apiURL, "/"),
+	}
+}
+
+// NewVerifier returns a random PKCE code verifier.
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating code verifier: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge derives the S256 PKCE challenge of a verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Discover loads the endpoints of an OIDC provider. It only fetches the
+// discovery document until it succeeds once.
+func (p *Provider) Discover(ctx context.Context, client *http.Client) error {
+	if p.Kind != KindOIDC {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered {
+		return nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+
+	issuer := strings.TrimRight(p.Issuer, "/")
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return fmt.Errorf("discovering %s: %w", p.Name, err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != issuer {
+		return fmt.Errorf("discovering %s: document is for issuer %q", p.Name, doc.Issuer)
+	}
+
+	p.AuthURL, p.TokenURL, p.UserInfoURL = doc.AuthorizationEndpoint, doc.TokenEndpoint, doc.UserinfoEndpoint
+	p.discovered = true
+
+	return nil
+}
+
+// AuthCodeURL is where the user is sent to sign in with the provider.
+func (p *Provider) AuthCodeURL(state, verifier, redirectURI string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", Challenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		separator = "&"
+	}
+
+	return p.AuthURL + separator + params.Encode()
+}
+
+// Exchange trades the code the provider sent back for an access token.
+func (p *Provider) Exchange(ctx context.Context, client *http.Client, code, verifier, redirectURI string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := doJSON(client, req, &token); err != nil {
+		return "", fmt.Errorf("exchanging code with %s: %w", p.Name, err)
+	}
+
+	// GitHub reports errors with a 200 status
+	if token.Error != "" {
+		return "", fmt.Errorf("exchanging code with %s: %s: %s", p.Name, token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("exchanging code with %s: no access token in response", p.Name)
+	}
+
+	return token.AccessToken, nil
+}
+
+// Identity looks up who the access token belongs to.
+func (p *Provider) Identity(ctx context.Context, client *http.Client, accessToken string) (Identity, error) {
+	if p.Kind == KindGitHub {
+		return p.gitHubIdentity(ctx, client, accessToken)
+	}
+
+	var info struct {
+		Subject       string          `json:"sub"`
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		Name          string          `json:"name"`
+	}
+	if err := getJSON(ctx, client, p.UserInfoURL, accessToken, &info); err != nil {
+		return Identity{}, fmt.Errorf("fetching %s user info: %w", p.Name, err)
+	}
+	if info.Subject == "" {
+		return Identity{}, fmt.Errorf("fetching %s user info: no subject", p.Name)
+	}
+
+	// Some providers send the flag as a string
+	verified, _ := strconv.ParseBool(strings.Trim(string(info.EmailVerified), `"`))
+
+	return Identity{Subject: info.Subject, Email: info.Email, EmailVerified: verified, Name: info.Name}, nil
+}
+
+func (p *Provider) gitHubIdentity(ctx context.Context, client *http.Client, accessToken string) (Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, p.APIURL+"/user", accessToken, &user); err != nil {
+		return Identity{}, fmt.Errorf("fetching github user: %w", err)
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, p.APIURL+"/user/emails", accessToken, &emails); err != nil {
+		return Identity{}, fmt.Errorf("fetching github emails: %w", err)
+	}
+
+	identity := Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email, identity.EmailVerified = email.Email, email.Verified
+		}
+	}
+
+	return identity, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, address, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	return doJSON(client, req, v)
+}
+
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	// Token endpoints describe errors in a JSON body sent with a 400
+	if resp.StatusCode != http.StatusOK && !(resp.StatusCode == http.StatusBadRequest && req.Method == http.MethodPost) {
+		return fmt.Errorf("%s %s answered %d", req.Method, req.URL.Redacted(), resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubProvider is a minimal OIDC and GitHub provider. It hands out the code
+// "good-code" for the challenge it was last sent and only honours it with
+// the matching verifier.
+type stubProvider struct {
+	*httptest.Server
+	challenge string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	stub := &stubProvider{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 stub.URL,
+			"authorization_endpoint": stub.URL + "/authorize",
+			"token_endpoint":         stub.URL + "/token",
+			"userinfo_endpoint":      stub.URL + "/userinfo",
+		})
+	})
+	authorize := func(w http.ResponseWriter, r *http.Request) {
+		stub.challenge = r.URL.Query().Get("code_challenge")
+	}
+	mux.HandleFunc("/authorize", authorize)
+	mux.HandleFunc("/login/oauth/authorize", authorize)
+	token := func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("Failed to parse token request: %v", err)
+		}
+		if r.PostForm.Get("code") != "good-code" || Challenge(r.PostForm.Get("code_verifier")) != stub.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer"})
+	}
+	mux.HandleFunc("/token", token)
+	mux.HandleFunc("/login/oauth/access_token", token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"sub": "1234", "email": "ana@test.com", "email_verified": true, "name": "Ana"}`))
+	})
+	mux.HandleFunc("/api/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": 42, "login": "ana", "name": ""}`))
+	})
+	mux.HandleFunc("/api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"email": "old@test.com", "primary": false, "verified": true},
+			{"email": "ana@test.com", "primary": true, "verified": true}]`))
+	})
+
+	stub.Server = httptest.NewServer(mux)
+	t.Cleanup(stub.Close)
+
+	return stub
+}
+
+// authorize follows the authorization URL the way a browser would.
+func (stub *stubProvider) authorize(t *testing.T, authURL string) {
+	resp, err := stub.Client().Get(authURL)
+	if err != nil {
+		t.Fatalf("Failed to authorize: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestOIDCFlow(t *testing.T) {
+	stub := newStubProvider(t)
+	provider := NewGoogle("client", "secret", stub.URL)
+	ctx := context.Background()
+
+	if err := provider.Discover(ctx, stub.Client()); err != nil {
+		t.Fatalf("Failed to discover: %v", err)
+	}
+	assert.Equal(t, stub.URL+"/token", provider.TokenURL)
+
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	authURL := provider.AuthCodeURL("state", verifier, "http://localhost/callback")
+
+	parsed, _ := url.Parse(authURL)
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	assert.Equal(t, "state", parsed.Query().Get("state"))
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	stub.authorize(t, authURL)
+
+	_, err = provider.Exchange(ctx, stub.Client(), "good-code", "wrong-verifier", "http://localhost/callback")
+	assert.ErrorContains(t, err, "invalid_grant", "PKCE verifiers must match")
+
+	accessToken, err := provider.Exchange(ctx, stub.Client(), "good-code", verifier, "http://localhost/callback")
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+
+	identity, err := provider.Identity(ctx, stub.Client(), accessToken)
+	if err != nil {
+		t.Fatalf("Failed to get identity: %v", err)
+	}
+	assert.Equal(t, Identity{Subject: "1234", Email: "ana@test.com", EmailVerified: true, Name: "Ana"}, identity)
+}
+
+func TestDiscoverRejectsOtherIssuer(t *testing.T) {
+	stub := newStubProvider(t)
+	provider := NewGoogle("client", "secret", stub.URL+"/other")
+
+	assert.Error(t, provider.Discover(context.Background(), stub.Client()))
+}
+
+func TestGitHubFlow(t *testing.T) {
+	stub := newStubProvider(t)
+	provider := NewGitHub("client", "secret", stub.URL, stub.URL+"/api")
+	ctx := context.Background()
+
+	verifier, _ := NewVerifier()
+	stub.authorize(t, provider.AuthCodeURL("state", verifier, "http://localhost/callback"))
+
+	accessToken, err := provider.Exchange(ctx, stub.Client(), "good-code", verifier, "http://localhost/callback")
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+
+	identity, err := provider.Identity(ctx, stub.Client(), accessToken)
+	if err != nil {
+		t.Fatalf("Failed to get identity: %v", err)
+	}
+	assert.Equal(t, Identity{Subject: "42", Email: "ana@test.com", EmailVerified: true, Name: "ana"}, identity)
+}
+
+func TestChallenge(t *testing.T) {
+	// Example from RFC 7636, appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
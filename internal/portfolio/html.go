@@ -0,0 +1,100 @@
+// Package portfolio renders public student portfolios as HTML pages that
+// link previews can read through their OpenGraph tags.
+package portfolio
+
+import (
+	"bytes"
+	"fmt"
+	"game-student-go/internal/model"
+	"html/template"
+)
+
+// sections lists the item kinds in page order with their headings.
+var sections = []struct {
+	Kind    string
+	Heading string
+}{
+	{model.PortfolioSubmission, "Projetos"},
+	{model.PortfolioJamEntry, "Game jams"},
+	{model.PortfolioCertificate, "Certificados"},
+	{model.PortfolioBadge, "Conquistas"},
+}
+
+type section struct {
+	Heading string
+	Items   []model.PortfolioItem
+}
+
+type page struct {
+	model.Portfolio
+	URL         string
+	Description string
+	Sections    []section
+}
+
+var pageTemplate = template.Must(template.New("portfolio").Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.DisplayName}} · Escola do Jogo</title>
+<meta name="description" content="{{.Description}}">
+<link rel="canonical" href="{{.URL}}">
+<meta property="og:type" content="profile">
+<meta property="og:site_name" content="Escola do Jogo">
+<meta property="og:title" content="{{.DisplayName}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+<meta name="twitter:card" content="summary">
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2rem auto; padding: 0 1rem; color: #222; }
+li { margin-bottom: .75rem; }
+.subtitle, time { color: #666; font-size: .9rem; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.DisplayName}}</h1>
+{{if .Headline}}<p>{{.Headline}}</p>{{end}}
+</header>
+{{range .Sections}}
+<section>
+<h2>{{.Heading}}</h2>
+<ul>
+{{range .Items}}<li>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}{{if .Subtitle}} <span class="subtitle">{{.Subtitle}}</span>{{end}} <time datetime="{{.Date.Format "2006-01-02"}}">{{.Date.Format "02/01/2006"}}</time></li>
+{{end}}</ul>
+</section>
+{{else}}
+<p>Nenhum trabalho publicado ainda.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML renders the visible items of a portfolio grouped by kind.
+// pageURL is the portfolio's public address, used as its canonical URL.
+func RenderHTML(portfolio model.Portfolio, pageURL string) ([]byte, error) {
+	data := page{Portfolio: portfolio, URL: pageURL, Description: portfolio.Headline}
+	if data.Description == "" {
+		data.Description = fmt.Sprintf("Portfólio de %s na Escola do Jogo", portfolio.DisplayName)
+	}
+
+	for _, s := range sections {
+		var items []model.PortfolioItem
+		for _, item := range portfolio.Items {
+			if item.Kind == s.Kind && item.Visible {
+				items = append(items, item)
+			}
+		}
+		if len(items) > 0 {
+			data.Sections = append(data.Sections, section{Heading: s.Heading, Items: items})
+		}
+	}
+
+	var b bytes.Buffer
+	if err := pageTemplate.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("rendering portfolio: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+package portfolio
+
+import (
+	"game-student-go/internal/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderHTML(t *testing.T) {
+	date := time.Date(2024, 4, 2, 15, 0, 0, 0, time.UTC)
+	portfolio := model.Portfolio{
+		Slug:        "ana",
+		DisplayName: "Ana <Dev>",
+		Headline:    "Game designer",
+		Items: []model.PortfolioItem{
+			{Kind: model.PortfolioCertificate, Key: "ABC", Title: "Godot Basics", URL: "https://example.com/certificates/ABC", Date: date, Visible: true},
+			{Kind: model.PortfolioSubmission, Key: "1", Title: "Platformer", Subtitle: "Godot Basics", URL: "https://example.com/game", Date: date, Visible: true},
+			{Kind: model.PortfolioBadge, Key: "streak_7", Title: "Uma semana seguida", Date: date, Visible: false},
+		},
+	}
+
+	html, err := RenderHTML(portfolio, "https://example.com/portfolio/ana")
+	if err != nil {
+		t.Fatalf("Failed to render portfolio: %v", err)
+	}
+	page := string(html)
+
+	assert.Contains(t, page, `<meta property="og:title" content="Ana &lt;Dev&gt;">`)
+	assert.Contains(t, page, `<meta property="og:description" content="Game designer">`)
+	assert.Contains(t, page, `<meta property="og:url" content="https://example.com/portfolio/ana">`)
+	assert.Contains(t, page, `<a href="https://example.com/game">Platformer</a>`)
+	assert.Contains(t, page, `<time datetime="2024-04-02">02/04/2024</time>`)
+	assert.NotContains(t, page, "<Dev>")
+	assert.NotContains(t, page, "Conquistas", "Hidden items must not be rendered")
+	assert.Less(t, strings.Index(page, "Projetos"), strings.Index(page, "Certificados"))
+}
+
+func TestRenderHTMLWithoutItems(t *testing.T) {
+	html, err := RenderHTML(model.Portfolio{DisplayName: "Ana"}, "https://example.com/portfolio/ana")
+	if err != nil {
+		t.Fatalf("Failed to render portfolio: %v", err)
+	}
+
+	assert.Contains(t, string(html), `content="Portfólio de Ana na Escola do Jogo"`)
+	assert.Contains(t, string(html), "Nenhum trabalho publicado ainda.")
+}
@@ -0,0 +1,74 @@
+// Package privacy packs a user's personal data into the archive handed out
+// for data portability requests.
+package privacy
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"game-student-go/internal/model"
+	"io"
+	"time"
+)
+
+const readme = `Estes são os dados pessoais que a Escola do Jogo mantém sobre você,
+gerados em %s.
+
+Cada arquivo JSON traz uma parte dos dados:
+
+profile.json          seu cadastro
+payments.json         pagamentos realizados
+cards.json            cartões salvos (apenas bandeira, final e validade)
+progress.json         progresso nas aulas
+submissions.json      projetos enviados e suas revisões
+reviews.json          avaliações de cursos
+certificates.json     certificados emitidos
+notes.json            anotações nas aulas
+linked_accounts.json  contas do Google e do GitHub usadas para entrar
+`
+
+// WriteExport writes data to w as a zip archive with one JSON file per kind
+// of data and a README describing them.
+func WriteExport(w io.Writer, data model.PersonalData, generatedAt time.Time) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"payments.json", data.Payments},
+		{"cards.json", data.Cards},
+		{"progress.json", data.Progress},
+		{"submissions.json", data.Submissions},
+		{"reviews.json", data.Reviews},
+		{"certificates.json", data.Certificates},
+		{"notes.json", data.Notes},
+		{"linked_accounts.json", data.Identities},
+	}
+
+	header := &zip.FileHeader{Name: "README.txt", Method: zip.Deflate, Modified: generatedAt}
+	f, err := archive.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("adding README.txt: %w", err)
+	}
+	if _, err := fmt.Fprintf(f, readme, generatedAt.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("writing README.txt: %w", err)
+	}
+
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: generatedAt}
+		f, err := archive.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("adding %s: %w", file.name, err)
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return fmt.Errorf("writing %s: %w", file.name, err)
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,56 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"game-student-go/internal/model"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteExport(t *testing.T) {
+	data := model.PersonalData{
+		Profile:  model.User{ID: 7, Email: "ana@test.com", Password: "hash", DisplayName: "Ana"},
+		Payments: []model.Payment{{ID: 1, Amount: 4990, Currency: "brl"}},
+		Cards:    []model.Card{{Brand: "visa", LastFour: "4242"}},
+	}
+
+	var b bytes.Buffer
+	if err := WriteExport(&b, data, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		files[f.Name] = content
+	}
+
+	assert.Len(t, files, 10)
+	assert.Contains(t, string(files["README.txt"]), "2024-05-01T12:00:00Z")
+
+	var profile map[string]interface{}
+	if err := json.Unmarshal(files["profile.json"], &profile); err != nil {
+		t.Fatalf("Failed to decode profile: %v", err)
+	}
+	assert.Equal(t, "ana@test.com", profile["email"])
+	assert.NotContains(t, string(files["profile.json"]), "hash", "Password hashes are never exported")
+	assert.Contains(t, string(files["cards.json"]), `"last_four": "4242"`)
+}
@@ -0,0 +1,114 @@
+package quizzes
+
+import (
+	"errors"
+	"fmt"
+	"game-student-go/internal/model"
+	"math"
+)
+
+// Validate checks a quiz definition before it is stored.
+func Validate(quiz model.Quiz) error {
+	if quiz.PassingScore < 0 || quiz.PassingScore > 100 {
+		return errors.New("passing_score must be between 0 and 100")
+	}
+
+	if quiz.MaxAttempts < 0 {
+		return errors.New("max_attempts must not be negative")
+	}
+
+	if len(quiz.Questions) == 0 {
+		return errors.New("quiz must have at least one question")
+	}
+
+	for i, question := range quiz.Questions {
+		if question.Prompt == "" {
+			return fmt.Errorf("question %d: prompt is required", i+1)
+		}
+
+		if question.Points < 0 {
+			return fmt.Errorf("question %d: points must not be negative", i+1)
+		}
+
+		correct := 0
+		for _, option := range question.Options {
+			if option.Correct {
+				correct++
+			}
+		}
+
+		switch question.Kind {
+		case model.QuestionSingleChoice:
+			if len(question.Options) < 2 || correct != 1 {
+				return fmt.Errorf("question %d: single choice needs at least two options and exactly one correct", i+1)
+			}
+		case model.QuestionMultipleChoice:
+			if len(question.Options) < 2 || correct == 0 {
+				return fmt.Errorf("question %d: multiple choice needs at least two options and one correct", i+1)
+			}
+		case model.QuestionNumeric:
+			if question.CorrectNumber == nil || len(question.Options) > 0 || question.Tolerance < 0 {
+				return fmt.Errorf("question %d: numeric questions need a correct_number, a non negative tolerance and no options", i+1)
+			}
+		default:
+			return fmt.Errorf("question %d: unknown kind %q", i+1, question.Kind)
+		}
+	}
+
+	return nil
+}
+
+// Grade scores answers against the quiz as a percentage of the available
+// points. Choice questions are all or nothing: every correct option and no
+// wrong one must be picked.
+func Grade(quiz model.Quiz, answers []model.QuizAnswer) (score int, passed bool) {
+	byQuestion := make(map[int]model.QuizAnswer, len(answers))
+	for _, answer := range answers {
+		byQuestion[answer.QuestionID] = answer
+	}
+
+	total, earned := 0, 0
+	for _, question := range quiz.Questions {
+		total += question.Points
+		answer, ok := byQuestion[question.ID]
+		if ok && isCorrect(question, answer) {
+			earned += question.Points
+		}
+	}
+
+	if total == 0 {
+		return 100, true
+	}
+
+	score = earned * 100 / total
+	return score, score >= quiz.PassingScore
+}
+
+func isCorrect(question model.QuizQuestion, answer model.QuizAnswer) bool {
+	if question.Kind == model.QuestionNumeric {
+		return answer.Number != nil && question.CorrectNumber != nil &&
+			math.Abs(*answer.Number-*question.CorrectNumber) <= question.Tolerance
+	}
+
+	chosen := make(map[int]bool, len(answer.OptionIDs))
+	for _, id := range answer.OptionIDs {
+		chosen[id] = true
+	}
+
+	if question.Kind == model.QuestionSingleChoice && len(chosen) != 1 {
+		return false
+	}
+
+	matched := 0
+	for _, option := range question.Options {
+		if option.Correct != chosen[option.ID] {
+			return false
+		}
+		if chosen[option.ID] {
+			matched++
+		}
+	}
+
+	// Reject options that do not belong to the question
+	return matched == len(chosen)
+}
@@ -0,0 +1,66 @@
+package quizzes
+
+import (
+	"game-student-go/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+var quiz = model.Quiz{
+	PassingScore: 60,
+	Questions: []model.QuizQuestion{
+		{ID: 1, Kind: model.QuestionSingleChoice, Prompt: "Which engine uses GDScript?", Points: 1, Options: []model.QuizOption{
+			{ID: 1, Text: "Unity"}, {ID: 2, Text: "Godot", Correct: true},
+		}},
+		{ID: 2, Kind: model.QuestionMultipleChoice, Prompt: "Which are sprite editors?", Points: 1, Options: []model.QuizOption{
+			{ID: 3, Text: "Aseprite", Correct: true}, {ID: 4, Text: "Blender"}, {ID: 5, Text: "Pyxel Edit", Correct: true},
+		}},
+		{ID: 3, Kind: model.QuestionNumeric, Prompt: "Frames per second for smooth play?", Points: 2, CorrectNumber: float(60), Tolerance: 0.5},
+	},
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(quiz))
+
+	invalid := quiz
+	invalid.Questions = []model.QuizQuestion{{Kind: model.QuestionSingleChoice, Prompt: "?", Options: []model.QuizOption{
+		{Text: "a", Correct: true}, {Text: "b", Correct: true},
+	}}}
+	assert.Error(t, Validate(invalid))
+
+	assert.Error(t, Validate(model.Quiz{PassingScore: 50}))
+}
+
+func TestGrade(t *testing.T) {
+	score, passed := Grade(quiz, []model.QuizAnswer{
+		{QuestionID: 1, OptionIDs: []int{2}},
+		{QuestionID: 2, OptionIDs: []int{3, 5}},
+		{QuestionID: 3, Number: float(60.2)},
+	})
+	assert.Equal(t, 100, score)
+	assert.True(t, passed)
+
+	score, passed = Grade(quiz, []model.QuizAnswer{
+		{QuestionID: 1, OptionIDs: []int{2}},
+		{QuestionID: 2, OptionIDs: []int{3}},
+	})
+	assert.Equal(t, 25, score)
+	assert.False(t, passed)
+
+	// Options from another question do not count
+	score, _ = Grade(quiz, []model.QuizAnswer{{QuestionID: 1, OptionIDs: []int{3}}})
+	assert.Equal(t, 0, score)
+}
+
+func TestWithoutAnswers(t *testing.T) {
+	public := quiz.WithoutAnswers()
+
+	assert.False(t, public.Questions[0].Options[1].Correct)
+	assert.Nil(t, public.Questions[2].CorrectNumber)
+	assert.True(t, quiz.Questions[0].Options[1].Correct, "original quiz must keep its answers")
+}
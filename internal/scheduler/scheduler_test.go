@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	var runs, failures int32
+	jobs := New()
+	jobs.Every("count", 10*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	jobs.Every("fail", 10*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&failures, 1)
+		return errors.New("boom")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+	jobs.Start(ctx)
+	jobs.Wait()
+
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&runs), int32(3))
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&failures), int32(3), "failing jobs keep running")
+}
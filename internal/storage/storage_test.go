@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStorage(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if err := store.Save("submissions/1/build.zip", strings.NewReader("content")); err != nil {
+		t.Fatalf("Failed to save file: %v", err)
+	}
+
+	f, err := store.Open("submissions/1/build.zip")
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	content, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	assert.Equal(t, "content", string(content))
+
+	if err := store.Delete("submissions/1/build.zip"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	_, err = store.Open("submissions/1/build.zip")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Error(t, store.Save("../escape", strings.NewReader("content")))
+}
@@ -0,0 +1,92 @@
+package totp
+
+import (
+	"encoding/base32"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// The RFC vectors have eight digits; these are their last six
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		code, err := Code(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("Failed to compute code: %v", err)
+		}
+		assert.Equal(t, want, code, "time %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	step, ok := Validate(rfcSecret, "050471", now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, current, step)
+
+	previous, _ := Code(rfcSecret, current-1)
+	_, ok = Validate(rfcSecret, previous, now, 0)
+	assert.True(t, ok, "One step of drift is tolerated")
+
+	old, _ := Code(rfcSecret, current-2)
+	_, ok = Validate(rfcSecret, old, now, 0)
+	assert.False(t, ok)
+
+	_, ok = Validate(rfcSecret, "050471", now, current)
+	assert.False(t, ok, "Used steps are refused")
+
+	_, ok = Validate(rfcSecret, "050 471", now, 0)
+	assert.True(t, ok, "Spaces are ignored")
+
+	_, ok = Validate(rfcSecret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+
+	assert.Len(t, secret, 32)
+	_, err = Code(secret, 1)
+	assert.NoError(t, err)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Escola do Jogo", "ana@test.com", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Escola%20do%20Jogo:ana@test.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Escola%20do%20Jogo")
+	assert.Contains(t, uri, "digits=6")
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("Failed to generate recovery codes: %v", err)
+	}
+
+	assert.Len(t, codes, 10)
+	for _, code := range codes {
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{5}-[0-9a-f]{5}$`), code)
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE training_progress;
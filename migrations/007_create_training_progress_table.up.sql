@@ -0,0 +1,9 @@
+CREATE TABLE training_progress (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    training_id INTEGER REFERENCES trainings(id) ON DELETE CASCADE NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    position_seconds INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, training_id)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE certificates (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(32) UNIQUE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    student_name VARCHAR(255) NOT NULL,
+    course_name VARCHAR(255) NOT NULL,
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_reason TEXT,
+    UNIQUE (user_id, course_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE submissions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    training_id INTEGER REFERENCES trainings(id) ON DELETE CASCADE NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    attachment_key VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    feedback TEXT,
+    reviewer_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    submitted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT submissions_status_check CHECK (status IN ('pending', 'approved', 'changes_requested')),
+    UNIQUE (user_id, training_id)
+);
+
+CREATE INDEX submissions_status_idx ON submissions (status, submitted_at);
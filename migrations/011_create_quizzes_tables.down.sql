@@ -0,0 +1,4 @@
+DROP TABLE quiz_attempts;
+DROP TABLE quiz_options;
+DROP TABLE quiz_questions;
+DROP TABLE quizzes;
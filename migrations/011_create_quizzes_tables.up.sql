@@ -0,0 +1,40 @@
+CREATE TABLE quizzes (
+    id SERIAL PRIMARY KEY,
+    training_id INTEGER UNIQUE REFERENCES trainings(id) ON DELETE CASCADE NOT NULL,
+    passing_score INTEGER NOT NULL DEFAULT 70,
+    max_attempts INTEGER NOT NULL DEFAULT 0,
+    required_for_completion BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT quizzes_passing_score_check CHECK (passing_score BETWEEN 0 AND 100)
+);
+
+CREATE TABLE quiz_questions (
+    id SERIAL PRIMARY KEY,
+    quiz_id INTEGER REFERENCES quizzes(id) ON DELETE CASCADE NOT NULL,
+    position INTEGER NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    prompt TEXT NOT NULL,
+    points INTEGER NOT NULL DEFAULT 1,
+    correct_number DOUBLE PRECISION,
+    tolerance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    CONSTRAINT quiz_questions_kind_check CHECK (kind IN ('single', 'multiple', 'numeric'))
+);
+
+CREATE TABLE quiz_options (
+    id SERIAL PRIMARY KEY,
+    question_id INTEGER REFERENCES quiz_questions(id) ON DELETE CASCADE NOT NULL,
+    position INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    correct BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE quiz_attempts (
+    id SERIAL PRIMARY KEY,
+    quiz_id INTEGER REFERENCES quizzes(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    score INTEGER NOT NULL,
+    passed BOOLEAN NOT NULL,
+    answers JSONB NOT NULL,
+    submitted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX quiz_attempts_user_idx ON quiz_attempts (quiz_id, user_id);
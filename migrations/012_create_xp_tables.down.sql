@@ -0,0 +1,5 @@
+DROP TABLE user_badges;
+DROP TABLE user_xp;
+DROP TRIGGER xp_ledger_no_update ON xp_ledger;
+DROP FUNCTION xp_ledger_immutable;
+DROP TABLE xp_ledger;
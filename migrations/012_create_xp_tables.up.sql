@@ -0,0 +1,33 @@
+CREATE TABLE xp_ledger (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    amount INTEGER NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    source_id INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, reason, source_id)
+);
+
+-- Grants are never edited. Each source grants XP once, so a wrong grant is
+-- deleted rather than corrected, and totals are rebuilt from the ledger.
+CREATE FUNCTION xp_ledger_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'xp_ledger entries cannot be updated';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER xp_ledger_no_update BEFORE UPDATE ON xp_ledger
+    FOR EACH ROW EXECUTE FUNCTION xp_ledger_immutable();
+
+CREATE TABLE user_xp (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    total_xp INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_badges (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    badge VARCHAR(50) NOT NULL,
+    awarded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, badge)
+);
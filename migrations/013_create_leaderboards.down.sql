@@ -0,0 +1,10 @@
+DROP TABLE leaderboard_entries;
+ALTER TABLE users DROP COLUMN leaderboard_opt_out;
+DROP INDEX xp_ledger_created_at_idx;
+ALTER TABLE xp_ledger DROP COLUMN course_id;
+
+CREATE OR REPLACE FUNCTION xp_ledger_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'xp_ledger entries cannot be updated';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,50 @@
+ALTER TABLE xp_ledger ADD COLUMN course_id INTEGER REFERENCES courses(id) ON DELETE SET NULL;
+
+-- Grants stay immutable, except for losing their course when it is deleted.
+CREATE OR REPLACE FUNCTION xp_ledger_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.course_id IS NULL AND OLD.course_id IS NOT NULL
+        AND (NEW.id, NEW.user_id, NEW.amount, NEW.reason, NEW.source_id, NEW.created_at)
+            = (OLD.id, OLD.user_id, OLD.amount, OLD.reason, OLD.source_id, OLD.created_at) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'xp_ledger entries cannot be updated';
+END;
+$$ LANGUAGE plpgsql;
+
+-- The backfill is the one edit grants ever get.
+ALTER TABLE xp_ledger DISABLE TRIGGER xp_ledger_no_update;
+
+UPDATE xp_ledger l SET course_id = t.course_id
+FROM trainings t
+WHERE l.reason = 'training_completed' AND t.id = l.source_id;
+
+UPDATE xp_ledger l SET course_id = t.course_id
+FROM quizzes q JOIN trainings t ON t.id = q.training_id
+WHERE l.reason = 'quiz_passed' AND q.id = l.source_id;
+
+UPDATE xp_ledger l SET course_id = t.course_id
+FROM submissions s JOIN trainings t ON t.id = s.training_id
+WHERE l.reason = 'submission_approved' AND s.id = l.source_id;
+
+ALTER TABLE xp_ledger ENABLE TRIGGER xp_ledger_no_update;
+
+CREATE INDEX xp_ledger_created_at_idx ON xp_ledger (created_at);
+
+ALTER TABLE users ADD COLUMN leaderboard_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Rankings are materialized by a background job. All time rankings use the
+-- 1970-01-01 season, weekly ones the monday their season started.
+CREATE TABLE leaderboard_entries (
+    scope VARCHAR(20) NOT NULL,
+    scope_id INTEGER NOT NULL,
+    period VARCHAR(20) NOT NULL,
+    season_start DATE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    xp INTEGER NOT NULL,
+    rank INTEGER NOT NULL,
+    refreshed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, scope_id, period, season_start, user_id)
+);
+
+CREATE INDEX leaderboard_entries_rank_idx ON leaderboard_entries (scope, scope_id, period, season_start, rank);
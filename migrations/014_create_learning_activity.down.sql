@@ -0,0 +1,3 @@
+DROP TABLE streak_reminders;
+DROP TABLE learning_activity;
+ALTER TABLE users DROP COLUMN time_zone;
@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN time_zone VARCHAR(64) NOT NULL DEFAULT 'America/Sao_Paulo';
+
+-- One row per student and local calendar day.
+CREATE TABLE learning_activity (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    day DATE NOT NULL,
+    trainings_completed INTEGER NOT NULL DEFAULT 0,
+    quizzes_passed INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE TABLE streak_reminders (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    day DATE NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, day)
+);
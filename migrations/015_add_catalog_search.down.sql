@@ -0,0 +1,6 @@
+DROP INDEX trainings_name_trgm_idx;
+DROP INDEX courses_name_trgm_idx;
+DROP INDEX trainings_search_vector_idx;
+DROP INDEX courses_search_vector_idx;
+ALTER TABLE trainings DROP COLUMN search_vector;
+ALTER TABLE courses DROP COLUMN search_vector;
@@ -0,0 +1,21 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Indexed in both Portuguese and English since the catalog mixes the two.
+ALTER TABLE courses ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('portuguese', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('portuguese', coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+ALTER TABLE trainings ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('portuguese', name), 'A') ||
+    setweight(to_tsvector('english', name), 'A') ||
+    setweight(to_tsvector('portuguese', topic), 'B') ||
+    setweight(to_tsvector('english', topic), 'B')
+) STORED;
+
+CREATE INDEX courses_search_vector_idx ON courses USING GIN (search_vector);
+CREATE INDEX trainings_search_vector_idx ON trainings USING GIN (search_vector);
+CREATE INDEX courses_name_trgm_idx ON courses USING GIN (name gin_trgm_ops);
+CREATE INDEX trainings_name_trgm_idx ON trainings USING GIN (name gin_trgm_ops);
@@ -0,0 +1,7 @@
+DROP INDEX courses_category_idx;
+ALTER TABLE courses DROP CONSTRAINT courses_difficulty_check;
+ALTER TABLE courses DROP COLUMN difficulty;
+ALTER TABLE courses DROP COLUMN category_id;
+DROP TABLE course_tags;
+DROP TABLE tags;
+DROP TABLE categories;
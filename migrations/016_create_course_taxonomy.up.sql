@@ -0,0 +1,28 @@
+CREATE TABLE categories (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL
+);
+
+INSERT INTO categories (slug, name) VALUES
+    ('unity', 'Unity'),
+    ('godot', 'Godot'),
+    ('pixel-art', 'Pixel Art'),
+    ('game-design', 'Game Design');
+
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE course_tags (
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    tag_id INTEGER REFERENCES tags(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (course_id, tag_id)
+);
+
+ALTER TABLE courses ADD COLUMN category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+ALTER TABLE courses ADD COLUMN difficulty VARCHAR(20);
+ALTER TABLE courses ADD CONSTRAINT courses_difficulty_check CHECK (difficulty IN ('beginner', 'intermediate', 'advanced'));
+
+CREATE INDEX courses_category_idx ON courses (category_id);
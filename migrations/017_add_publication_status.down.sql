@@ -0,0 +1,13 @@
+DROP INDEX trainings_scheduled_idx;
+DROP INDEX courses_scheduled_idx;
+ALTER TABLE trainings DROP CONSTRAINT trainings_publish_at_check;
+ALTER TABLE trainings DROP CONSTRAINT trainings_status_check;
+ALTER TABLE trainings DROP COLUMN published_at;
+ALTER TABLE trainings DROP COLUMN publish_at;
+ALTER TABLE trainings DROP COLUMN status;
+ALTER TABLE courses DROP CONSTRAINT courses_publish_at_check;
+ALTER TABLE courses DROP CONSTRAINT courses_status_check;
+ALTER TABLE courses DROP COLUMN announced_at;
+ALTER TABLE courses DROP COLUMN published_at;
+ALTER TABLE courses DROP COLUMN publish_at;
+ALTER TABLE courses DROP COLUMN status;
@@ -0,0 +1,19 @@
+ALTER TABLE courses ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft';
+ALTER TABLE courses ADD COLUMN publish_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE courses ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE courses ADD COLUMN announced_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE courses ADD CONSTRAINT courses_status_check CHECK (status IN ('draft', 'scheduled', 'published', 'archived'));
+ALTER TABLE courses ADD CONSTRAINT courses_publish_at_check CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+ALTER TABLE trainings ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft';
+ALTER TABLE trainings ADD COLUMN publish_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE trainings ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE trainings ADD CONSTRAINT trainings_status_check CHECK (status IN ('draft', 'scheduled', 'published', 'archived'));
+ALTER TABLE trainings ADD CONSTRAINT trainings_publish_at_check CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+-- Everything already in the catalog stays live and is not announced again
+UPDATE courses SET status = 'published', published_at = CURRENT_TIMESTAMP, announced_at = CURRENT_TIMESTAMP;
+UPDATE trainings SET status = 'published', published_at = CURRENT_TIMESTAMP;
+
+CREATE INDEX courses_scheduled_idx ON courses (publish_at) WHERE status = 'scheduled';
+CREATE INDEX trainings_scheduled_idx ON trainings (publish_at) WHERE status = 'scheduled';
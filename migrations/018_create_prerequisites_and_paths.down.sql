@@ -0,0 +1,3 @@
+DROP TABLE learning_path_courses;
+DROP TABLE learning_paths;
+DROP TABLE course_prerequisites;
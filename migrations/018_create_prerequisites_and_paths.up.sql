@@ -0,0 +1,25 @@
+-- Required prerequisites block starting a course, the others only warn.
+CREATE TABLE course_prerequisites (
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    prerequisite_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (course_id, prerequisite_id),
+    CHECK (course_id <> prerequisite_id)
+);
+
+CREATE INDEX course_prerequisites_prerequisite_idx ON course_prerequisites (prerequisite_id);
+
+CREATE TABLE learning_paths (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE learning_path_courses (
+    path_id INTEGER REFERENCES learning_paths(id) ON DELETE CASCADE NOT NULL,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (path_id, course_id),
+    UNIQUE (path_id, position)
+);
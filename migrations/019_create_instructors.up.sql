@@ -0,0 +1,19 @@
+-- An instructor profile belongs to a user with the instructor role and
+-- shares its id.
+CREATE TABLE instructors (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    display_name VARCHAR(255) NOT NULL DEFAULT '',
+    bio TEXT NOT NULL DEFAULT '',
+    avatar_url VARCHAR(255) NOT NULL DEFAULT '',
+    social_links JSONB NOT NULL DEFAULT '{}',
+    payout_account VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE course_instructors (
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    instructor_id INTEGER REFERENCES instructors(user_id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (course_id, instructor_id)
+);
+
+CREATE INDEX course_instructors_instructor_idx ON course_instructors (instructor_id);
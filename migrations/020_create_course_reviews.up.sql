@@ -0,0 +1,33 @@
+CREATE TABLE course_reviews (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body TEXT NOT NULL DEFAULT '',
+    helpful_count INTEGER NOT NULL DEFAULT 0,
+    report_count INTEGER NOT NULL DEFAULT 0,
+    hidden_at TIMESTAMP WITH TIME ZONE,
+    reply TEXT,
+    reply_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    replied_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (course_id, user_id)
+);
+
+CREATE INDEX course_reviews_helpful_idx ON course_reviews (course_id, helpful_count, id) WHERE hidden_at IS NULL;
+CREATE INDEX course_reviews_created_idx ON course_reviews (course_id, created_at, id) WHERE hidden_at IS NULL;
+
+CREATE TABLE review_votes (
+    review_id INTEGER REFERENCES course_reviews(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (review_id, user_id)
+);
+
+CREATE TABLE review_reports (
+    review_id INTEGER REFERENCES course_reviews(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (review_id, user_id)
+);
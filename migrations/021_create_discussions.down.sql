@@ -0,0 +1,5 @@
+DROP TABLE answer_votes;
+DROP TABLE question_votes;
+ALTER TABLE questions DROP CONSTRAINT questions_accepted_answer_fkey;
+DROP TABLE answers;
+DROP TABLE questions;
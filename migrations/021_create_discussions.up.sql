@@ -0,0 +1,45 @@
+CREATE TABLE questions (
+    id SERIAL PRIMARY KEY,
+    training_id INTEGER REFERENCES trainings(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    upvotes INTEGER NOT NULL DEFAULT 0,
+    accepted_answer_id INTEGER,
+    locked_at TIMESTAMP WITH TIME ZONE,
+    hidden_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX questions_training_idx ON questions (training_id, created_at, id);
+
+-- Answers reply to the question or, through parent_id, to another answer.
+CREATE TABLE answers (
+    id SERIAL PRIMARY KEY,
+    question_id INTEGER REFERENCES questions(id) ON DELETE CASCADE NOT NULL,
+    parent_id INTEGER REFERENCES answers(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    body TEXT NOT NULL,
+    upvotes INTEGER NOT NULL DEFAULT 0,
+    hidden_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX answers_question_idx ON answers (question_id, created_at);
+
+ALTER TABLE questions ADD CONSTRAINT questions_accepted_answer_fkey
+    FOREIGN KEY (accepted_answer_id) REFERENCES answers(id) ON DELETE SET NULL;
+
+CREATE TABLE question_votes (
+    question_id INTEGER REFERENCES questions(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (question_id, user_id)
+);
+
+CREATE TABLE answer_votes (
+    answer_id INTEGER REFERENCES answers(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (answer_id, user_id)
+);
@@ -0,0 +1,18 @@
+CREATE TABLE notes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    training_id INTEGER REFERENCES trainings(id) ON DELETE CASCADE NOT NULL,
+    position_seconds INTEGER NOT NULL DEFAULT 0 CHECK (position_seconds >= 0),
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX notes_user_training_idx ON notes (user_id, training_id, position_seconds);
+
+CREATE TABLE bookmarks (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    training_id INTEGER REFERENCES trainings(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, training_id)
+);
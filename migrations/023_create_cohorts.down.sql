@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN calendar_token;
+DROP TABLE live_sessions;
+DROP TABLE cohort_enrollments;
+DROP TABLE cohorts;
@@ -0,0 +1,46 @@
+CREATE TABLE cohorts (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    price INTEGER NOT NULL DEFAULT 0 CHECK (price >= 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'brl',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX cohorts_course_idx ON cohorts (course_id, starts_at);
+
+-- Paid enrollments hold their seat while pending and become active once the
+-- Stripe webhook reports the payment succeeded.
+CREATE TABLE cohort_enrollments (
+    cohort_id INTEGER REFERENCES cohorts(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'active', 'cancelled')),
+    payment_id INTEGER REFERENCES payments(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cohort_id, user_id)
+);
+
+CREATE INDEX cohort_enrollments_user_idx ON cohort_enrollments (user_id);
+CREATE INDEX cohort_enrollments_payment_idx ON cohort_enrollments (payment_id);
+
+CREATE TABLE live_sessions (
+    id SERIAL PRIMARY KEY,
+    cohort_id INTEGER REFERENCES cohorts(id) ON DELETE CASCADE NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    meeting_url TEXT NOT NULL,
+    reminded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX live_sessions_cohort_idx ON live_sessions (cohort_id, starts_at);
+CREATE INDEX live_sessions_reminder_idx ON live_sessions (starts_at) WHERE reminded_at IS NULL;
+
+-- Calendar apps cannot send a bearer token, so each student's .ics feed is
+-- addressed by a secret token instead.
+ALTER TABLE users ADD COLUMN calendar_token VARCHAR(64) UNIQUE;
@@ -0,0 +1,7 @@
+DROP TABLE jam_entry_scores;
+DROP TABLE jam_votes;
+DROP TABLE jam_entries;
+DROP TABLE jam_team_members;
+DROP TABLE jam_teams;
+DROP TABLE jam_criteria;
+DROP TABLE game_jams;
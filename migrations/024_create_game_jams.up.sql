@@ -0,0 +1,80 @@
+-- A jam runs in phases: the theme is revealed at theme_reveal_at, entries are
+-- accepted until submission_deadline and voted on until voting_ends_at.
+CREATE TABLE game_jams (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    theme VARCHAR(255) NOT NULL,
+    theme_reveal_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    submission_deadline TIMESTAMP WITH TIME ZONE NOT NULL,
+    voting_ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    max_team_size INTEGER NOT NULL CHECK (max_team_size > 0),
+    results_published_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (submission_deadline > theme_reveal_at),
+    CHECK (voting_ends_at > submission_deadline)
+);
+
+CREATE INDEX game_jams_results_idx ON game_jams (voting_ends_at) WHERE results_published_at IS NULL;
+
+CREATE TABLE jam_criteria (
+    id SERIAL PRIMARY KEY,
+    jam_id INTEGER REFERENCES game_jams(id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL,
+    UNIQUE (jam_id, name)
+);
+
+CREATE TABLE jam_teams (
+    id SERIAL PRIMARY KEY,
+    jam_id INTEGER REFERENCES game_jams(id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    invite_code VARCHAR(32) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (jam_id, name)
+);
+
+-- jam_id is repeated so a student can only be in one team per jam.
+CREATE TABLE jam_team_members (
+    team_id INTEGER REFERENCES jam_teams(id) ON DELETE CASCADE NOT NULL,
+    jam_id INTEGER REFERENCES game_jams(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id),
+    UNIQUE (jam_id, user_id)
+);
+
+CREATE TABLE jam_entries (
+    id SERIAL PRIMARY KEY,
+    jam_id INTEGER REFERENCES game_jams(id) ON DELETE CASCADE NOT NULL,
+    team_id INTEGER REFERENCES jam_teams(id) ON DELETE CASCADE NOT NULL UNIQUE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    build_url TEXT,
+    build_key TEXT,
+    screenshot_keys TEXT[] NOT NULL DEFAULT '{}',
+    score DOUBLE PRECISION,
+    rank INTEGER,
+    submitted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX jam_entries_jam_idx ON jam_entries (jam_id, rank);
+
+CREATE TABLE jam_votes (
+    entry_id INTEGER REFERENCES jam_entries(id) ON DELETE CASCADE NOT NULL,
+    criterion_id INTEGER REFERENCES jam_criteria(id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    score INTEGER NOT NULL CHECK (score BETWEEN 1 AND 5),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entry_id, criterion_id, user_id)
+);
+
+-- Filled when voting closes: the average score of each entry per criterion.
+CREATE TABLE jam_entry_scores (
+    entry_id INTEGER REFERENCES jam_entries(id) ON DELETE CASCADE NOT NULL,
+    criterion_id INTEGER REFERENCES jam_criteria(id) ON DELETE CASCADE NOT NULL,
+    average DOUBLE PRECISION NOT NULL,
+    votes INTEGER NOT NULL,
+    PRIMARY KEY (entry_id, criterion_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE portfolio_hidden_items;
+DROP TABLE portfolios;
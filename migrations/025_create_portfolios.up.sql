@@ -0,0 +1,18 @@
+CREATE TABLE portfolios (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    display_name VARCHAR(100) NOT NULL,
+    headline VARCHAR(255) NOT NULL DEFAULT '',
+    public BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Portfolio items are shown unless the student hides them. Items are keyed by
+-- id, or by code for certificates and badges.
+CREATE TABLE portfolio_hidden_items (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('submission', 'jam_entry', 'certificate', 'badge')),
+    item_key VARCHAR(64) NOT NULL,
+    PRIMARY KEY (user_id, kind, item_key)
+);
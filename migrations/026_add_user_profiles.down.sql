@@ -0,0 +1,6 @@
+DROP TABLE email_changes;
+ALTER TABLE users DROP COLUMN country;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN bio;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN display_name;
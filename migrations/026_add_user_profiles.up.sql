@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN locale VARCHAR(10) NOT NULL DEFAULT 'pt-BR';
+ALTER TABLE users ADD COLUMN country VARCHAR(2) NOT NULL DEFAULT '';
+
+-- A pending email change, applied once the new address proves it receives
+-- mail. Only the SHA-256 of the emailed token is kept.
+CREATE TABLE email_changes (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
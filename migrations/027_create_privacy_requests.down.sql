@@ -0,0 +1,3 @@
+DROP TABLE account_deletions;
+DROP TABLE data_exports;
+ALTER TABLE users DROP COLUMN deleted_at;
@@ -0,0 +1,6 @@
+DROP TABLE two_factor_roles;
+DROP TABLE two_factor_challenges;
+DROP TABLE recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled_at;
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,26 @@
+-- totp_secret is set on enrollment and 2FA is only on once totp_enabled_at
+-- is. totp_last_step remembers the last code used so it can't be replayed.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- Issued by signin after the password of an account with 2FA checks out,
+-- and traded for a token together with a code.
+CREATE TABLE two_factor_challenges (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Roles whose members need 2FA to use their privileges
+CREATE TABLE two_factor_roles (
+    role VARCHAR(20) PRIMARY KEY CHECK (role IN ('instructor', 'admin'))
+);
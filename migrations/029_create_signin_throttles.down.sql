@@ -0,0 +1 @@
+DROP TABLE signin_throttles;
@@ -0,0 +1,11 @@
+-- Failed sign ins per key, where a key is either "account:<email>" or
+-- "ip:<address>". Unknown emails are tracked like real ones so responses
+-- don't tell them apart.
+CREATE TABLE signin_throttles (
+    key VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX signin_throttles_last_failure_idx ON signin_throttles (last_failure_at);
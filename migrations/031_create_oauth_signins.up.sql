@@ -0,0 +1,12 @@
+-- Sign ins finished at a provider and waiting for the app to collect them
+-- with their one-time code, which is stored hashed. A provider account that
+-- matched an existing user by email is kept here until the user proves they
+-- own that account with its password; only then is it linked.
+CREATE TABLE oauth_signins (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    link_provider VARCHAR(20),
+    link_subject VARCHAR(255),
+    link_email VARCHAR(255),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
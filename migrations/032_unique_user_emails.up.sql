@@ -0,0 +1,8 @@
+-- Emails are matched whatever their case, so accounts whose addresses differ
+-- only in case share one mailbox. The oldest keeps the address and the others
+-- get a placeholder, as deleted accounts do, until support sorts them out.
+UPDATE users u
+SET email = 'duplicate-' || u.id || '@duplicate.invalid'
+WHERE EXISTS (SELECT 1 FROM users older WHERE lower(older.email) = lower(u.email) AND older.id < u.id);
+
+CREATE UNIQUE INDEX users_lower_email_key ON users (lower(email));